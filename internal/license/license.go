@@ -0,0 +1,259 @@
+// Copyright 2026 ko Build Authors All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package license detects the licenses of Go modules from the files in their
+// module cache directories.
+package license
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"slices"
+	"strings"
+
+	"golang.org/x/mod/module"
+)
+
+// Unknown is returned for modules where no license could be detected.  It
+// matches the SPDX NOASSERTION value so it can be written to SBOMs as-is.
+const Unknown = "NOASSERTION"
+
+// licenseFilePrefixes are the (upper-cased) prefixes of file names that we
+// consider to hold a module's license text.
+var licenseFilePrefixes = []string{"LICENSE", "LICENCE", "COPYING", "UNLICENSE"}
+
+// matcher identifies a license from its normalized text.  All of the phrases
+// in all must appear, and none of the phrases in none may appear.
+type matcher struct {
+	id   string
+	all  []string
+	none []string
+}
+
+// matchers are checked in order, so licenses whose text mentions other
+// licenses (e.g. MPL-2.0 and LGPL mention the GPL) must come first.
+var matchers = []matcher{{
+	id:  "Apache-2.0",
+	all: []string{"apache license", "version 2.0"},
+}, {
+	id:  "MPL-2.0",
+	all: []string{"mozilla public license", "version 2.0"},
+}, {
+	id:  "EPL-2.0",
+	all: []string{"eclipse public license - v 2.0"},
+}, {
+	id:  "EPL-1.0",
+	all: []string{"eclipse public license - v 1.0"},
+}, {
+	id:  "AGPL-3.0-only",
+	all: []string{"gnu affero general public license", "version 3"},
+}, {
+	id:  "LGPL-3.0-only",
+	all: []string{"gnu lesser general public license", "version 3"},
+}, {
+	id:  "LGPL-2.1-only",
+	all: []string{"gnu lesser general public license", "version 2.1"},
+}, {
+	id:  "LGPL-2.0-only",
+	all: []string{"gnu library general public license", "version 2"},
+}, {
+	id:  "GPL-3.0-only",
+	all: []string{"gnu general public license", "version 3"},
+}, {
+	id:  "GPL-2.0-only",
+	all: []string{"gnu general public license", "version 2"},
+}, {
+	id:  "BSD-3-Clause",
+	all: []string{"redistribution and use in source and binary forms", "endorse or promote"},
+}, {
+	id:   "BSD-2-Clause",
+	all:  []string{"redistribution and use in source and binary forms"},
+	none: []string{"endorse or promote"},
+}, {
+	id:  "MIT",
+	all: []string{"permission is hereby granted, free of charge, to any person obtaining a copy"},
+}, {
+	id:  "ISC",
+	all: []string{"permission to use, copy, modify, and/or distribute this software for any purpose", "provided that the above copyright notice and this permission notice appear in all copies"},
+}, {
+	id:  "0BSD",
+	all: []string{"permission to use, copy, modify, and/or distribute this software for any purpose"},
+}, {
+	id:  "Unlicense",
+	all: []string{"this is free and unencumbered software released into the public domain"},
+}, {
+	id:  "CC0-1.0",
+	all: []string{"cc0 1.0 universal"},
+}, {
+	id:  "BSL-1.0",
+	all: []string{"boost software license - version 1.0"},
+}}
+
+// Identify returns the SPDX license identifier for the given license text,
+// or Unknown if the text is not recognized.
+func Identify(text string) string {
+	for _, line := range strings.Split(text, "\n") {
+		if _, id, ok := strings.Cut(line, "SPDX-License-Identifier:"); ok {
+			if id = strings.TrimSpace(id); id != "" {
+				return id
+			}
+		}
+	}
+
+	normalized := strings.Join(strings.Fields(strings.ToLower(text)), " ")
+	for _, m := range matchers {
+		if containsAll(normalized, m.all) && !containsAny(normalized, m.none) {
+			return m.id
+		}
+	}
+	return Unknown
+}
+
+func containsAll(s string, phrases []string) bool {
+	for _, p := range phrases {
+		if !strings.Contains(s, p) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsAny(s string, phrases []string) bool {
+	for _, p := range phrases {
+		if strings.Contains(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// DetectDir returns the SPDX license expression for the license files at the
+// top level of dir.  Multiple distinct licenses are combined with AND.
+func DetectDir(dir string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+
+	var ids []string
+	for _, e := range entries {
+		if e.IsDir() || !isLicenseFile(e.Name()) {
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return "", err
+		}
+		id := Identify(string(b))
+		if id == Unknown || slices.Contains(ids, id) {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return Unknown, nil
+	}
+	slices.Sort(ids)
+	return strings.Join(ids, " AND "), nil
+}
+
+func isLicenseFile(name string) bool {
+	upper := strings.ToUpper(name)
+	for _, p := range licenseFilePrefixes {
+		if strings.HasPrefix(upper, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// ModuleDir returns the directory holding the source of the given module.
+// Modules are looked up in modCache (i.e. GOMODCACHE), following replace
+// directives.  Replacements with a local path are resolved relative to
+// mainDir, the directory of the go.mod of the main module, as the go command
+// does.
+func ModuleDir(modCache, mainDir string, mod *debug.Module) (string, error) {
+	if mod.Replace != nil {
+		if isLocalPath(mod.Replace.Path) {
+			if filepath.IsAbs(mod.Replace.Path) {
+				return mod.Replace.Path, nil
+			}
+			return filepath.Join(mainDir, mod.Replace.Path), nil
+		}
+		mod = mod.Replace
+	}
+	path, err := module.EscapePath(mod.Path)
+	if err != nil {
+		return "", fmt.Errorf("escaping module path %q: %w", mod.Path, err)
+	}
+	version, err := module.EscapeVersion(mod.Version)
+	if err != nil {
+		return "", fmt.Errorf("escaping module version %q: %w", mod.Version, err)
+	}
+	return filepath.Join(modCache, filepath.FromSlash(path)+"@"+version), nil
+}
+
+// isLocalPath reports whether the target of a replace directive is a
+// directory rather than a module path, following the rules of the go command.
+func isLocalPath(path string) bool {
+	return path == "." || path == ".." || filepath.IsAbs(path) ||
+		strings.HasPrefix(path, "./") || strings.HasPrefix(path, "../") ||
+		strings.HasPrefix(path, `.\`) || strings.HasPrefix(path, `..\`)
+}
+
+// Detect returns the license expression of each module in mods, keyed by
+// module path.  Modules whose source cannot be found map to Unknown.  See
+// ModuleDir for modCache and mainDir.
+func Detect(modCache, mainDir string, mods []*debug.Module) (map[string]string, error) {
+	licenses := make(map[string]string, len(mods))
+	for _, mod := range mods {
+		modDir, err := ModuleDir(modCache, mainDir, mod)
+		if err != nil {
+			return nil, err
+		}
+		lic, err := DetectDir(modDir)
+		if os.IsNotExist(err) {
+			lic = Unknown
+		} else if err != nil {
+			return nil, fmt.Errorf("detecting license of %s: %w", mod.Path, err)
+		}
+		licenses[mod.Path] = lic
+	}
+	return licenses, nil
+}
+
+// IDs returns the license identifiers referenced by the SPDX expression.
+// Exceptions (the operand of WITH) are not license identifiers and are
+// skipped.
+func IDs(expr string) []string {
+	var ids []string
+	exception := false
+	for _, tok := range strings.Fields(strings.NewReplacer("(", " ", ")", " ").Replace(expr)) {
+		switch strings.ToUpper(tok) {
+		case "AND", "OR":
+			continue
+		case "WITH":
+			exception = true
+			continue
+		}
+		if exception {
+			exception = false
+			continue
+		}
+		ids = append(ids, tok)
+	}
+	return ids
+}
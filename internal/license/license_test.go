@@ -0,0 +1,163 @@
+// Copyright 2026 ko Build Authors All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package license
+
+import (
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const (
+	mitText = `MIT License
+
+Copyright (c) 2020 Example
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction.`
+
+	bsd3Text = `Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+   * Neither the name of Google Inc. nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.`
+
+	mplText = `Mozilla Public License Version 2.0
+==================================
+1.12. "Secondary License"
+    means either the GNU General Public License, Version 2.0, the GNU
+    Lesser General Public License, Version 2.1`
+)
+
+func TestIdentify(t *testing.T) {
+	for _, c := range []struct {
+		desc, text, want string
+	}{{
+		desc: "mit",
+		text: mitText,
+		want: "MIT",
+	}, {
+		desc: "bsd-3-clause",
+		text: bsd3Text,
+		want: "BSD-3-Clause",
+	}, {
+		desc: "mpl mentions gpl",
+		text: mplText,
+		want: "MPL-2.0",
+	}, {
+		desc: "apache",
+		text: "                                 Apache License\n                           Version 2.0, January 2004",
+		want: "Apache-2.0",
+	}, {
+		desc: "spdx identifier",
+		text: "// SPDX-License-Identifier: Apache-2.0 OR MIT\n",
+		want: "Apache-2.0 OR MIT",
+	}, {
+		desc: "unknown",
+		text: "All rights reserved.",
+		want: Unknown,
+	}} {
+		t.Run(c.desc, func(t *testing.T) {
+			if got := Identify(c.text); got != c.want {
+				t.Errorf("Identify() = %q, want %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestIDs(t *testing.T) {
+	got := IDs("(Apache-2.0 WITH LLVM-exception OR MIT) AND BSD-3-Clause")
+	want := []string{"Apache-2.0", "MIT", "BSD-3-Clause"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("IDs() (-want +got) = %s", diff)
+	}
+}
+
+func TestModuleDir(t *testing.T) {
+	for _, c := range []struct {
+		desc string
+		mod  *debug.Module
+		want string
+	}{{
+		desc: "escaped path",
+		mod:  &debug.Module{Path: "github.com/BurntSushi/toml", Version: "v1.6.0"},
+		want: filepath.Join("/cache", "github.com", "!burnt!sushi", "toml@v1.6.0"),
+	}, {
+		desc: "module replacement",
+		mod: &debug.Module{Path: "example.com/a", Version: "v1.0.0", Replace: &debug.Module{
+			Path: "example.com/b", Version: "v1.1.0",
+		}},
+		want: filepath.Join("/cache", "example.com", "b@v1.1.0"),
+	}, {
+		desc: "local replacement",
+		mod: &debug.Module{Path: "example.com/a", Version: "v1.0.0", Replace: &debug.Module{
+			Path: "../a",
+		}},
+		want: filepath.Join("/src", "..", "a"),
+	}, {
+		desc: "local replacement as reported by go version -m",
+		mod: &debug.Module{Path: "example.com/a", Version: "v1.0.0", Replace: &debug.Module{
+			Path: "./third_party/a", Version: "(devel)",
+		}},
+		want: filepath.Join("/src", "third_party", "a"),
+	}} {
+		t.Run(c.desc, func(t *testing.T) {
+			got, err := ModuleDir("/cache", "/src", c.mod)
+			if err != nil {
+				t.Fatalf("ModuleDir() = %v", err)
+			}
+			if got != c.want {
+				t.Errorf("ModuleDir() = %q, want %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestDetect(t *testing.T) {
+	cache := t.TempDir()
+	dual := filepath.Join(cache, "example.com", "dual@v1.0.0")
+	if err := os.MkdirAll(dual, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, text := range map[string]string{
+		"LICENSE-MIT":  mitText,
+		"LICENSE.bsd":  bsd3Text,
+		"README.md":    mplText,
+		"license_test": "unrelated",
+	} {
+		if err := os.WriteFile(filepath.Join(dual, name), []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := Detect(cache, "", []*debug.Module{
+		{Path: "example.com/dual", Version: "v1.0.0"},
+		{Path: "example.com/missing", Version: "v1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("Detect() = %v", err)
+	}
+	want := map[string]string{
+		"example.com/dual":    "BSD-3-Clause AND MIT",
+		"example.com/missing": Unknown,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Detect() (-want +got) = %s", diff)
+	}
+}
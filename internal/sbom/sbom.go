@@ -45,6 +45,16 @@ func goRef(mod *debug.Module) string {
 	return fmt.Sprintf("pkg:golang/%s@%s?type=module", path, mod.Version)
 }
 
+// ParseGoVersionM parses the output of `go version -m` into the build info
+// of the binary.
+func ParseGoVersionM(b []byte) (*debug.BuildInfo, error) {
+	b, err := massageGoVersionM(b)
+	if err != nil {
+		return nil, err
+	}
+	return debug.ParseBuildInfo(string(b))
+}
+
 // massageGoVersionM massages the output of `go version -m` into a form that
 // can be consumed by ParseBuildInfo.
 //
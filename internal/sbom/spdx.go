@@ -22,7 +22,6 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
-	"runtime/debug"
 	"strings"
 	"time"
 
@@ -66,14 +65,11 @@ func h1ToSHA256(s string) string {
 
 const dateFormat = "2006-01-02T15:04:05Z"
 
-func GenerateImageSPDX(koVersion string, mod []byte, img oci.SignedImage) ([]byte, error) {
-	var err error
-	mod, err = massageGoVersionM(mod)
-	if err != nil {
-		return nil, err
-	}
-
-	bi, err := debug.ParseBuildInfo(string(mod))
+// GenerateImageSPDX produces an SPDX document for the image from the output
+// of `go version -m`.  If licenses is non-nil, it maps module paths to the
+// license expression concluded for each dependency.
+func GenerateImageSPDX(koVersion string, mod []byte, img oci.SignedImage, licenses map[string]string) ([]byte, error) {
+	bi, err := ParseGoVersionM(mod)
 	if err != nil {
 		return nil, err
 	}
@@ -180,6 +176,10 @@ func GenerateImageSPDX(koVersion string, mod []byte, img oci.SignedImage) ([]byt
 			}},
 		}
 
+		if lic, ok := licenses[dep.Path]; ok && lic != "" {
+			pkg.LicenseConcluded = lic
+		}
+
 		if dep.Sum != "" {
 			pkg.Checksums = []Checksum{{
 				Algorithm: "SHA256",
@@ -199,6 +199,24 @@ func GenerateImageSPDX(koVersion string, mod []byte, img oci.SignedImage) ([]byt
 	return buf.Bytes(), nil
 }
 
+// Licenses returns the license concluded for each package of the SPDX
+// document b, keyed by package name, in the form GenerateImageSPDX takes.
+// Packages without a concluded license are omitted.
+func Licenses(b []byte) (map[string]string, error) {
+	var doc Document
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("parsing SPDX document: %w", err)
+	}
+	licenses := make(map[string]string, len(doc.Packages))
+	for _, pkg := range doc.Packages {
+		if pkg.LicenseConcluded == "" || pkg.LicenseConcluded == NOASSERTION {
+			continue
+		}
+		licenses[pkg.Name] = pkg.LicenseConcluded
+	}
+	return licenses, nil
+}
+
 func extractDate(sii oci.SignedImageIndex) (*time.Time, error) {
 	im, err := sii.IndexManifest()
 	if err != nil {
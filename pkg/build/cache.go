@@ -18,7 +18,9 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"log"
 	"os"
 	"os/exec"
@@ -29,6 +31,9 @@ import (
 	"github.com/google/go-containerregistry/pkg/logs"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/partial"
+
+	"github.com/google/ko/pkg/internal/atomicfile"
+	"github.com/google/ko/pkg/internal/flock"
 )
 
 type diffIDToDescriptor map[v1.Hash]v1.Descriptor
@@ -42,13 +47,20 @@ type layerCache struct {
 
 type layerFactory func() (v1.Layer, error)
 
-func (c *layerCache) get(ctx context.Context, file string, miss layerFactory) (v1.Layer, error) {
+// get returns the layer built from file with opts, using the metadata cached
+// by previous builds, if any, to avoid building it.
+func (c *layerCache) get(ctx context.Context, file string, opts *layerOptions, miss layerFactory) (v1.Layer, error) {
 	if os.Getenv("KOCACHE") == "" {
 		return miss()
 	}
 
+	optsKey, err := opts.cacheKey()
+	if err != nil {
+		return nil, err
+	}
+
 	// Cache hit.
-	if diffid, desc, err := c.getMeta(ctx, file); err != nil {
+	if diffid, desc, err := c.getMeta(ctx, file, optsKey); err != nil {
 		logs.Debug.Printf("getMeta(%q): %v", file, err)
 	} else {
 		return &lazyLayer{
@@ -63,13 +75,13 @@ func (c *layerCache) get(ctx context.Context, file string, miss layerFactory) (v
 	if err != nil {
 		return nil, fmt.Errorf("miss(%q): %w", file, err)
 	}
-	if err := c.put(ctx, file, layer); err != nil {
+	if err := c.put(ctx, file, optsKey, layer); err != nil {
 		log.Printf("failed to cache metadata %s: %v", file, err)
 	}
 	return layer, nil
 }
 
-func (c *layerCache) getMeta(ctx context.Context, file string) (*v1.Hash, *v1.Descriptor, error) {
+func (c *layerCache) getMeta(ctx context.Context, file, optsKey string) (*v1.Hash, *v1.Descriptor, error) {
 	buildid, err := getBuildID(ctx, file)
 	if err != nil {
 		return nil, nil, err
@@ -78,8 +90,8 @@ func (c *layerCache) getMeta(ctx context.Context, file string) (*v1.Hash, *v1.De
 	if buildid == "" {
 		return nil, nil, fmt.Errorf("no buildid for %q", file)
 	}
+	key := layerKey(buildid, optsKey)
 
-	// TODO: Implement better per-file locking.
 	c.Lock()
 	defer c.Unlock()
 
@@ -92,9 +104,9 @@ func (c *layerCache) getMeta(ctx context.Context, file string) (*v1.Hash, *v1.De
 		return nil, nil, err
 	}
 
-	diffid, ok := btod[buildid]
+	diffid, ok := btod[key]
 	if !ok {
-		return nil, nil, fmt.Errorf("no diffid for %q", buildid)
+		return nil, nil, fmt.Errorf("no diffid for %q", key)
 	}
 
 	desc, ok := dtod[diffid]
@@ -106,11 +118,16 @@ func (c *layerCache) getMeta(ctx context.Context, file string) (*v1.Hash, *v1.De
 }
 
 // Compute new layer metadata and cache it in-mem and on-disk.
-func (c *layerCache) put(ctx context.Context, file string, layer v1.Layer) error {
+//
+// Other ko processes may have added to the on-disk metadata since we read
+// it, so it is read again and merged with the new entry.  The caller holds
+// the lock from lockBinary, so the on-disk metadata can't change meanwhile.
+func (c *layerCache) put(ctx context.Context, file, optsKey string, layer v1.Layer) error {
 	buildid, err := getBuildID(ctx, file)
 	if err != nil {
 		return err
 	}
+	key := layerKey(buildid, optsKey)
 
 	desc, err := partial.Descriptor(layer)
 	if err != nil {
@@ -122,43 +139,59 @@ func (c *layerCache) put(ctx context.Context, file string, layer v1.Layer) error
 		return err
 	}
 
-	btod, ok := c.buildToDiff[file]
-	if !ok {
+	c.Lock()
+	defer c.Unlock()
+
+	delete(c.buildToDiff, file)
+	delete(c.diffToDesc, file)
+
+	btod, err := c.readBuildToDiff(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		btod = buildIDToDiffID{}
+	} else if err != nil {
+		logs.Debug.Printf("discarding unreadable buildid-to-diffid for %q: %v", file, err)
 		btod = buildIDToDiffID{}
 	}
-	btod[buildid] = diffid
+	btod[key] = diffid
 
-	dtod, ok := c.diffToDesc[file]
-	if !ok {
+	dtod, err := c.readDiffToDesc(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		dtod = diffIDToDescriptor{}
+	} else if err != nil {
+		logs.Debug.Printf("discarding unreadable diffid-to-descriptor for %q: %v", file, err)
 		dtod = diffIDToDescriptor{}
 	}
 	dtod[diffid] = *desc
 
-	// TODO: Implement better per-file locking.
-	c.Lock()
-	defer c.Unlock()
+	c.buildToDiff[file] = btod
+	c.diffToDesc[file] = dtod
 
-	btodf, err := os.OpenFile(filepath.Join(filepath.Dir(file), "buildid-to-diffid"), os.O_RDWR|os.O_CREATE, 0755)
-	if err != nil {
-		return fmt.Errorf("opening buildid-to-diffid: %w", err)
+	// Write the descriptors first, so that a diffid is never recorded for
+	// a buildid without its descriptor.
+	if err := writeJSON(filepath.Join(filepath.Dir(file), "diffid-to-descriptor"), dtod); err != nil {
+		return fmt.Errorf("writing diffid-to-descriptor: %w", err)
 	}
-	defer btodf.Close()
+	if err := writeJSON(filepath.Join(filepath.Dir(file), "buildid-to-diffid"), btod); err != nil {
+		return fmt.Errorf("writing buildid-to-diffid: %w", err)
+	}
+	return nil
+}
 
-	dtodf, err := os.OpenFile(filepath.Join(filepath.Dir(file), "diffid-to-descriptor"), os.O_RDWR|os.O_CREATE, 0755)
-	if err != nil {
-		return fmt.Errorf("opening diffid-to-descriptor: %w", err)
+// layerKey returns the key that the layer built from the binary with
+// buildid, and the layer options with optsKey, is cached under.
+func layerKey(buildid, optsKey string) string {
+	if optsKey == "" {
+		return buildid
 	}
-	defer dtodf.Close()
+	return buildid + "+" + optsKey
+}
 
-	enc := json.NewEncoder(btodf)
-	enc.SetIndent("", "  ")
-	if err := enc.Encode(&btod); err != nil {
+func writeJSON(path string, v any) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
 		return err
 	}
-
-	enc = json.NewEncoder(dtodf)
-	enc.SetIndent("", "  ")
-	return enc.Encode(&dtod)
+	return atomicfile.WriteFile(path, append(b, '\n'), 0o644)
 }
 
 func (c *layerCache) readDiffToDesc(file string) (diffIDToDescriptor, error) {
@@ -199,6 +232,30 @@ func (c *layerCache) readBuildToDiff(file string) (buildIDToDiffID, error) {
 	return btod, nil
 }
 
+// binaryDir returns the directory in KOCACHE that the binary for ip is built
+// into for platform.
+func binaryDir(kocache, ip string, platform v1.Platform) string {
+	return filepath.Join(kocache, "bin", ip, platform.String())
+}
+
+// lockBinary takes an exclusive lock on dir, the KOCACHE directory that a
+// binary is built into, so that other ko processes building the same import
+// path for the same platform don't replace the binary or its layer metadata
+// until we're done with them.
+func lockBinary(kocache, dir string) (*flock.Lock, error) {
+	// Keep "ko cache prune" from removing dir while we hold a lock in it.
+	if err := os.MkdirAll(kocache, os.ModePerm); err != nil {
+		return nil, err
+	}
+	if err := flock.Hold(filepath.Join(kocache, ".lock")); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	return flock.Exclusive(filepath.Join(dir, ".lock"))
+}
+
 func getBuildID(ctx context.Context, file string) (string, error) {
 	gobin := getGoBinary()
 
@@ -0,0 +1,218 @@
+// Copyright 2026 ko Build Authors All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package build
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+
+	"github.com/google/ko/pkg/caps"
+)
+
+// layerCacheWorkerEnv makes TestLayerCacheConcurrentProcesses act as one of
+// the ko processes sharing a KOCACHE, recording layers for the binary it is
+// set to.
+const layerCacheWorkerEnv = "KO_TEST_LAYER_CACHE_WORKER"
+
+const layerCachePuts = 5
+
+func TestLayerCacheConcurrentProcesses(t *testing.T) {
+	if file := os.Getenv(layerCacheWorkerEnv); file != "" {
+		layerCacheWorker(t, file)
+		return
+	}
+	if testing.Short() {
+		t.Skip("skipping stress test in short mode")
+	}
+
+	kocache := t.TempDir()
+	dir := binaryDir(kocache, "example.com/app", v1.Platform{OS: "linux", Architecture: "amd64"})
+	file := copyGoBinary(t, dir)
+
+	const workers = 4
+	cmds := make([]*exec.Cmd, workers)
+	outs := make([]bytes.Buffer, workers)
+	for i := range workers {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestLayerCacheConcurrentProcesses$")
+		cmd.Env = append(os.Environ(), "KOCACHE="+kocache, layerCacheWorkerEnv+"="+file)
+		cmd.Stdout, cmd.Stderr = &outs[i], &outs[i]
+		if err := cmd.Start(); err != nil {
+			t.Fatal(err)
+		}
+		cmds[i] = cmd
+	}
+	for i, cmd := range cmds {
+		if err := cmd.Wait(); err != nil {
+			t.Errorf("worker %d: %v\n%s", i, err, outs[i].String())
+		}
+	}
+
+	// No process lost another's updates.
+	b, err := os.ReadFile(filepath.Join(dir, "diffid-to-descriptor"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dtod diffIDToDescriptor
+	if err := json.Unmarshal(b, &dtod); err != nil {
+		t.Fatalf("diffid-to-descriptor is corrupt: %v", err)
+	}
+	if got, want := len(dtod), workers*layerCachePuts; got != want {
+		t.Errorf("diffid-to-descriptor has %d entries, wanted %d", got, want)
+	}
+}
+
+// copyGoBinary copies a Go binary into dir, as if it had been built there,
+// and returns its path.  Any Go binary has a build ID; this uses the go
+// command's.
+func copyGoBinary(t *testing.T, dir string) string {
+	t.Helper()
+	gobin, err := exec.LookPath(getGoBinary())
+	if err != nil {
+		t.Skipf("no go binary: %v", err)
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "out")
+	src, err := os.Open(gobin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	dst, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+	if _, err := io.Copy(dst, src); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestLayerCacheOptions(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("KOCACHE", t.TempDir())
+	file := copyGoBinary(t, t.TempDir())
+	c := &layerCache{
+		buildToDiff: map[string]buildIDToDiffID{},
+		diffToDesc:  map[string]diffIDToDescriptor{},
+	}
+
+	netBind, err := caps.NewFileCaps("CAP_NET_BIND_SERVICE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sysTime, err := caps.NewFileCaps("CAP_SYS_TIME")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := []*layerOptions{{}, {linuxCapabilities: netBind}, {linuxCapabilities: sysTime}}
+
+	// Each set of options gets a layer of its own.
+	want := make([]v1.Layer, len(opts))
+	for i, lo := range opts {
+		want[i], err = random.Layer(1024, "application/vnd.oci.image.layer.v1.tar+gzip")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.get(ctx, file, lo, func() (v1.Layer, error) { return want[i], nil }); err != nil {
+			t.Fatalf("get() = %v", err)
+		}
+	}
+
+	// After which they're all cached.
+	for i, lo := range opts {
+		got, err := c.get(ctx, file, lo, func() (v1.Layer, error) {
+			return nil, errors.New("unexpected cache miss")
+		})
+		if err != nil {
+			t.Fatalf("get(%d) = %v", i, err)
+		}
+		gotDigest, err := got.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wantDigest, err := want[i].Digest(); err != nil || gotDigest != wantDigest {
+			t.Errorf("get(%d) = %s, wanted %s", i, gotDigest, wantDigest)
+		}
+	}
+
+	// The default options use the key that layers were cached under before
+	// there were options, and equal options share a key.
+	if key, err := (&layerOptions{}).cacheKey(); err != nil || key != "" {
+		t.Errorf("cacheKey() = %q, %v; wanted none for the default options", key, err)
+	}
+	same, err := caps.NewFileCaps("cap_net_bind_service=p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	k1, err := opts[1].cacheKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k2, err := (&layerOptions{linuxCapabilities: same}).cacheKey(); err != nil || k1 != k2 {
+		t.Errorf("cacheKey() = %q, %v; wanted %q", k2, err, k1)
+	}
+}
+
+// layerCacheWorker repeatedly records a new layer for file, and checks that
+// another ko process reading the cache would see it.
+func layerCacheWorker(t *testing.T, file string) {
+	ctx := context.Background()
+	kocache := os.Getenv("KOCACHE")
+	c := &layerCache{
+		buildToDiff: map[string]buildIDToDiffID{},
+		diffToDesc:  map[string]diffIDToDescriptor{},
+	}
+	for range layerCachePuts {
+		l, err := lockBinary(kocache, filepath.Dir(file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		layer, err := random.Layer(1024, "application/vnd.oci.image.layer.v1.tar+gzip")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.put(ctx, file, "", layer); err != nil {
+			t.Fatalf("put() = %v", err)
+		}
+
+		fresh := &layerCache{
+			buildToDiff: map[string]buildIDToDiffID{},
+			diffToDesc:  map[string]diffIDToDescriptor{},
+		}
+		diffid, _, err := fresh.getMeta(ctx, file, "")
+		if err != nil {
+			t.Fatalf("getMeta() = %v", err)
+		}
+		if want, err := layer.DiffID(); err != nil || *diffid != want {
+			t.Errorf("getMeta() = %s, wanted %s", diffid, want)
+		}
+		if err := l.Unlock(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+// Copyright 2026 ko Build Authors All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package build
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"strconv"
+
+	"github.com/containerd/stargz-snapshotter/estargz"
+	"github.com/google/go-containerregistry/pkg/compression"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	godigest "github.com/opencontainers/go-digest"
+)
+
+// Compression is how the layers that ko builds are compressed.
+type Compression string
+
+const (
+	// CompressionGzip compresses layers with gzip.  It is the default.
+	CompressionGzip Compression = "gzip"
+	// CompressionZstd compresses layers with zstd, which requires images
+	// with OCI media types.
+	CompressionZstd Compression = "zstd"
+	// CompressionEstargz compresses layers as eStargz, which is gzip with a
+	// table of contents that lets runtimes pull files lazily.
+	CompressionEstargz Compression = "estargz"
+)
+
+// ParseCompression parses the name of a Compression and, if not zero, a
+// compression level for it.
+func ParseCompression(s string, level int) (Compression, error) {
+	c := Compression(s)
+	var lo, hi int
+	switch c {
+	case "":
+		c, lo, hi = CompressionGzip, 1, 9
+	case CompressionGzip, CompressionEstargz:
+		lo, hi = 1, 9
+	case CompressionZstd:
+		lo, hi = 1, 22
+	default:
+		return "", fmt.Errorf("unsupported compression %q, must be one of gzip, zstd or estargz", s)
+	}
+	if level != 0 && (level < lo || level > hi) {
+		return "", fmt.Errorf("%s compression level must be between %d and %d, got %d", c, lo, hi, level)
+	}
+	return c, nil
+}
+
+// layerMediaType returns the media type of layers compressed with c, given
+// the media type of gzip layers in the image.
+func (c Compression) layerMediaType(gzipType types.MediaType) types.MediaType {
+	if c == CompressionZstd {
+		return types.OCILayerZStd
+	}
+	return gzipType
+}
+
+// newLayer returns a layer of the tarball that write writes, compressed as
+// opts say, with mediaType, which must be the one layerMediaType returns.  The
+// tarball and the layer are spooled to temporary files rather than held in
+// memory.
+func newLayer(write func(io.Writer) error, mediaType types.MediaType, opts *layerOptions) (v1.Layer, error) {
+	var c Compression
+	var level int
+	if opts != nil {
+		c, level = opts.compression, opts.compressionLevel
+	}
+
+	f, size, err := spool(write)
+	if err != nil {
+		return nil, err
+	}
+	defer removeTemp(f)
+	if c == CompressionEstargz {
+		return newEstargzLayer(io.NewSectionReader(f, 0, size), level, mediaType)
+	}
+
+	lopts := []tarball.LayerOption{tarball.WithMediaType(mediaType)}
+	if level != 0 {
+		lopts = append(lopts, tarball.WithCompressionLevel(level))
+	}
+	if c == CompressionZstd {
+		lopts = append(lopts, tarball.WithCompression(compression.ZStd))
+	}
+	l, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		return io.NopCloser(io.NewSectionReader(f, 0, size)), nil
+	}, lopts...)
+	if err != nil {
+		return nil, err
+	}
+	rc, err := l.Compressed()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return blobLayer(rc, mediaType)
+}
+
+// newEstargzLayer returns an eStargz layer of the tarball tr, annotated with
+// the digest of its table of contents as lazy pulling requires.
+func newEstargzLayer(tr *io.SectionReader, level int, mediaType types.MediaType) (v1.Layer, error) {
+	if level == 0 {
+		level = gzip.BestCompression
+	}
+	blob, err := estargz.Build(tr,
+		estargz.WithCompression(&estargzCompression{
+			GzipCompressor:   estargz.NewGzipCompressorWithLevel(level),
+			GzipDecompressor: &estargz.GzipDecompressor{},
+		}))
+	if err != nil {
+		return nil, fmt.Errorf("building eStargz layer: %w", err)
+	}
+	defer blob.Close()
+	l, err := blobLayer(blob, mediaType)
+	if err != nil {
+		return nil, fmt.Errorf("building eStargz layer: %w", err)
+	}
+	size, err := blob.UncompressedSize()
+	if err != nil {
+		return nil, err
+	}
+	return &annotatedLayer{
+		Layer: l,
+		annotations: map[string]string{
+			estargz.TOCJSONDigestAnnotation:         blob.TOCDigest().String(),
+			estargz.StoreUncompressedSizeAnnotation: strconv.FormatInt(size, 10),
+		},
+	}, nil
+}
+
+// annotatedLayer is a layer whose descriptor carries annotations.
+type annotatedLayer struct {
+	v1.Layer
+	annotations map[string]string
+}
+
+// Descriptor implements partial.withDescriptor.
+func (l *annotatedLayer) Descriptor() (*v1.Descriptor, error) {
+	desc, err := partial.Descriptor(l.Layer)
+	if err != nil {
+		return nil, err
+	}
+	desc.Annotations = l.annotations
+	return desc, nil
+}
+
+// estargzCompression is estargz's gzip compression, but writing the footer
+// itself.  estargz compresses the footer with compress/gzip, which stopped
+// producing the exact 51 bytes it must be in Go 1.26.
+type estargzCompression struct {
+	*estargz.GzipCompressor
+	*estargz.GzipDecompressor
+}
+
+// WriteTOCAndFooter implements estargz.Compressor.
+func (c *estargzCompression) WriteTOCAndFooter(w io.Writer, off int64, toc *estargz.JTOC, diffHash hash.Hash) (godigest.Digest, error) {
+	tocJSON, err := json.MarshalIndent(toc, "", "\t")
+	if err != nil {
+		return "", err
+	}
+	gz, err := c.Writer(w)
+	if err != nil {
+		return "", err
+	}
+	gw := io.Writer(gz)
+	if diffHash != nil {
+		gw = io.MultiWriter(gz, diffHash)
+	}
+	tw := tar.NewWriter(gw)
+	if err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     estargz.TOCTarName,
+		Size:     int64(len(tocJSON)),
+	}); err != nil {
+		return "", err
+	}
+	if _, err := tw.Write(tocJSON); err != nil {
+		return "", err
+	}
+	if err := tw.Close(); err != nil {
+		return "", err
+	}
+	if err := gz.Close(); err != nil {
+		return "", err
+	}
+	if _, err := w.Write(estargzFooter(off)); err != nil {
+		return "", err
+	}
+	return godigest.FromBytes(tocJSON), nil
+}
+
+// estargzFooter returns the footer of an eStargz blob whose table of contents
+// is at tocOff: an empty gzip stream, with the offset in its extra field and
+// a stored block for its body.
+func estargzFooter(tocOff int64) []byte {
+	extra := fmt.Sprintf("SG\x16\x00%016xSTARGZ", tocOff)
+	b := make([]byte, 0, estargz.FooterSize)
+	// Magic, deflate, FEXTRA, no mtime, no extra flags, unknown OS.
+	b = append(b, 0x1f, 0x8b, 8, 4, 0, 0, 0, 0, 0, 0xff)
+	b = binary.LittleEndian.AppendUint16(b, uint16(len(extra)))
+	b = append(b, extra...)
+	// A final, empty stored block.
+	b = append(b, 1, 0, 0, 0xff, 0xff)
+	// The CRC-32 and size of the empty body.
+	b = append(b, 0, 0, 0, 0, 0, 0, 0, 0)
+	return b
+}
@@ -0,0 +1,160 @@
+// Copyright 2026 ko Build Authors All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package build
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/containerd/stargz-snapshotter/estargz"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+func TestParseCompression(t *testing.T) {
+	for _, c := range []struct {
+		name    string
+		level   int
+		want    Compression
+		wantErr bool
+	}{
+		{name: "", want: CompressionGzip},
+		{name: "gzip", level: 9, want: CompressionGzip},
+		{name: "zstd", level: 19, want: CompressionZstd},
+		{name: "estargz", want: CompressionEstargz},
+		{name: "gzip", level: 10, wantErr: true},
+		{name: "zstd", level: -1, wantErr: true},
+		{name: "brotli", wantErr: true},
+	} {
+		got, err := ParseCompression(c.name, c.level)
+		if (err != nil) != c.wantErr {
+			t.Errorf("ParseCompression(%q, %d) = %v, wanted error %t", c.name, c.level, err, c.wantErr)
+		}
+		if got != c.want {
+			t.Errorf("ParseCompression(%q, %d) = %q, wanted %q", c.name, c.level, got, c.want)
+		}
+	}
+}
+
+func TestGoBuildCompression(t *testing.T) {
+	const baseLayers = 1
+	for _, c := range []struct {
+		compression   Compression
+		wantMediaType types.MediaType
+		wantLayerType types.MediaType
+	}{
+		{CompressionGzip, types.DockerManifestSchema2, types.DockerLayer},
+		{CompressionZstd, types.OCIManifestSchema1, types.OCILayerZStd},
+		{CompressionEstargz, types.DockerManifestSchema2, types.DockerLayer},
+	} {
+		t.Run(string(c.compression), func(t *testing.T) {
+			base := mutate.MediaType(empty.Image, types.DockerManifestSchema2)
+			l, err := random.Layer(10, types.DockerLayer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			base, err = mutate.AppendLayers(base, l)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ng, err := NewGo(
+				context.Background(),
+				"",
+				WithBaseImages(func(context.Context, string) (name.Reference, Result, error) { return baseRef, base, nil }),
+				withBuilder(writeTempFile),
+				withSBOMber(fauxSBOM),
+				WithPlatforms("all"),
+				WithCompression(c.compression, 0),
+			)
+			if err != nil {
+				t.Fatalf("NewGo() = %v", err)
+			}
+			result, err := ng.Build(context.Background(), StrictScheme+"github.com/google/ko")
+			if err != nil {
+				t.Fatalf("Build() = %v", err)
+			}
+			img, ok := result.(v1.Image)
+			if !ok {
+				t.Fatalf("Build() not an Image: %T", result)
+			}
+
+			m, err := img.Manifest()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m.MediaType != c.wantMediaType {
+				t.Errorf("image mediaType = %q, wanted %q", m.MediaType, c.wantMediaType)
+			}
+			ls, err := img.Layers()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, desc := range m.Layers[baseLayers:] {
+				if desc.MediaType != c.wantLayerType {
+					t.Errorf("layer %d: mediaType = %q, wanted %q", i, desc.MediaType, c.wantLayerType)
+				}
+				_, hasTOC := desc.Annotations[estargz.TOCJSONDigestAnnotation]
+				if hasTOC != (c.compression == CompressionEstargz) {
+					t.Errorf("layer %d: annotations = %v", i, desc.Annotations)
+				}
+
+				// The layers are compressed as their media types say.
+				rc, err := ls[baseLayers+i].Compressed()
+				if err != nil {
+					t.Fatalf("Compressed() = %v", err)
+				}
+				magic := make([]byte, 4)
+				if _, err := io.ReadFull(rc, magic); err != nil {
+					t.Fatal(err)
+				}
+				rc.Close()
+				wantMagic := []byte{0x1f, 0x8b}
+				if c.compression == CompressionZstd {
+					wantMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+				}
+				if !bytes.HasPrefix(magic, wantMagic) {
+					t.Errorf("layer %d starts with %x, wanted %x", i, magic, wantMagic)
+				}
+
+				if c.compression == CompressionEstargz {
+					// Runtimes can find the files in the layer.
+					rc, err := ls[baseLayers+i].Compressed()
+					if err != nil {
+						t.Fatal(err)
+					}
+					blob, err := io.ReadAll(rc)
+					if err != nil {
+						t.Fatal(err)
+					}
+					rc.Close()
+					r, err := estargz.Open(io.NewSectionReader(bytes.NewReader(blob), 0, int64(len(blob))))
+					if err != nil {
+						t.Fatalf("estargz.Open() = %v", err)
+					}
+					if got, want := r.TOCDigest().String(), desc.Annotations[estargz.TOCJSONDigestAnnotation]; got != want {
+						t.Errorf("layer %d: TOC digest = %s, wanted %s", i, got, want)
+					}
+				}
+			}
+		})
+	}
+}
@@ -14,7 +14,10 @@
 
 package build
 
-import "strings"
+import (
+	"os"
+	"strings"
+)
 
 // Note: The structs, types, and functions are based upon GoReleaser build
 // configuration to have a loosely compatible YAML configuration:
@@ -96,4 +99,57 @@ type Config struct {
 	// extension: Linux capabilities to enable on the executable, applies
 	// to Linux targets.
 	LinuxCapabilities FlagArray `yaml:"linux_capabilities,omitempty"`
+
+	// extension: how the kodata directory is put in the image.
+	Kodata KodataConfig `yaml:",omitempty"`
+
+	// extension: template of the name of the image, which overrides the
+	// top-level imageName of .ko.yaml.
+	ImageName string `yaml:"image_name,omitempty"`
+}
+
+// KodataConfig configures how the kodata directory is put in the image.
+type KodataConfig struct {
+	// Layers splits kodata into a layer for each rule, in order, followed
+	// by a layer of the files no rule matches.  Each file goes in the layer
+	// of the first rule it matches.  Listing rules from the least to the
+	// most frequently changing files lets unchanged layers be reused.
+	Layers []KodataLayer `yaml:",omitempty"`
+
+	// UID and GID own kodata's files and directories, instead of root.
+	UID int `yaml:"uid,omitempty"`
+	GID int `yaml:"gid,omitempty"`
+
+	// FileMode and DirMode are the permissions of kodata's files and
+	// directories, instead of 0555.
+	FileMode os.FileMode `yaml:"file_mode,omitempty"`
+	DirMode  os.FileMode `yaml:"dir_mode,omitempty"`
+
+	// PreserveExec gives files the execute bits for whoever may read them
+	// only if they are executable in kodata, rather than all the same.
+	PreserveExec bool `yaml:"preserve_exec,omitempty"`
+}
+
+// KodataLayer is a rule selecting kodata files for a layer of their own.
+// A file matches if it matches all of the fields that are set.
+type KodataLayer struct {
+	// Paths are path.Match patterns, relative to the kodata directory, that
+	// match a file or any of the directories it is in.
+	Paths StringArray `yaml:",omitempty"`
+
+	// MinSize and MaxSize, such as "1MB", are the smallest size of files
+	// that match, and the size that they must be smaller than.
+	MinSize string `yaml:"min_size,omitempty"`
+	MaxSize string `yaml:"max_size,omitempty"`
+}
+
+// LicensePolicy lists the SPDX license identifiers that the dependencies of
+// built binaries may or may not use.
+type LicensePolicy struct {
+	// Allow, if non-empty, is the exhaustive list of permitted licenses.
+	// Dependencies whose license cannot be detected are rejected.
+	Allow []string `yaml:",omitempty"`
+
+	// Deny lists licenses that are never permitted.
+	Deny []string `yaml:",omitempty"`
 }
@@ -32,6 +32,7 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 	"text/template"
 	"time"
 
@@ -39,10 +40,10 @@ import (
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/empty"
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
-	"github.com/google/go-containerregistry/pkg/v1/tarball"
 	"github.com/google/go-containerregistry/pkg/v1/types"
 	"github.com/google/ko/internal/sbom"
 	"github.com/google/ko/pkg/caps"
+	"github.com/google/ko/pkg/internal/flock"
 	"github.com/google/ko/pkg/internal/git"
 	specsv1 "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/sigstore/cosign/v3/pkg/oci"
@@ -63,6 +64,8 @@ const (
 )
 
 // GetBase takes an importpath and returns a base image reference and base image (or index).
+// The reference is nil for base images that were not pulled from a registry,
+// such as those loaded from an OCI image layout.
 type GetBase func(context.Context, string) (name.Reference, Result, error)
 
 // buildContext provides parameters for a builder function.
@@ -78,7 +81,7 @@ type buildContext struct {
 
 type builder func(context.Context, buildContext) (string, error)
 
-type sbomber func(context.Context, string, string, string, oci.SignedEntity, string) ([]byte, types.MediaType, error)
+type sbomber func(context.Context, string, string, string, oci.SignedEntity, string, *binaryInfo) ([]byte, types.MediaType, error)
 
 type platformMatcher struct {
 	spec      []string
@@ -106,9 +109,13 @@ type gobuild struct {
 	annotations          map[string]string
 	user                 string
 	debug                bool
+	licensePolicy        *LicensePolicy
+	compression          Compression
+	compressionLevel     int
 	semaphore            *semaphore.Weighted
 
-	cache *layerCache
+	cache  *layerCache
+	kodata *sync.Map // kodataKey -> *kodataEntry
 }
 
 // Option is a functional option for NewGo.
@@ -136,6 +143,9 @@ type gobuildOpener struct {
 	dir                  string
 	jobs                 int
 	debug                bool
+	licensePolicy        *LicensePolicy
+	compression          Compression
+	compressionLevel     int
 }
 
 func (gbo *gobuildOpener) Open() (Interface, error) {
@@ -172,11 +182,15 @@ func (gbo *gobuildOpener) Open() (Interface, error) {
 		annotations:          gbo.annotations,
 		dir:                  gbo.dir,
 		debug:                gbo.debug,
+		licensePolicy:        gbo.licensePolicy,
+		compression:          gbo.compression,
+		compressionLevel:     gbo.compressionLevel,
 		platformMatcher:      matcher,
 		cache: &layerCache{
 			buildToDiff: map[string]buildIDToDiffID{},
 			diffToDesc:  map[string]diffIDToDescriptor{},
 		},
+		kodata:    &sync.Map{},
 		semaphore: semaphore.NewWeighted(int64(gbo.jobs)),
 	}, nil
 }
@@ -402,8 +416,13 @@ func build(ctx context.Context, buildCtx buildContext) (string, error) {
 			return "", fmt.Errorf("KOCACHE should be a directory, %s is not a directory", dir)
 		}
 
+		// Keep "ko cache prune" from removing binaries while we build.
+		if err := flock.Hold(filepath.Join(dir, ".lock")); err != nil {
+			return "", fmt.Errorf("locking KOCACHE: %w", err)
+		}
+
 		// TODO(#264): if KOCACHE is unset, default to filepath.Join(os.TempDir(), "ko").
-		tmpDir = filepath.Join(dir, "bin", buildCtx.ip, buildCtx.platform.String())
+		tmpDir = binaryDir(dir, buildCtx.ip, buildCtx.platform)
 		/* #nosec G304 G703 -- tmpDir is derived from the user-controlled KOCACHE. */
 		if err := os.MkdirAll(tmpDir, os.ModePerm); err != nil {
 			return "", fmt.Errorf("creating KOCACHE bin dir: %w", err)
@@ -476,22 +495,32 @@ func goenv(ctx context.Context) (map[string]string, error) {
 	return env, nil
 }
 
-func goversionm(ctx context.Context, file string, appPath string, appFileName string, se oci.SignedEntity, dir string) ([]byte, types.MediaType, error) {
+// runGoVersionM returns the output of `go version -m` for the binary.
+func runGoVersionM(ctx context.Context, file string) ([]byte, error) {
 	gobin := getGoBinary()
 
+	out := bytes.NewBuffer(nil)
+	cmd := exec.CommandContext(ctx, gobin, "version", "-m", file)
+	cmd.Stdout = out
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("go version -m %s: %w", file, err)
+	}
+	return out.Bytes(), nil
+}
+
+func goversionm(ctx context.Context, bin *binaryInfo, appPath string, appFileName string, se oci.SignedEntity, dir string) ([]byte, types.MediaType, error) {
 	switch se.(type) {
 	case oci.SignedImage:
-		sbom := bytes.NewBuffer(nil)
-		cmd := exec.CommandContext(ctx, gobin, "version", "-m", file)
-		cmd.Stdout = sbom
-		cmd.Stderr = os.Stderr
-		if err := cmd.Run(); err != nil {
-			return nil, "", fmt.Errorf("go version -m %s: %w", file, err)
+		sbom, err := bin.goVersionM(ctx)
+		if err != nil {
+			return nil, "", err
 		}
+		file := bin.file
 
 		// In order to get deterministics SBOMs replace our randomized
 		// file name with the path the app will get inside of the container.
-		s := []byte(strings.Replace(sbom.String(), file, appPath, 1))
+		s := []byte(strings.Replace(string(sbom), file, appPath, 1))
 
 		if err := writeSBOM(s, appFileName, dir, "go.version-m"); err != nil {
 			return nil, "", fmt.Errorf("writing sbom: %w", err)
@@ -508,15 +537,23 @@ func goversionm(ctx context.Context, file string, appPath string, appFileName st
 }
 
 func spdx(version string) sbomber {
-	return func(ctx context.Context, file string, appPath string, appFileName string, se oci.SignedEntity, dir string) ([]byte, types.MediaType, error) {
+	return func(ctx context.Context, _ string, appPath string, appFileName string, se oci.SignedEntity, dir string, bin *binaryInfo) ([]byte, types.MediaType, error) {
 		switch obj := se.(type) {
 		case oci.SignedImage:
-			b, _, err := goversionm(ctx, file, appPath, "", obj, "")
+			b, _, err := goversionm(ctx, bin, appPath, "", obj, "")
 			if err != nil {
 				return nil, "", err
 			}
 
-			b, err = sbom.GenerateImageSPDX(version, b, obj)
+			// Licenses are informational unless a license policy is set,
+			// so failing to detect them doesn't fail the build.
+			licenses, err := bin.detectLicenses(ctx)
+			if err != nil {
+				log.Printf("Unable to detect module licenses of %s, recording them as %s: %v", appPath, sbom.NOASSERTION, err)
+				licenses = nil
+			}
+
+			b, err = sbom.GenerateImageSPDX(version, b, obj, licenses)
 			if err != nil {
 				return nil, "", err
 			}
@@ -625,10 +662,8 @@ func appFilename(importpath string) string {
 // owner: BUILTIN/Users group: BUILTIN/Users ($sddlValue="O:BUG:BU")
 const userOwnerAndGroupSID = "AQAAgBQAAAAkAAAAAAAAAAAAAAABAgAAAAAABSAAAAAhAgAAAQIAAAAAAAUgAAAAIQIAAA=="
 
-func tarBinary(name, binary string, platform *v1.Platform, opts *layerOptions) (*bytes.Buffer, error) {
-	buf := bytes.NewBuffer(nil)
-	tw := tar.NewWriter(buf)
-	defer tw.Close()
+func tarBinary(w io.Writer, name, binary string, platform *v1.Platform, opts *layerOptions) error {
+	tw := tar.NewWriter(w)
 
 	// Write the parent directories to the tarball archive.
 	// For Windows, the layer must contain a Hives/ directory, and the root
@@ -652,18 +687,18 @@ func tarBinary(name, binary string, platform *v1.Platform, opts *layerOptions) (
 			// 0444, or 0666, none of which are executable.
 			Mode: 0555,
 		}); err != nil {
-			return nil, fmt.Errorf("writing dir %q to tar: %w", dir, err)
+			return fmt.Errorf("writing dir %q to tar: %w", dir, err)
 		}
 	}
 
 	file, err := os.Open(binary)
 	if err != nil {
-		return nil, fmt.Errorf("opening binary: %w", err)
+		return fmt.Errorf("opening binary: %w", err)
 	}
 	defer file.Close()
 	stat, err := file.Stat()
 	if err != nil {
-		return nil, err
+		return err
 	}
 	header := &tar.Header{
 		Name:     name,
@@ -684,21 +719,21 @@ func tarBinary(name, binary string, platform *v1.Platform, opts *layerOptions) (
 		if opts.linuxCapabilities != nil {
 			xattr, err := opts.linuxCapabilities.ToXattrBytes()
 			if err != nil {
-				return nil, fmt.Errorf("caps.FileCaps.ToXattrBytes: %w", err)
+				return fmt.Errorf("caps.FileCaps.ToXattrBytes: %w", err)
 			}
 			header.PAXRecords["SCHILY.xattr.security.capability"] = string(xattr)
 		}
 	}
 	// write the header to the tarball archive
 	if err := tw.WriteHeader(header); err != nil {
-		return nil, fmt.Errorf("writing tar header: %w", err)
+		return fmt.Errorf("writing tar header: %w", err)
 	}
 	// copy the file data to the tarball
 	if _, err := io.Copy(tw, file); err != nil {
-		return nil, fmt.Errorf("copying file to tar: %w", err)
+		return fmt.Errorf("copying file to tar: %w", err)
 	}
 
-	return buf, nil
+	return tw.Close()
 }
 
 func (g *gobuild) kodataPath(ref reference) (string, error) {
@@ -722,9 +757,10 @@ func (g *gobuild) kodataPath(ref reference) (string, error) {
 // Where kodata lives in the image.
 const kodataRoot = "/var/run/ko"
 
-// writeDirToTar writes a directory header to the tar writer.
-func writeDirToTar(tw *tar.Writer, name string, modTime time.Time) error {
-	return tw.WriteHeader(&tar.Header{
+// writeDirToTar writes a directory header to the tar writer, with the owner
+// and mode kw configures.
+func writeDirToTar(tw *tar.Writer, name string, modTime time.Time, kw *kodataWriter) error {
+	header := &tar.Header{
 		Name:     name,
 		Typeflag: tar.TypeDir,
 		// Use a fixed Mode, so that this isn't sensitive to the directory and umask
@@ -732,11 +768,14 @@ func writeDirToTar(tw *tar.Writer, name string, modTime time.Time) error {
 		// 0444, or 0666, none of which are executable.
 		Mode:    0555,
 		ModTime: modTime,
-	})
+	}
+	kw.setOwnership(header, os.ModeDir)
+	return tw.WriteHeader(header)
 }
 
-// writeFileToTar writes a file to the tar writer.
-func writeFileToTar(tw *tar.Writer, name, evalPath string, size int64, modTime time.Time, platform *v1.Platform) error {
+// writeFileToTar writes a file to the tar writer, with the owner and mode kw
+// configures.
+func writeFileToTar(tw *tar.Writer, name, evalPath string, info os.FileInfo, modTime time.Time, platform *v1.Platform, kw *kodataWriter) error {
 	file, err := os.Open(evalPath)
 	if err != nil {
 		return fmt.Errorf("os.Open(%q): %w", evalPath, err)
@@ -745,7 +784,7 @@ func writeFileToTar(tw *tar.Writer, name, evalPath string, size int64, modTime t
 
 	header := &tar.Header{
 		Name:     name,
-		Size:     size,
+		Size:     info.Size(),
 		Typeflag: tar.TypeReg,
 		// Use a fixed Mode, so that this isn't sensitive to the directory and umask
 		// under which it was created. Additionally, windows can only set 0222,
@@ -760,6 +799,7 @@ func writeFileToTar(tw *tar.Writer, name, evalPath string, size int64, modTime t
 			"MSWINDOWS.rawsd": userOwnerAndGroupSID,
 		}
 	}
+	kw.setOwnership(header, info.Mode())
 	if err := tw.WriteHeader(header); err != nil {
 		return fmt.Errorf("tar.Writer.WriteHeader(%q): %w", name, err)
 	}
@@ -776,8 +816,9 @@ func writeFileToTar(tw *tar.Writer, name, evalPath string, size int64, modTime t
 // symlinks are permitted to resolve; symlinks that resolve to a path outside of
 // it are rejected to prevent arbitrary host files from being packed into the
 // container image.  It defaults to the kodata root but may be widened to the
-// enclosing source tree (see resolveKodataAllowedRoot).
-func walkRecursive(tw *tar.Writer, root, chroot, absAllowedRoot string, creationTime v1.Time, platform *v1.Platform) error {
+// enclosing source tree (see resolveKodataAllowedRoot).  kw selects the files
+// that are added, and their owner and mode.
+func walkRecursive(tw *tar.Writer, root, chroot, absAllowedRoot string, creationTime v1.Time, platform *v1.Platform, kw *kodataWriter) error {
 	return filepath.Walk(root, func(hostPath string, info os.FileInfo, err error) error {
 		if hostPath == root {
 			return nil
@@ -790,11 +831,17 @@ func walkRecursive(tw *tar.Writer, root, chroot, absAllowedRoot string, creation
 
 		// Handle directories: write header and let filepath.Walk recurse.
 		if info.Mode().IsDir() {
-			if err := writeDirToTar(tw, newPath, creationTime.Time); err != nil {
+			if kw.ignored(newPath, true) {
+				return filepath.SkipDir
+			}
+			if err := kw.writeDir(tw, newPath, creationTime.Time); err != nil {
 				return fmt.Errorf("writing dir %q to tar: %w", newPath, err)
 			}
 			return nil
 		}
+		if kw.ignored(newPath, false) {
+			return nil
+		}
 
 		// Don't chase symlinks on Windows, where cross-compiled symlink support is not possible.
 		if platform.OS == "windows" {
@@ -829,26 +876,24 @@ func walkRecursive(tw *tar.Writer, root, chroot, absAllowedRoot string, creation
 
 		// Symlink target is a directory: write header and recurse.
 		if info.Mode().IsDir() {
-			if err := writeDirToTar(tw, newPath, creationTime.Time); err != nil {
+			if kw.ignored(newPath, true) {
+				return nil
+			}
+			if err := kw.writeDir(tw, newPath, creationTime.Time); err != nil {
 				return fmt.Errorf("writing dir %q to tar: %w", newPath, err)
 			}
-			return walkRecursive(tw, evalPath, newPath, absAllowedRoot, creationTime, platform)
+			return walkRecursive(tw, evalPath, newPath, absAllowedRoot, creationTime, platform, kw)
 		}
 
 		// Regular file (or symlink to file): write to tar.
-		return writeFileToTar(tw, newPath, evalPath, info.Size(), creationTime.Time, platform)
+		return kw.writeFile(tw, newPath, evalPath, info, creationTime.Time, platform)
 	})
 }
 
-func (g *gobuild) tarKoData(ref reference, platform *v1.Platform) (*bytes.Buffer, error) {
-	buf := bytes.NewBuffer(nil)
-	tw := tar.NewWriter(buf)
-	defer tw.Close()
-
-	root, err := g.kodataPath(ref)
-	if err != nil {
-		return nil, err
-	}
+// tarKoData writes a tarball of the kodata directory root for platform, as kw
+// configures.
+func (g *gobuild) tarKoData(w io.Writer, root string, platform *v1.Platform, kw *kodataWriter) error {
+	tw := tar.NewWriter(w)
 
 	creationTime := g.kodataCreationTime
 
@@ -873,8 +918,13 @@ func (g *gobuild) tarKoData(ref reference, platform *v1.Platform) (*bytes.Buffer
 		}
 	}
 	for _, dir := range dirs {
-		if err := writeDirToTar(tw, dir, creationTime.Time); err != nil {
-			return nil, fmt.Errorf("writing dir %q: %w", dir, err)
+		// Only kodata's own directory gets its owner and mode.
+		var dkw *kodataWriter
+		if dir == chroot {
+			dkw = kw
+		}
+		if err := writeDirToTar(tw, dir, creationTime.Time, dkw); err != nil {
+			return fmt.Errorf("writing dir %q: %w", dir, err)
 		}
 	}
 
@@ -887,11 +937,11 @@ func (g *gobuild) tarKoData(ref reference, platform *v1.Platform) (*bytes.Buffer
 	if _, statErr := os.Stat(root); statErr == nil {
 		resolvedRoot, err := filepath.EvalSymlinks(root)
 		if err != nil {
-			return nil, fmt.Errorf("filepath.EvalSymlinks(%q): %w", root, err)
+			return fmt.Errorf("filepath.EvalSymlinks(%q): %w", root, err)
 		}
 		absKodataRoot, err := filepath.Abs(resolvedRoot)
 		if err != nil {
-			return nil, fmt.Errorf("filepath.Abs(%q): %w", resolvedRoot, err)
+			return fmt.Errorf("filepath.Abs(%q): %w", resolvedRoot, err)
 		}
 		// Widen the boundary from the kodata directory to the enclosing source
 		// tree so that in-repo symlinks (a top-level LICENSE, shared config
@@ -899,7 +949,13 @@ func (g *gobuild) tarKoData(ref reference, platform *v1.Platform) (*bytes.Buffer
 		// escape the project entirely are still rejected.
 		absAllowedRoot = resolveKodataAllowedRoot(absKodataRoot)
 	}
-	return buf, walkRecursive(tw, root, chroot, absAllowedRoot, creationTime, platform)
+	if kw != nil {
+		kw.chroot = chroot
+	}
+	if err := walkRecursive(tw, root, chroot, absAllowedRoot, creationTime, platform, kw); err != nil {
+		return err
+	}
+	return tw.Close()
 }
 
 // resolveKodataAllowedRoot returns the directory tree within which kodata
@@ -1125,6 +1181,7 @@ func (g *gobuild) buildOne(ctx context.Context, refStr string, base v1.Image, pl
 	case types.DockerManifestSchema2:
 		layerMediaType = types.DockerLayer
 	}
+	layerMediaType = g.compression.layerMediaType(layerMediaType)
 
 	cf, err := base.ConfigFile()
 	if err != nil {
@@ -1177,6 +1234,15 @@ func (g *gobuild) buildOne(ctx context.Context, refStr string, base v1.Image, pl
 		}
 	}
 
+	if dir := os.Getenv("KOCACHE"); dir != "" {
+		dir = filepath.Clean(dir)
+		l, err := lockBinary(dir, binaryDir(dir, ref.Path(), *platform))
+		if err != nil {
+			return nil, fmt.Errorf("locking KOCACHE: %w", err)
+		}
+		defer l.Unlock()
+	}
+
 	// Do the build into a temporary file.
 	file, err := g.build(ctx, buildContext{
 		creationTime: g.creationTime,
@@ -1194,35 +1260,50 @@ func (g *gobuild) buildOne(ctx context.Context, refStr string, base v1.Image, pl
 		defer os.RemoveAll(filepath.Dir(file))
 	}
 
+	// The SBOM and the license policy share what `go version -m` says.
+	bin := &binaryInfo{file: file, env: env, dir: g.dir}
+	if g.licensePolicy != nil {
+		if err := g.checkLicenses(ctx, ref.Path(), bin); err != nil {
+			return nil, err
+		}
+	}
+
 	var layers []mutate.Addendum
 
+	lo := layerOptions{
+		compression:      g.compression,
+		compressionLevel: g.compressionLevel,
+	}
+
 	// Create a layer from the kodata directory under this import path.
-	dataLayerBuf, err := g.tarKoData(ref, platform)
+	kodata, err := g.kodataPath(ref)
 	if err != nil {
-		return nil, fmt.Errorf("tarring kodata: %w", err)
+		return nil, err
 	}
-	dataLayerBytes := dataLayerBuf.Bytes()
-	dataLayer, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
-		return io.NopCloser(bytes.NewBuffer(dataLayerBytes)), nil
-	}, tarball.WithCompressedCaching, tarball.WithMediaType(layerMediaType))
+	dataLayers, err := g.kodataLayers(kodata, platform, layerMediaType, &lo, config.Kodata)
 	if err != nil {
 		return nil, err
 	}
-	layers = append(layers, mutate.Addendum{
-		Layer: dataLayer,
-		History: v1.History{
-			Author:    "ko",
-			CreatedBy: "ko build " + ref.String(),
-			Created:   g.kodataCreationTime,
-			Comment:   "kodata contents, at $KO_DATA_PATH",
-		},
-	})
+	for i, dataLayer := range dataLayers {
+		comment := "kodata contents, at $KO_DATA_PATH"
+		if len(dataLayers) > 1 {
+			comment = fmt.Sprintf("kodata contents (%d of %d), at $KO_DATA_PATH", i+1, len(dataLayers))
+		}
+		layers = append(layers, mutate.Addendum{
+			Layer: dataLayer,
+			History: v1.History{
+				Author:    "ko",
+				CreatedBy: "ko build " + ref.String(),
+				Created:   g.kodataCreationTime,
+				Comment:   comment,
+			},
+		})
+	}
 
 	appDir := "/ko-app"
 	appFileName := appFilename(ref.Path())
 	appPath := path.Join(appDir, appFileName)
 
-	var lo layerOptions
 	lo.linuxCapabilities, err = caps.NewFileCaps(config.LinuxCapabilities...)
 	if err != nil {
 		return nil, fmt.Errorf("linux_capabilities: %w", err)
@@ -1232,14 +1313,7 @@ func (g *gobuild) buildOne(ctx context.Context, refStr string, base v1.Image, pl
 		return buildLayer(appPath, file, platform, layerMediaType, &lo)
 	}
 
-	var binaryLayer v1.Layer
-	switch {
-	case lo.linuxCapabilities != nil:
-		log.Printf("Some options prevent us from using layer cache")
-		binaryLayer, err = miss()
-	default:
-		binaryLayer, err = g.cache.get(ctx, file, miss)
-	}
+	binaryLayer, err := g.cache.get(ctx, file, &lo, miss)
 	if err != nil {
 		return nil, fmt.Errorf("cache.get(%q): %w", file, err)
 	}
@@ -1267,7 +1341,7 @@ func (g *gobuild) buildOne(ctx context.Context, refStr string, base v1.Image, pl
 		delvePath = path.Join("/ko-app", filepath.Base(delveBinary))
 
 		// add layer with delve binary
-		delveLayer, err := g.cache.get(ctx, delveBinary, func() (v1.Layer, error) {
+		delveLayer, err := g.cache.get(ctx, delveBinary, &lo, func() (v1.Layer, error) {
 			return buildLayer(delvePath, delveBinary, platform, layerMediaType, &lo)
 		})
 		if err != nil {
@@ -1300,6 +1374,10 @@ func (g *gobuild) buildOne(ctx context.Context, refStr string, base v1.Image, pl
 	if err != nil {
 		return nil, err
 	}
+	if g.compression == CompressionZstd && mt == types.DockerManifestSchema2 {
+		// zstd layers are only defined for OCI images.
+		withApp = mutate.ConfigMediaType(mutate.MediaType(withApp, types.OCIManifestSchema1), types.OCIConfigJSON)
+	}
 
 	// Start from a copy of the base image's config file, and set
 	// the entrypoint to our app.
@@ -1357,7 +1435,7 @@ func (g *gobuild) buildOne(ctx context.Context, refStr string, base v1.Image, pl
 	if g.sbom != nil {
 		// Construct a path-safe encoding of platform.
 		pf := strings.ReplaceAll(strings.ReplaceAll(platform.String(), "/", "-"), ":", "-")
-		sbom, mt, err := g.sbom(ctx, file, appPath, fmt.Sprintf("%s-%s", appFileName, pf), si, g.sbomDir)
+		sbom, mt, err := g.sbom(ctx, file, appPath, fmt.Sprintf("%s-%s", appFileName, pf), si, g.sbomDir, bin)
 		if err != nil {
 			return nil, err
 		}
@@ -1376,18 +1454,50 @@ func (g *gobuild) buildOne(ctx context.Context, refStr string, base v1.Image, pl
 // layerOptions captures additional options to apply when authoring layer
 type layerOptions struct {
 	linuxCapabilities *caps.FileCaps
+	compression       Compression
+	compressionLevel  int
 }
 
-func buildLayer(appPath, file string, platform *v1.Platform, layerMediaType types.MediaType, opts *layerOptions) (v1.Layer, error) {
-	// Construct a tarball with the binary and produce a layer.
-	binaryLayerBuf, err := tarBinary(appPath, file, platform, opts)
+// cacheKey returns a digest of the options, so that layers built from the
+// same binary with different options are cached separately.  It is empty for
+// the default options, which layers cached before there were options used.
+// Any option that changes the layer must be included.
+func (lo *layerOptions) cacheKey() (string, error) {
+	if lo == nil {
+		return "", nil
+	}
+	var b strings.Builder
+	if lo.linuxCapabilities != nil {
+		xattr, err := lo.linuxCapabilities.ToXattrBytes()
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "linuxCapabilities=%x\n", xattr)
+	}
+	if lo.compression != "" && lo.compression != CompressionGzip {
+		fmt.Fprintf(&b, "compression=%s\n", lo.compression)
+	}
+	if lo.compressionLevel != 0 {
+		fmt.Fprintf(&b, "compressionLevel=%d\n", lo.compressionLevel)
+	}
+	if b.Len() == 0 {
+		return "", nil
+	}
+	h, _, err := v1.SHA256(strings.NewReader(b.String()))
 	if err != nil {
-		return nil, fmt.Errorf("tarring binary: %w", err)
+		return "", err
 	}
-	binaryLayerBytes := binaryLayerBuf.Bytes()
-	return tarball.LayerFromOpener(func() (io.ReadCloser, error) {
-		return io.NopCloser(bytes.NewBuffer(binaryLayerBytes)), nil
-	}, tarball.WithCompressedCaching, tarball.WithMediaType(layerMediaType))
+	return h.String(), nil
+}
+
+func buildLayer(appPath, file string, platform *v1.Platform, layerMediaType types.MediaType, opts *layerOptions) (v1.Layer, error) {
+	// Construct a tarball with the binary and produce a layer.
+	return newLayer(func(w io.Writer) error {
+		if err := tarBinary(w, appPath, file, platform, opts); err != nil {
+			return fmt.Errorf("tarring binary: %w", err)
+		}
+		return nil
+	}, layerMediaType, opts)
 }
 
 // Append appPath to the PATH environment variable, if it exists. Otherwise,
@@ -1438,7 +1548,7 @@ func (g *gobuild) Build(ctx context.Context, s string) (Result, error) {
 
 		annotations := maps.Clone(g.annotations)
 		annotations[specsv1.AnnotationBaseImageDigest] = baseDigest.String()
-		annotations[specsv1.AnnotationBaseImageName] = baseRef.Name()
+		setBaseImageName(annotations, baseRef)
 		base = mutate.Annotations(base, annotations).(Result)
 	}
 
@@ -1460,6 +1570,14 @@ func (g *gobuild) Build(ctx context.Context, s string) (Result, error) {
 	}
 }
 
+// setBaseImageName records the name of the base image in annotations, if it
+// has one.
+func setBaseImageName(annotations map[string]string, baseRef name.Reference) {
+	if baseRef != nil {
+		annotations[specsv1.AnnotationBaseImageName] = baseRef.Name()
+	}
+}
+
 func (g *gobuild) buildAll(ctx context.Context, ref string, baseRef name.Reference, baseIndex v1.ImageIndex) (Result, error) {
 	im, err := baseIndex.IndexManifest()
 	if err != nil {
@@ -1492,13 +1610,13 @@ func (g *gobuild) buildAll(ctx context.Context, ref string, baseRef name.Referen
 		// Decorate the image with the ref of the index, and the matching
 		// platform's digest.
 		annotations[specsv1.AnnotationBaseImageDigest] = matches[0].Digest.String()
-		annotations[specsv1.AnnotationBaseImageName] = baseRef.Name()
+		setBaseImageName(annotations, baseRef)
 		img = mutate.Annotations(img, annotations).(v1.Image)
 		return g.buildOne(ctx, ref, img, matches[0].Platform)
 	}
 
 	annotations := maps.Clone(g.annotations)
-	annotations[specsv1.AnnotationBaseImageName] = baseRef.Name()
+	setBaseImageName(annotations, baseRef)
 	baseDigest, _ := baseIndex.Digest()
 	annotations[specsv1.AnnotationBaseImageDigest] = baseDigest.String()
 
@@ -1533,7 +1651,7 @@ func (g *gobuild) buildAll(ctx context.Context, ref string, baseRef name.Referen
 			// image we used, and we would potentially end up doing Nx more work
 			// than we really need to do.
 			annotations[specsv1.AnnotationBaseImageDigest] = desc.Digest.String()
-			annotations[specsv1.AnnotationBaseImageName] = baseRef.Name()
+			setBaseImageName(annotations, baseRef)
 
 			baseImage = mutate.Annotations(baseImage, annotations).(v1.Image)
 
@@ -1541,11 +1659,15 @@ func (g *gobuild) buildAll(ctx context.Context, ref string, baseRef name.Referen
 			if err != nil {
 				return err
 			}
+			mt, err := img.MediaType()
+			if err != nil {
+				return err
+			}
 			adds[i] = ocimutate.IndexAddendum{
 				Add: img,
 				Descriptor: v1.Descriptor{
 					URLs:        desc.URLs,
-					MediaType:   desc.MediaType,
+					MediaType:   mt,
 					Annotations: desc.Annotations,
 					Platform:    desc.Platform,
 				},
@@ -1561,6 +1683,10 @@ func (g *gobuild) buildAll(ctx context.Context, ref string, baseRef name.Referen
 	if err != nil {
 		return nil, err
 	}
+	if g.compression == CompressionZstd {
+		// zstd layers are only defined for OCI images.
+		baseType = types.OCIImageIndex
+	}
 
 	idx := ocimutate.AppendManifests(
 		mutate.Annotations(
@@ -1571,7 +1697,7 @@ func (g *gobuild) buildAll(ctx context.Context, ref string, baseRef name.Referen
 	if g.sbom != nil {
 		ref := newRef(ref)
 		appFileName := appFilename(ref.Path())
-		sbom, mt, err := g.sbom(ctx, "", "", fmt.Sprintf("%s-index", appFileName), idx, g.sbomDir)
+		sbom, mt, err := g.sbom(ctx, "", "", fmt.Sprintf("%s-index", appFileName), idx, g.sbomDir, nil)
 		if err != nil {
 			return nil, err
 		}
@@ -602,7 +602,7 @@ func nilGetBase(context.Context, string) (name.Reference, Result, error) {
 const wantSBOM = "This is our fake SBOM"
 
 // A helper method we use to substitute for the default "build" method.
-func fauxSBOM(context.Context, string, string, string, oci.SignedEntity, string) ([]byte, types.MediaType, error) {
+func fauxSBOM(context.Context, string, string, string, oci.SignedEntity, string, *binaryInfo) ([]byte, types.MediaType, error) {
 	return []byte(wantSBOM), "application/vnd.garbage", nil
 }
 
@@ -1319,6 +1319,16 @@ func TestGoBuildIndex(t *testing.T) {
 		t.Fatalf("len(Manifests()) = %v, want %v", got, want)
 	}
 
+	// The images share one kodata layer, which is only built once.
+	var kodata int
+	ng.(*gobuild).kodata.Range(func(any, any) bool {
+		kodata++
+		return true
+	})
+	if kodata != 1 {
+		t.Errorf("built %d kodata layers, wanted 1", kodata)
+	}
+
 	// Check that rebuilding the image again results in the same image digest.
 	t.Run("check determinism", func(t *testing.T) {
 		result2, err := ng.Build(context.Background(), StrictScheme+filepath.Join(importpath, "test"))
@@ -1747,7 +1757,7 @@ func TestWalkRecursiveSymlinkTraversal(t *testing.T) {
 	platform := &v1.Platform{OS: "linux", Architecture: "amd64"}
 	creationTime := v1.Time{}
 
-	err = walkRecursive(tw, kodataDir, "/var/run/ko", absKodataRoot, creationTime, platform)
+	err = walkRecursive(tw, kodataDir, "/var/run/ko", absKodataRoot, creationTime, platform, nil)
 	if err == nil {
 		t.Error("walkRecursive: expected error for symlink escaping kodata root, got nil")
 	} else if !strings.Contains(err.Error(), "outside the allowed root") {
@@ -1788,7 +1798,7 @@ func TestWalkRecursiveSymlinkWithinKodata(t *testing.T) {
 	platform := &v1.Platform{OS: "linux", Architecture: "amd64"}
 	creationTime := v1.Time{}
 
-	if err := walkRecursive(tw, kodataDir, "/var/run/ko", absKodataRoot, creationTime, platform); err != nil {
+	if err := walkRecursive(tw, kodataDir, "/var/run/ko", absKodataRoot, creationTime, platform, nil); err != nil {
 		t.Fatalf("walkRecursive: unexpected error for in-kodata symlink: %v", err)
 	}
 	tw.Close()
@@ -1863,7 +1873,7 @@ func TestWalkRecursiveSymlinkWithinAllowedRoot(t *testing.T) {
 	platform := &v1.Platform{OS: "linux", Architecture: "amd64"}
 	creationTime := v1.Time{}
 
-	if err := walkRecursive(tw, kodataDir, "/var/run/ko", absAllowedRoot, creationTime, platform); err != nil {
+	if err := walkRecursive(tw, kodataDir, "/var/run/ko", absAllowedRoot, creationTime, platform, nil); err != nil {
 		t.Fatalf("walkRecursive: unexpected error for in-repo symlink: %v", err)
 	}
 	tw.Close()
@@ -0,0 +1,276 @@
+// Copyright 2026 ko Build Authors All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package build
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/go-units"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// kodataKey identifies the kodata layers of an image.  They depend only on
+// the directory they hold, whether they are for Windows, their media type,
+// and how they are configured.
+type kodataKey struct {
+	root      string
+	windows   bool
+	mediaType types.MediaType
+	config    string
+}
+
+// kodataEntry is the kodata layers of an image, built once.
+type kodataEntry struct {
+	sync.Once
+	layers []v1.Layer
+	err    error
+}
+
+// kodataLayers returns the layers of the kodata directory root for platform,
+// as cfg configures.  They are built once, and shared by the images of every
+// platform and import path with the same kodata, which also lets registries
+// mount them instead of having them pushed again.
+func (g *gobuild) kodataLayers(root string, platform *v1.Platform, mediaType types.MediaType, lo *layerOptions, cfg KodataConfig) ([]v1.Layer, error) {
+	key := kodataKey{
+		root:      root,
+		windows:   platform.OS == "windows",
+		mediaType: mediaType,
+		config:    fmt.Sprintf("%+v", cfg),
+	}
+	v, _ := g.kodata.LoadOrStore(key, &kodataEntry{})
+	e := v.(*kodataEntry)
+	e.Do(func() {
+		e.layers, e.err = g.buildKodataLayers(root, platform, mediaType, lo, cfg)
+	})
+	return e.layers, e.err
+}
+
+func (g *gobuild) buildKodataLayers(root string, platform *v1.Platform, mediaType types.MediaType, lo *layerOptions, cfg KodataConfig) ([]v1.Layer, error) {
+	ignore, err := readKodataIgnore(root)
+	if err != nil {
+		return nil, err
+	}
+	base := kodataWriter{ignore: ignore}
+	// Windows can't use other owners and modes.
+	if platform.OS != "windows" {
+		base.uid, base.gid = cfg.UID, cfg.GID
+		base.fileMode, base.dirMode = int64(cfg.FileMode.Perm()), int64(cfg.DirMode.Perm())
+		base.preserveExec = cfg.PreserveExec
+	}
+
+	tarKoData := func(kw *kodataWriter) func(io.Writer) error {
+		return func(w io.Writer) error {
+			if err := g.tarKoData(w, root, platform, kw); err != nil {
+				return fmt.Errorf("tarring kodata: %w", err)
+			}
+			return nil
+		}
+	}
+	if len(cfg.Layers) == 0 {
+		kw := base
+		l, err := newLayer(tarKoData(&kw), mediaType, lo)
+		if err != nil {
+			return nil, err
+		}
+		return []v1.Layer{l}, nil
+	}
+
+	matchers := make([]kodataMatcher, len(cfg.Layers))
+	for i, rule := range cfg.Layers {
+		m, err := newKodataMatcher(rule)
+		if err != nil {
+			return nil, fmt.Errorf("kodata.layers[%d]: %w", i, err)
+		}
+		matchers[i] = m
+	}
+	// layerOf returns the index of the layer a file goes in.
+	layerOf := func(rel string, size int64) int {
+		for i, m := range matchers {
+			if m.matches(rel, size) {
+				return i
+			}
+		}
+		return len(matchers)
+	}
+
+	var layers []v1.Layer
+	for i := range len(matchers) + 1 {
+		kw := base
+		kw.include = func(rel string, size int64) bool {
+			return layerOf(rel, size) == i
+		}
+		// Empty directories go in the last layer, with the files that
+		// change most often.
+		kw.allDirs = i == len(matchers)
+		kw.written = map[string]bool{}
+		l, err := newLayer(tarKoData(&kw), mediaType, lo)
+		if err != nil {
+			return nil, err
+		}
+		// Rules that match nothing don't get empty layers.
+		if kw.files > 0 || kw.allDirs {
+			layers = append(layers, l)
+		}
+	}
+	return layers, nil
+}
+
+// kodataMatcher is a compiled KodataLayer.
+type kodataMatcher struct {
+	paths            []string
+	minSize, maxSize int64
+}
+
+func newKodataMatcher(rule KodataLayer) (kodataMatcher, error) {
+	m := kodataMatcher{paths: rule.Paths}
+	if len(rule.Paths) == 0 && rule.MinSize == "" && rule.MaxSize == "" {
+		return m, errors.New("one of paths, min_size or max_size must be set")
+	}
+	for _, p := range rule.Paths {
+		if _, err := path.Match(p, ""); err != nil {
+			return m, fmt.Errorf("invalid path pattern %q: %w", p, err)
+		}
+	}
+	var err error
+	if rule.MinSize != "" {
+		if m.minSize, err = units.FromHumanSize(rule.MinSize); err != nil {
+			return m, fmt.Errorf("min_size: %w", err)
+		}
+	}
+	if rule.MaxSize != "" {
+		if m.maxSize, err = units.FromHumanSize(rule.MaxSize); err != nil {
+			return m, fmt.Errorf("max_size: %w", err)
+		}
+	}
+	return m, nil
+}
+
+// matches returns whether the kodata file at rel, relative to the kodata
+// directory, with size matches the rule.
+func (m kodataMatcher) matches(rel string, size int64) bool {
+	if size < m.minSize || (m.maxSize > 0 && size >= m.maxSize) {
+		return false
+	}
+	if len(m.paths) == 0 {
+		return true
+	}
+	for _, p := range m.paths {
+		for dir := rel; dir != "."; dir = path.Dir(dir) {
+			if ok, _ := path.Match(p, dir); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// kodataWriter writes kodata files and directories to a layer's tarball.
+// A nil *kodataWriter writes everything, owned by root with mode 0555.
+type kodataWriter struct {
+	// chroot is where kodata is in the layer.
+	chroot string
+	ignore *kodataIgnore
+
+	uid, gid          int
+	fileMode, dirMode int64
+	preserveExec      bool
+
+	// include, if set, selects the files for the layer, when kodata is
+	// split into several.  Only the directories of the selected files are
+	// written, unless allDirs is set, so that a layer doesn't change when
+	// files are added outside it.
+	include func(rel string, size int64) bool
+	allDirs bool
+	written map[string]bool
+	files   int
+}
+
+func (kw *kodataWriter) rel(name string) string {
+	return strings.TrimPrefix(name, kw.chroot+"/")
+}
+
+// ignored returns whether the file, or directory if isDir, name is left out.
+func (kw *kodataWriter) ignored(name string, isDir bool) bool {
+	if kw == nil {
+		return false
+	}
+	return kw.ignore.ignored(kw.rel(name), isDir)
+}
+
+// writeDir writes the directory name, or leaves it to writeFile.
+func (kw *kodataWriter) writeDir(tw *tar.Writer, name string, modTime time.Time) error {
+	if kw != nil && kw.include != nil {
+		if !kw.allDirs {
+			return nil
+		}
+		kw.written[name] = true
+	}
+	return writeDirToTar(tw, name, modTime, kw)
+}
+
+// writeFile writes the file name, and the directories it is in if need be,
+// if it is selected.
+func (kw *kodataWriter) writeFile(tw *tar.Writer, name, evalPath string, info os.FileInfo, modTime time.Time, platform *v1.Platform) error {
+	if kw != nil && kw.include != nil {
+		if !kw.include(kw.rel(name), info.Size()) {
+			return nil
+		}
+		var dirs []string
+		for dir := path.Dir(name); dir != kw.chroot && !kw.written[dir]; dir = path.Dir(dir) {
+			dirs = append(dirs, dir)
+		}
+		for i := len(dirs) - 1; i >= 0; i-- {
+			if err := writeDirToTar(tw, dirs[i], modTime, kw); err != nil {
+				return fmt.Errorf("writing dir %q to tar: %w", dirs[i], err)
+			}
+			kw.written[dirs[i]] = true
+		}
+		kw.files++
+	}
+	return writeFileToTar(tw, name, evalPath, info, modTime, platform, kw)
+}
+
+// setOwnership sets the owner and mode of the kodata file or directory h,
+// whose mode on the host is hostMode.
+func (kw *kodataWriter) setOwnership(h *tar.Header, hostMode os.FileMode) {
+	if kw == nil {
+		return
+	}
+	h.Uid, h.Gid = kw.uid, kw.gid
+	if h.Typeflag == tar.TypeDir {
+		if kw.dirMode != 0 {
+			h.Mode = kw.dirMode
+		}
+		return
+	}
+	if kw.fileMode != 0 {
+		h.Mode = kw.fileMode
+	}
+	if kw.preserveExec {
+		h.Mode &^= 0o111
+		if hostMode&0o111 != 0 {
+			h.Mode |= (h.Mode & 0o444) >> 2
+		}
+	}
+}
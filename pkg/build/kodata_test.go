@@ -0,0 +1,277 @@
+// Copyright 2026 ko Build Authors All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package build
+
+import (
+	"archive/tar"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+func TestKodataMatcher(t *testing.T) {
+	for _, c := range []struct {
+		rule KodataLayer
+		rel  string
+		size int64
+		want bool
+	}{
+		{KodataLayer{Paths: []string{"fonts"}}, "fonts/a/b.ttf", 1, true},
+		{KodataLayer{Paths: []string{"fonts"}}, "fontsx/b.ttf", 1, false},
+		{KodataLayer{Paths: []string{"*.png"}}, "img/logo.png", 1, false},
+		{KodataLayer{Paths: []string{"img/*.png"}}, "img/logo.png", 1, true},
+		{KodataLayer{Paths: []string{"static/*"}}, "static/css/site.css", 1, true},
+		{KodataLayer{MinSize: "1kB"}, "a", 999, false},
+		{KodataLayer{MinSize: "1kB"}, "a", 1000, true},
+		{KodataLayer{MaxSize: "1kB"}, "a", 999, true},
+		{KodataLayer{MaxSize: "1kB"}, "a", 1000, false},
+		{KodataLayer{Paths: []string{"img"}, MinSize: "1kB"}, "img/small.png", 10, false},
+	} {
+		m, err := newKodataMatcher(c.rule)
+		if err != nil {
+			t.Fatalf("newKodataMatcher(%+v) = %v", c.rule, err)
+		}
+		if got := m.matches(c.rel, c.size); got != c.want {
+			t.Errorf("%+v matches(%q, %d) = %t, wanted %t", c.rule, c.rel, c.size, got, c.want)
+		}
+	}
+
+	for _, rule := range []KodataLayer{
+		{},
+		{Paths: []string{"["}},
+		{MinSize: "big"},
+	} {
+		if _, err := newKodataMatcher(rule); err == nil {
+			t.Errorf("newKodataMatcher(%+v) = nil, wanted an error", rule)
+		}
+	}
+}
+
+func TestKodataLayers(t *testing.T) {
+	root := t.TempDir()
+	writeFile := func(rel, content string) {
+		t.Helper()
+		p := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(p), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile("fonts/a.ttf", strings.Repeat("a", 2000))
+	writeFile("img/logo.png", strings.Repeat("b", 2000))
+	writeFile("img/icon.png", "c")
+	writeFile("app.js", "d")
+	if err := os.Mkdir(filepath.Join(root, "empty"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	rules := []KodataLayer{
+		{Paths: []string{"fonts"}},
+		{MinSize: "1kB"},
+		{Paths: []string{"unused"}},
+	}
+	build := func() []v1.Layer {
+		t.Helper()
+		g := &gobuild{kodata: &sync.Map{}}
+		layers, err := g.kodataLayers(root, &v1.Platform{OS: "linux"}, types.OCILayer, &layerOptions{}, KodataConfig{Layers: rules})
+		if err != nil {
+			t.Fatalf("kodataLayers() = %v", err)
+		}
+		return layers
+	}
+
+	layers := build()
+	var got [][]string
+	for _, l := range layers {
+		got = append(got, layerFiles(t, l))
+	}
+	want := [][]string{{
+		"/var", "/var/run", "/var/run/ko",
+		"/var/run/ko/fonts", "/var/run/ko/fonts/a.ttf",
+	}, {
+		"/var", "/var/run", "/var/run/ko",
+		"/var/run/ko/img", "/var/run/ko/img/logo.png",
+	}, {
+		"/var", "/var/run", "/var/run/ko",
+		"/var/run/ko/app.js", "/var/run/ko/empty", "/var/run/ko/fonts", "/var/run/ko/img", "/var/run/ko/img/icon.png",
+	}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("kodataLayers() files (-want +got): %s", diff)
+	}
+
+	// Changing the files of the last layer, or adding directories, leaves
+	// the others as they were.
+	writeFile("app.js", "e")
+	writeFile("new/file.js", "f")
+	again := build()
+	for i := range 2 {
+		if d1, d2 := mustLayerDigest(t, layers[i]), mustLayerDigest(t, again[i]); d1 != d2 {
+			t.Errorf("layer %d changed from %s to %s", i, d1, d2)
+		}
+	}
+	if d1, d2 := mustLayerDigest(t, layers[2]), mustLayerDigest(t, again[2]); d1 == d2 {
+		t.Errorf("last layer unchanged at %s", d1)
+	}
+}
+
+func TestKodataOwnership(t *testing.T) {
+	root := t.TempDir()
+	for _, f := range []struct {
+		rel  string
+		mode os.FileMode
+	}{
+		{"data/config.json", 0o644},
+		{"bin/tool", 0o755},
+		{"data/.DS_Store", 0o644},
+		{".kodataignore", 0o644},
+	} {
+		p := filepath.Join(root, filepath.FromSlash(f.rel))
+		if err := os.MkdirAll(filepath.Dir(p), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(".DS_Store\n"), f.mode); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(p, f.mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, c := range []struct {
+		desc     string
+		platform string
+		cfg      KodataConfig
+		want     map[string]int64
+		wantUID  int
+	}{{
+		desc:     "defaults",
+		platform: "linux",
+		want: map[string]int64{
+			"/var/run/ko":                  0o555,
+			"/var/run/ko/bin":              0o555,
+			"/var/run/ko/bin/tool":         0o555,
+			"/var/run/ko/data":             0o555,
+			"/var/run/ko/data/config.json": 0o555,
+		},
+	}, {
+		desc:     "configured",
+		platform: "linux",
+		cfg:      KodataConfig{UID: 65532, GID: 65532, FileMode: 0o644, DirMode: 0o775, PreserveExec: true},
+		want: map[string]int64{
+			"/var/run/ko":                  0o775,
+			"/var/run/ko/bin":              0o775,
+			"/var/run/ko/bin/tool":         0o755,
+			"/var/run/ko/data":             0o775,
+			"/var/run/ko/data/config.json": 0o644,
+		},
+		wantUID: 65532,
+	}, {
+		desc:     "preserve exec",
+		platform: "linux",
+		cfg:      KodataConfig{PreserveExec: true},
+		want: map[string]int64{
+			"/var/run/ko":                  0o555,
+			"/var/run/ko/bin":              0o555,
+			"/var/run/ko/bin/tool":         0o555,
+			"/var/run/ko/data":             0o555,
+			"/var/run/ko/data/config.json": 0o444,
+		},
+	}, {
+		desc:     "windows ignores ownership",
+		platform: "windows",
+		cfg:      KodataConfig{UID: 65532, FileMode: 0o644},
+		want: map[string]int64{
+			"Files/var/run/ko":                  0o555,
+			"Files/var/run/ko/bin":              0o555,
+			"Files/var/run/ko/bin/tool":         0o555,
+			"Files/var/run/ko/data":             0o555,
+			"Files/var/run/ko/data/config.json": 0o555,
+		},
+	}} {
+		t.Run(c.desc, func(t *testing.T) {
+			g := &gobuild{kodata: &sync.Map{}}
+			layers, err := g.kodataLayers(root, &v1.Platform{OS: c.platform}, types.OCILayer, &layerOptions{}, c.cfg)
+			if err != nil {
+				t.Fatalf("kodataLayers() = %v", err)
+			}
+			rc, err := layers[0].Uncompressed()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rc.Close()
+			got := map[string]int64{}
+			tr := tar.NewReader(rc)
+			for {
+				hdr, err := tr.Next()
+				if err == io.EOF {
+					break
+				} else if err != nil {
+					t.Fatal(err)
+				}
+				if !strings.Contains(hdr.Name, "ko") {
+					continue
+				}
+				got[hdr.Name] = hdr.Mode
+				if hdr.Uid != c.wantUID {
+					t.Errorf("%s: uid = %d, wanted %d", hdr.Name, hdr.Uid, c.wantUID)
+				}
+			}
+			if diff := cmp.Diff(c.want, got); diff != "" {
+				t.Errorf("modes (-want +got): %s", diff)
+			}
+		})
+	}
+}
+
+func layerFiles(t *testing.T, l v1.Layer) []string {
+	t.Helper()
+	rc, err := l.Uncompressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	var names []string
+	tr := tar.NewReader(rc)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, hdr.Name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func mustLayerDigest(t *testing.T, l v1.Layer) v1.Hash {
+	t.Helper()
+	d, err := l.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
@@ -0,0 +1,154 @@
+// Copyright 2026 ko Build Authors All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package build
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// kodataIgnoreFile is the file in the kodata directory that lists the files
+// to leave out of the image, as a .gitignore file does.
+const kodataIgnoreFile = ".kodataignore"
+
+// ignorePattern is a compiled line of a .kodataignore file.
+type ignorePattern struct {
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// kodataIgnore matches the kodata files that a .kodataignore file leaves out.
+type kodataIgnore struct {
+	patterns []ignorePattern
+}
+
+// readKodataIgnore reads the .kodataignore file in the kodata directory root,
+// returning nil if there isn't one.
+func readKodataIgnore(root string) (*kodataIgnore, error) {
+	b, err := os.ReadFile(filepath.Join(root, kodataIgnoreFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return parseKodataIgnore(b)
+}
+
+// parseKodataIgnore parses the contents of a .kodataignore file, which has
+// the syntax of a .gitignore file.
+func parseKodataIgnore(b []byte) (*kodataIgnore, error) {
+	ki := &kodataIgnore{}
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimRight(s.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var p ignorePattern
+		if strings.HasPrefix(line, "!") {
+			p.negate = true
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			p.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		re, err := compileIgnorePattern(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", kodataIgnoreFile, n, err)
+		}
+		p.re = re
+		ki.patterns = append(ki.patterns, p)
+	}
+	return ki, s.Err()
+}
+
+// compileIgnorePattern returns a regular expression matching the paths,
+// relative to the kodata directory, that the .gitignore pattern p matches.
+func compileIgnorePattern(p string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	// Patterns with a slash are relative to the kodata directory, and
+	// others match at any depth.
+	if strings.Contains(p, "/") {
+		p = strings.TrimPrefix(p, "/")
+	} else {
+		b.WriteString("(?:.*/)?")
+	}
+	for i := 0; i < len(p); i++ {
+		switch c := p[i]; {
+		case strings.HasPrefix(p[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case p[i:] == "/**":
+			b.WriteString("/.*")
+			i += 2
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(p[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := p[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(p):
+			i++
+			b.WriteString(regexp.QuoteMeta(p[i : i+1]))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+// ignored returns whether the file, or directory if isDir, at rel, relative
+// to the kodata directory, is left out.  As with .gitignore files, the last
+// pattern that matches decides, and the .kodataignore file itself is always
+// left out.
+func (ki *kodataIgnore) ignored(rel string, isDir bool) bool {
+	if rel == kodataIgnoreFile {
+		return true
+	}
+	if ki == nil {
+		return false
+	}
+	ignored := false
+	for _, p := range ki.patterns {
+		if p.dirOnly && !isDir {
+			continue
+		}
+		if p.re.MatchString(rel) {
+			ignored = !p.negate
+		}
+	}
+	return ignored
+}
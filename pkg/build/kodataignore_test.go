@@ -0,0 +1,72 @@
+// Copyright 2026 ko Build Authors All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package build
+
+import "testing"
+
+func TestKodataIgnore(t *testing.T) {
+	ki, err := parseKodataIgnore([]byte(`
+# Editor and OS files.
+.DS_Store
+*.sw[a-p]
+*~
+
+/build/
+docs/**/*.md
+!docs/README.md
+logs/
+\#notes
+`))
+	if err != nil {
+		t.Fatalf("parseKodataIgnore() = %v", err)
+	}
+	for _, c := range []struct {
+		rel   string
+		isDir bool
+		want  bool
+	}{
+		{".DS_Store", false, true},
+		{"img/.DS_Store", false, true},
+		{"index.html", false, false},
+		{"js/.app.js.swp", false, true},
+		{"js/app.js~", false, true},
+		{"build", true, true},
+		{"build", false, false},
+		{"web/build", true, false},
+		{"docs/guide.md", false, true},
+		{"docs/api/guide.md", false, true},
+		{"docs/README.md", false, false},
+		{"docs/guide.txt", false, false},
+		{"logs", true, true},
+		{"a/logs", true, true},
+		{"logs", false, false},
+		{"#notes", false, true},
+		{".kodataignore", false, true},
+	} {
+		if got := ki.ignored(c.rel, c.isDir); got != c.want {
+			t.Errorf("ignored(%q, %t) = %t, wanted %t", c.rel, c.isDir, got, c.want)
+		}
+	}
+
+	// Without a .kodataignore file, only it would be left out.
+	var none *kodataIgnore
+	if none.ignored("index.html", false) || !none.ignored(".kodataignore", false) {
+		t.Error("nil kodataIgnore ignores the wrong files")
+	}
+
+	if _, err := parseKodataIgnore([]byte("a\n[\n")); err != nil {
+		t.Errorf("parseKodataIgnore() with an unclosed [ = %v, wanted it matched literally", err)
+	}
+}
@@ -49,6 +49,13 @@ func (l *lazyLayer) MediaType() (types.MediaType, error) {
 	return l.desc.MediaType, nil
 }
 
+// Descriptor returns the cached descriptor, which carries the annotations,
+// such as eStargz's, that the layer was built with.
+func (l *lazyLayer) Descriptor() (*v1.Descriptor, error) {
+	desc := l.desc
+	return &desc, nil
+}
+
 // This is only called if the registry doesn't have this blob already.
 func (l *lazyLayer) Compressed() (io.ReadCloser, error) {
 	layer, err := l.compute()
@@ -0,0 +1,172 @@
+// Copyright 2026 ko Build Authors All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package build
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/google/ko/internal/license"
+	"github.com/google/ko/internal/sbom"
+)
+
+// binaryInfo is what `go version -m` says about a built binary, with the
+// licenses of the modules linked into it.  Each is looked up at most once,
+// and only if the SBOM or the license policy uses it.
+type binaryInfo struct {
+	file string
+	env  []string
+	dir  string
+
+	versionOnce sync.Once
+	versionM    []byte
+	deps        []*debug.Module
+	mainPath    string
+	versionErr  error
+
+	licenseOnce sync.Once
+	licenses    map[string]string
+	licenseErr  error
+}
+
+// goVersionM returns the output of `go version -m` for the binary.
+func (b *binaryInfo) goVersionM(ctx context.Context) ([]byte, error) {
+	b.versionOnce.Do(func() {
+		out, err := runGoVersionM(ctx, b.file)
+		if err != nil {
+			b.versionErr = err
+			return
+		}
+		bi, err := sbom.ParseGoVersionM(out)
+		if err != nil {
+			b.versionErr = fmt.Errorf("parsing go version -m %s: %w", b.file, err)
+			return
+		}
+		b.versionM, b.deps, b.mainPath = out, bi.Deps, bi.Main.Path
+	})
+	return b.versionM, b.versionErr
+}
+
+// detectLicenses returns the license of each module linked into the binary,
+// keyed by module path.
+func (b *binaryInfo) detectLicenses(ctx context.Context) (map[string]string, error) {
+	b.licenseOnce.Do(func() {
+		b.licenses, b.licenseErr = b.detect(ctx)
+	})
+	return b.licenses, b.licenseErr
+}
+
+func (b *binaryInfo) detect(ctx context.Context) (map[string]string, error) {
+	if _, err := b.goVersionM(ctx); err != nil {
+		return nil, err
+	}
+	modCache, err := goModCache(ctx, b.env)
+	if err != nil {
+		return nil, err
+	}
+	mainDir, err := mainModuleDir(ctx, b.env, b.dir, b.mainPath)
+	if err != nil {
+		return nil, err
+	}
+	return license.Detect(modCache, mainDir, b.deps)
+}
+
+// checkLicenses checks the licenses of the modules linked into the binary
+// against the license policy.
+func (g *gobuild) checkLicenses(ctx context.Context, ip string, bin *binaryInfo) error {
+	licenses, err := bin.detectLicenses(ctx)
+	if err != nil {
+		return fmt.Errorf("detecting module licenses of %s: %w", ip, err)
+	}
+	if err := g.licensePolicy.check(bin.deps, licenses); err != nil {
+		return fmt.Errorf("license policy violation for %s: %w", ip, err)
+	}
+	return nil
+}
+
+// check returns an error listing each module whose license is not permitted
+// by the policy.
+func (p *LicensePolicy) check(mods []*debug.Module, licenses map[string]string) error {
+	var violations []string
+	for _, mod := range mods {
+		lic := licenses[mod.Path]
+		if lic == "" || lic == license.Unknown {
+			if len(p.Allow) > 0 {
+				violations = append(violations, fmt.Sprintf("%s@%s: license could not be detected", mod.Path, mod.Version))
+			}
+			continue
+		}
+		for _, id := range license.IDs(lic) {
+			switch {
+			case containsFold(p.Deny, id):
+				violations = append(violations, fmt.Sprintf("%s@%s: %s is denied", mod.Path, mod.Version, id))
+			case len(p.Allow) > 0 && !containsFold(p.Allow, id):
+				violations = append(violations, fmt.Sprintf("%s@%s: %s is not allowed", mod.Path, mod.Version, id))
+			}
+		}
+	}
+	if len(violations) > 0 {
+		return fmt.Errorf("%d module license(s) rejected:\n\t%s", len(violations), strings.Join(violations, "\n\t"))
+	}
+	return nil
+}
+
+// containsFold reports whether ids contains id, ignoring case as SPDX
+// identifiers are matched case-insensitively.
+func containsFold(ids []string, id string) bool {
+	return slices.ContainsFunc(ids, func(s string) bool {
+		return strings.EqualFold(s, id)
+	})
+}
+
+// goModCache returns the module cache directory used for a build with the
+// given environment.
+func goModCache(ctx context.Context, env []string) (string, error) {
+	gobin := getGoBinary()
+	cmd := exec.CommandContext(ctx, gobin, "env", "GOMODCACHE")
+	cmd.Env = env
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("go env GOMODCACHE: %w: %s", err, stderr.String())
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// mainModuleDir returns the directory of the main module, at path, of a build
+// in dir with the given environment.  Without a main module, it is dir.
+func mainModuleDir(ctx context.Context, env []string, dir, path string) (string, error) {
+	if path == "" {
+		return dir, nil
+	}
+	gobin := getGoBinary()
+	cmd := exec.CommandContext(ctx, gobin, "list", "-m", "-f", "{{.Dir}}", path)
+	cmd.Dir = dir
+	cmd.Env = env
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("go list -m %s: %w: %s", path, err, stderr.String())
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
@@ -0,0 +1,160 @@
+// Copyright 2026 ko Build Authors All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package build
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/sigstore/cosign/v3/pkg/oci/signed"
+
+	"github.com/google/ko/internal/license"
+)
+
+func TestLicensePolicyCheck(t *testing.T) {
+	mods := []*debug.Module{
+		{Path: "example.com/apache", Version: "v1.0.0"},
+		{Path: "example.com/gpl", Version: "v1.0.0"},
+		{Path: "example.com/unknown", Version: "v1.0.0"},
+	}
+	licenses := map[string]string{
+		"example.com/apache":  "Apache-2.0",
+		"example.com/gpl":     "GPL-3.0-only",
+		"example.com/unknown": license.Unknown,
+	}
+
+	for _, c := range []struct {
+		desc   string
+		policy LicensePolicy
+		want   []string
+	}{{
+		desc:   "empty policy",
+		policy: LicensePolicy{},
+	}, {
+		desc:   "deny",
+		policy: LicensePolicy{Deny: []string{"gpl-3.0-only"}},
+		want:   []string{"example.com/gpl@v1.0.0: GPL-3.0-only is denied"},
+	}, {
+		desc:   "allow",
+		policy: LicensePolicy{Allow: []string{"Apache-2.0"}},
+		want: []string{
+			"example.com/gpl@v1.0.0: GPL-3.0-only is not allowed",
+			"example.com/unknown@v1.0.0: license could not be detected",
+		},
+	}, {
+		desc:   "allow everything detected",
+		policy: LicensePolicy{Allow: []string{"Apache-2.0", "GPL-3.0-only"}},
+		want:   []string{"example.com/unknown@v1.0.0: license could not be detected"},
+	}} {
+		t.Run(c.desc, func(t *testing.T) {
+			err := c.policy.check(mods, licenses)
+			if len(c.want) == 0 {
+				if err != nil {
+					t.Fatalf("check() = %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("check() = nil, wanted error")
+			}
+			for _, w := range c.want {
+				if !strings.Contains(err.Error(), w) {
+					t.Errorf("check() = %v, wanted %q", err, w)
+				}
+			}
+			if got := strings.Count(err.Error(), "\n"); got != len(c.want) {
+				t.Errorf("check() reported %d violations, wanted %d: %v", got, len(c.want), err)
+			}
+		})
+	}
+}
+
+func TestBinaryInfoRelativeReplace(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds a Go binary")
+	}
+	tmp := t.TempDir()
+	app := filepath.Join(tmp, "app")
+	files := map[string]string{
+		"app/go.mod":          "module example.com/app\n\ngo 1.21\n\nrequire example.com/dep v0.0.0\n\nreplace example.com/dep => ../dep\n",
+		"app/cmd/app/main.go": "package main\n\nimport \"example.com/dep\"\n\nfunc main() { dep.Hello() }\n",
+		"dep/go.mod":          "module example.com/dep\n\ngo 1.21\n",
+		"dep/dep.go":          "package dep\n\nfunc Hello() {}\n",
+		"dep/LICENSE":         "Permission is hereby granted, free of charge, to any person obtaining a copy of this software.\n",
+	}
+	for name, content := range files {
+		p := filepath.Join(tmp, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	env := append(os.Environ(), "GOFLAGS=", "GOWORK=off")
+	file := filepath.Join(tmp, "app.bin")
+	cmd := exec.Command("go", "build", "-o", file, "./cmd/app")
+	cmd.Dir = app
+	cmd.Env = env
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go build: %v\n%s", err, out)
+	}
+
+	// The build runs from a subdirectory of the main module, so the replace
+	// must be resolved against the directory holding go.mod.
+	ctx := context.Background()
+	bin := &binaryInfo{
+		file: file,
+		env:  env,
+		dir:  filepath.Join(app, "cmd", "app"),
+	}
+	licenses, err := bin.detectLicenses(ctx)
+	if err != nil {
+		t.Fatalf("detectLicenses() = %v", err)
+	}
+	if got, want := licenses["example.com/dep"], "MIT"; got != want {
+		t.Errorf("licenses[example.com/dep] = %q, want %q", got, want)
+	}
+
+	// When the licenses can't be detected, the SBOM records NOASSERTION
+	// instead, unless a license policy needs them.
+	bin = &binaryInfo{
+		file: file,
+		env:  env,
+		dir:  filepath.Join(tmp, "missing"),
+	}
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _, err := spdx("devel")(ctx, file, "/ko-app/app", "", signed.Image(img), "", bin)
+	if err != nil {
+		t.Fatalf("spdx() = %v", err)
+	}
+	if !strings.Contains(string(b), `"name": "example.com/dep"`) {
+		t.Errorf("spdx() = %s, wanted a package for example.com/dep", b)
+	}
+	g := &gobuild{licensePolicy: &LicensePolicy{Allow: []string{"MIT"}}}
+	if err := g.checkLicenses(ctx, "example.com/app/cmd/app", bin); err == nil {
+		t.Error("checkLicenses() = nil, wanted an error")
+	}
+}
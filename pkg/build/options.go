@@ -219,3 +219,26 @@ func WithDebugger() Option {
 		return nil
 	}
 }
+
+// WithLicensePolicy is a functional option for checking the licenses of the
+// modules linked into each binary against the given policy.  Detected
+// licenses are also recorded in the generated SBOMs.
+func WithLicensePolicy(p LicensePolicy) Option {
+	return func(gbo *gobuildOpener) error {
+		gbo.licensePolicy = &p
+		return nil
+	}
+}
+
+// WithCompression is a functional option for how the layers ko builds are
+// compressed, and at which level, or the default level if zero.
+func WithCompression(c Compression, level int) Option {
+	return func(gbo *gobuildOpener) error {
+		if _, err := ParseCompression(string(c), level); err != nil {
+			return err
+		}
+		gbo.compression = c
+		gbo.compressionLevel = level
+		return nil
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2026 ko Build Authors All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package build
+
+import (
+	"io"
+	"os"
+	"runtime"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// spool writes what write writes to a new temporary file, and returns it
+// and its size.  The caller must remove it with removeTemp.
+func spool(write func(io.Writer) error) (*os.File, int64, error) {
+	f, err := os.CreateTemp("", "ko-layer-*")
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := write(f); err != nil {
+		removeTemp(f)
+		return nil, 0, err
+	}
+	size, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		removeTemp(f)
+		return nil, 0, err
+	}
+	return f, size, nil
+}
+
+func removeTemp(f *os.File) {
+	f.Close()
+	os.Remove(f.Name())
+}
+
+func closeTemp(f *os.File) {
+	f.Close()
+}
+
+// blobFile is the temporary file that a layer built by blobLayer reads.
+type blobFile struct {
+	f *os.File
+}
+
+// blobLayer returns a layer of the compressed blob that r reads, which is
+// spooled to a temporary file.  The layer's digests are computed from the
+// file as needed, and it is read again whenever the layer is, so that
+// layers take no memory however large they are.
+//
+// The file is closed and removed when the layer is no longer used.  Where
+// open files can be removed, it is removed straight away, so that it can't
+// outlive ko.
+func blobLayer(r io.Reader, mediaType types.MediaType) (v1.Layer, error) {
+	f, size, err := spool(func(w io.Writer) error {
+		_, err := io.Copy(w, r)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	bf := &blobFile{f: f}
+	if err := os.Remove(f.Name()); err != nil {
+		runtime.AddCleanup(bf, removeTemp, f)
+	} else {
+		runtime.AddCleanup(bf, closeTemp, f)
+	}
+	return tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		return io.NopCloser(io.NewSectionReader(bf.f, 0, size)), nil
+	}, tarball.WithMediaType(mediaType))
+}
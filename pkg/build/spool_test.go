@@ -0,0 +1,104 @@
+// Copyright 2026 ko Build Authors All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package build
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+)
+
+func TestNewLayerSpoolsToDisk(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	t.Setenv("TMP", tmp)
+
+	content := bytes.Repeat([]byte("kodata"), 1<<16)
+	write := func(w io.Writer) error {
+		tw := tar.NewWriter(w)
+		if err := tw.WriteHeader(&tar.Header{
+			Name:     "data",
+			Typeflag: tar.TypeReg,
+			Mode:     0o555,
+			Size:     int64(len(content)),
+		}); err != nil {
+			return err
+		}
+		if _, err := tw.Write(content); err != nil {
+			return err
+		}
+		return tw.Close()
+	}
+
+	for _, c := range []Compression{CompressionGzip, CompressionZstd, CompressionEstargz} {
+		t.Run(string(c), func(t *testing.T) {
+			mt := c.layerMediaType(types.OCILayer)
+			l, err := newLayer(write, mt, &layerOptions{compression: c})
+			if err != nil {
+				t.Fatalf("newLayer() = %v", err)
+			}
+			// validate.Layer only knows gzip.
+			if c != CompressionZstd {
+				if err := validate.Layer(l); err != nil {
+					t.Errorf("validate.Layer() = %v", err)
+				}
+			}
+
+			// The layer can be read more than once.
+			for range 2 {
+				rc, err := l.Uncompressed()
+				if err != nil {
+					t.Fatal(err)
+				}
+				tr := tar.NewReader(rc)
+				var got []byte
+				for {
+					hdr, err := tr.Next()
+					if err == io.EOF {
+						break
+					} else if err != nil {
+						t.Fatal(err)
+					}
+					if hdr.Name == "data" {
+						if got, err = io.ReadAll(tr); err != nil {
+							t.Fatal(err)
+						}
+					}
+				}
+				rc.Close()
+				if !bytes.Equal(got, content) {
+					t.Errorf("layer has %d bytes of data, wanted %d", len(got), len(content))
+				}
+			}
+		})
+	}
+
+	// Nothing is left in the temporary directory.
+	if runtime.GOOS != "windows" {
+		entries, err := os.ReadDir(tmp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range entries {
+			t.Errorf("left behind %s", e.Name())
+		}
+	}
+}
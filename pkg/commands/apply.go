@@ -77,7 +77,10 @@ func addApply(topLevel *cobra.Command) {
 			if err != nil {
 				return fmt.Errorf("error creating builder: %w", err)
 			}
-			publisher, err := makePublisher(po)
+			if err := po.LoadConfig(); err != nil {
+				return fmt.Errorf("error creating publisher: %w", err)
+			}
+			publisher, err := makePublisher(ctx, po)
 			if err != nil {
 				return fmt.Errorf("error creating publisher: %w", err)
 			}
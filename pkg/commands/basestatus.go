@@ -0,0 +1,414 @@
+// Copyright 2026 ko Build Authors All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"unicode"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/spf13/cobra"
+	"go.yaml.in/yaml/v4"
+)
+
+// addBaseStatus augments our CLI surface with base-status.
+func addBaseStatus(topLevel *cobra.Command) {
+	var (
+		filenames []string
+		output    string
+		insecure  bool
+	)
+
+	baseStatus := &cobra.Command{
+		Use:   "base-status [IMAGE...]",
+		Short: "Report images built by ko whose base image has been updated.",
+		Long: `This sub-command checks whether the base image name recorded in images built by ko now points to a different image, and reports which images (per platform) are outdated and should be rebased or rebuilt.
+
+Images are given as arguments, or read from files with -f. Files may be the output of "ko resolve" (any image reference with a digest is checked, as is the value of any "image" field), or files written with --image-refs.`,
+		Example: `
+  # Check the images deployed from the output of ko resolve.
+  ko resolve -f config/ > release.yaml
+  ko base-status -f release.yaml
+
+  # Check the images recorded with --image-refs, as JSON.
+  ko build --image-refs=refs.txt ./cmd/...
+  ko base-status -f refs.txt --output=json
+
+  # Check the given images.
+  ko base-status registry.example.com/app@sha256:...`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if output != "table" && output != "json" {
+				return fmt.Errorf("unsupported --output %q, must be table or json", output)
+			}
+			refs := slices.Clone(args)
+			for _, f := range filenames {
+				var b []byte
+				var err error
+				if f == "-" {
+					b, err = io.ReadAll(os.Stdin)
+				} else {
+					b, err = os.ReadFile(f)
+				}
+				if err != nil {
+					return err
+				}
+				found, err := imageRefsFromYAML(b)
+				if err != nil {
+					return fmt.Errorf("reading image references from %s: %w", f, err)
+				}
+				refs = append(refs, found...)
+			}
+			if len(refs) == 0 {
+				return errors.New("no images given; pass image references as arguments or with -f")
+			}
+
+			c := &baseChecker{
+				ropt: []remote.Option{
+					remote.WithAuthFromKeychain(keychain),
+					remote.WithUserAgent(ua()),
+					remote.WithContext(cmd.Context()),
+				},
+			}
+			if insecure {
+				c.nopt = append(c.nopt, name.Insecure)
+			}
+			statuses, err := c.check(refs)
+			if err != nil {
+				return err
+			}
+			if output == "json" {
+				enc := json.NewEncoder(cmd.OutOrStdout())
+				enc.SetIndent("", "  ")
+				return enc.Encode(statuses)
+			}
+			return writeBaseStatusTable(cmd.OutOrStdout(), statuses)
+		},
+	}
+	baseStatus.Flags().StringSliceVarP(&filenames, "filename", "f", nil,
+		"Files to read image references from, or - for stdin (may be repeated)")
+	baseStatus.Flags().StringVarP(&output, "output", "o", "table",
+		"Output format, one of: table, json")
+	baseStatus.Flags().BoolVar(&insecure, "insecure-registry", false,
+		"Whether to skip TLS verification on the registry")
+	topLevel.AddCommand(baseStatus)
+}
+
+// imageRefsFromYAML returns the image references found in the given
+// (possibly multi-document) YAML.  Any word with a digest is a reference, as
+// is the value of any field named "image".  This
+// covers both the output of `ko resolve` and files written by --image-refs.
+func imageRefsFromYAML(b []byte) ([]string, error) {
+	var refs []string
+	add := func(ref string) {
+		if !slices.Contains(refs, ref) {
+			refs = append(refs, ref)
+		}
+	}
+	var walk func(n *yaml.Node)
+	walk = func(n *yaml.Node) {
+		switch n.Kind {
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				k, v := n.Content[i], n.Content[i+1]
+				if k.Value == "image" && v.Kind == yaml.ScalarNode {
+					if _, err := name.ParseReference(v.Value); err == nil {
+						add(v.Value)
+						continue
+					}
+				}
+				walk(v)
+			}
+		case yaml.ScalarNode:
+			// Split flags like --image=<ref> as well as lines.
+			words := strings.FieldsFunc(n.Value, func(r rune) bool {
+				return r == '=' || unicode.IsSpace(r)
+			})
+			for _, word := range words {
+				if _, err := name.NewDigest(word); err == nil {
+					add(word)
+				}
+			}
+		default:
+			for _, c := range n.Content {
+				walk(c)
+			}
+		}
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(b))
+	for {
+		var doc yaml.Node
+		if err := decoder.Decode(&doc); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		walk(&doc)
+	}
+	return refs, nil
+}
+
+// baseStatus describes whether the base image of an image (or one platform
+// of an index) is up to date.
+type baseStatus struct {
+	Image    string `json:"image"`
+	Platform string `json:"platform,omitempty"`
+	Base     string `json:"base"`
+	Digest   string `json:"digest"`
+	Latest   string `json:"latest"`
+	Outdated bool   `json:"outdated"`
+}
+
+func writeBaseStatusTable(w io.Writer, statuses []baseStatus) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "IMAGE\tPLATFORM\tBASE\tSTATUS")
+	for _, s := range statuses {
+		status := "up to date"
+		if s.Outdated {
+			status = "outdated"
+		}
+		platform := s.Platform
+		if platform == "" {
+			platform = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", s.Image, platform, s.Base, status)
+	}
+	return tw.Flush()
+}
+
+// baseChecker checks the base images of images built by ko.  Base images
+// are looked up once per name, using a HEAD request when possible.
+type baseChecker struct {
+	ropt []remote.Option
+	nopt []name.Option
+
+	heads   map[string]*v1.Descriptor
+	indexes map[string]*v1.IndexManifest
+}
+
+// head returns the descriptor that the base image name points to.
+func (c *baseChecker) head(ref name.Reference) (*v1.Descriptor, error) {
+	if d, ok := c.heads[ref.Name()]; ok {
+		return d, nil
+	}
+	d, err := remote.Head(ref, c.ropt...)
+	if err != nil {
+		return nil, fmt.Errorf("fetching base image %s: %w", ref, err)
+	}
+	if c.heads == nil {
+		c.heads = map[string]*v1.Descriptor{}
+	}
+	c.heads[ref.Name()] = d
+	return d, nil
+}
+
+// index returns the index manifest that the base image name points to.
+func (c *baseChecker) index(ref name.Reference) (*v1.IndexManifest, error) {
+	if im, ok := c.indexes[ref.Name()]; ok {
+		return im, nil
+	}
+	idx, err := remote.Index(ref, c.ropt...)
+	if err != nil {
+		return nil, fmt.Errorf("fetching base image %s: %w", ref, err)
+	}
+	im, err := idx.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+	if c.indexes == nil {
+		c.indexes = map[string]*v1.IndexManifest{}
+	}
+	c.indexes[ref.Name()] = im
+	return im, nil
+}
+
+// latest returns the digest of the image that the base image name points to
+// for the given platform.  The index is only fetched when the name no longer
+// points to the recorded digest, since index updates often leave the image
+// for a given platform unchanged.
+func (c *baseChecker) latest(baseRef name.Reference, recorded v1.Hash, platform *v1.Platform) (v1.Hash, error) {
+	d, err := c.head(baseRef)
+	if err != nil {
+		return v1.Hash{}, err
+	}
+	if d.Digest == recorded || !d.MediaType.IsIndex() {
+		return d.Digest, nil
+	}
+	im, err := c.index(baseRef)
+	if err != nil {
+		return v1.Hash{}, err
+	}
+	pd, err := platformDescriptor(im, platform)
+	if err != nil {
+		return v1.Hash{}, fmt.Errorf("base image %s: %w", baseRef, err)
+	}
+	return pd.Digest, nil
+}
+
+// check returns the base image status of each image in refs, and of each
+// platform of the indexes in refs.  Images that are part of an index in
+// refs (as recorded by --image-refs) are only reported once.
+func (c *baseChecker) check(refs []string) ([]baseStatus, error) {
+	type input struct {
+		ref  name.Reference
+		desc *remote.Descriptor
+	}
+	var inputs []input
+	children := map[v1.Hash]bool{}
+	seen := map[v1.Hash]bool{}
+	for _, s := range refs {
+		ref, err := name.ParseReference(s, c.nopt...)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %q: %w", s, err)
+		}
+		desc, err := remote.Get(ref, c.ropt...)
+		if err != nil {
+			return nil, fmt.Errorf("fetching %s: %w", ref, err)
+		}
+		if seen[desc.Digest] {
+			continue
+		}
+		seen[desc.Digest] = true
+		if desc.MediaType.IsIndex() {
+			im, err := v1.ParseIndexManifest(bytes.NewReader(desc.Manifest))
+			if err != nil {
+				return nil, err
+			}
+			for _, m := range im.Manifests {
+				children[m.Digest] = true
+			}
+		}
+		inputs = append(inputs, input{ref: ref, desc: desc})
+	}
+
+	var statuses []baseStatus
+	for _, in := range inputs {
+		if children[in.desc.Digest] {
+			continue
+		}
+		var s []baseStatus
+		var err error
+		if in.desc.MediaType.IsIndex() {
+			s, err = c.checkIndex(in.ref, in.desc)
+		} else {
+			s, err = c.checkImage(in.ref, in.desc)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("checking %s: %w", in.ref, err)
+		}
+		statuses = append(statuses, s...)
+	}
+	return statuses, nil
+}
+
+func (c *baseChecker) checkImage(ref name.Reference, desc *remote.Descriptor) ([]baseStatus, error) {
+	img, err := desc.Image()
+	if err != nil {
+		return nil, err
+	}
+	m, err := img.Manifest()
+	if err != nil {
+		return nil, err
+	}
+	baseRef, recorded, err := baseAnnotations(m.Annotations, c.nopt...)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := img.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	platform := cfg.Platform()
+	latest, err := c.latest(baseRef, recorded, platform)
+	if err != nil {
+		return nil, err
+	}
+	s := baseStatus{
+		Image:    ref.Context().Digest(desc.Digest.String()).String(),
+		Base:     baseRef.Name(),
+		Digest:   recorded.String(),
+		Latest:   latest.String(),
+		Outdated: latest != recorded,
+	}
+	if platform != nil {
+		s.Platform = platform.String()
+	}
+	return []baseStatus{s}, nil
+}
+
+func (c *baseChecker) checkIndex(ref name.Reference, desc *remote.Descriptor) ([]baseStatus, error) {
+	idx, err := desc.ImageIndex()
+	if err != nil {
+		return nil, err
+	}
+	im, err := idx.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+	baseRef, recorded, err := baseAnnotations(im.Annotations, c.nopt...)
+	if err != nil {
+		return nil, err
+	}
+	head, err := c.head(baseRef)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]baseStatus, 0, len(im.Manifests))
+	for _, cd := range im.Manifests {
+		img, err := idx.Image(cd.Digest)
+		if err != nil {
+			return nil, err
+		}
+		m, err := img.Manifest()
+		if err != nil {
+			return nil, err
+		}
+		_, childRecorded, err := baseAnnotations(m.Annotations, c.nopt...)
+		if err != nil {
+			return nil, fmt.Errorf("image for %s: %w", cd.Platform, err)
+		}
+		// When the index is unchanged, so is the image for each platform.
+		latest := childRecorded
+		if head.Digest != recorded {
+			latest, err = c.latest(baseRef, childRecorded, cd.Platform)
+			if err != nil {
+				return nil, err
+			}
+		}
+		s := baseStatus{
+			Image:    ref.Context().Digest(desc.Digest.String()).String(),
+			Base:     baseRef.Name(),
+			Digest:   childRecorded.String(),
+			Latest:   latest.String(),
+			Outdated: latest != childRecorded,
+		}
+		if cd.Platform != nil {
+			s.Platform = cd.Platform.String()
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses, nil
+}
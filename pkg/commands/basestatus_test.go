@@ -0,0 +1,207 @@
+// Copyright 2026 ko Build Authors All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"io"
+	"log"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	specsv1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestImageRefsFromYAML(t *testing.T) {
+	const (
+		d1 = "registry.example.com/app@sha256:1111111111111111111111111111111111111111111111111111111111111111"
+		d2 = "registry.example.com/sidecar@sha256:2222222222222222222222222222222222222222222222222222222222222222"
+	)
+	for _, c := range []struct {
+		desc, in string
+		want     []string
+	}{{
+		desc: "ko resolve output",
+		in: `apiVersion: apps/v1
+kind: Deployment
+spec:
+  template:
+    spec:
+      containers:
+      - name: a
+        image: ` + d1 + `
+      - name: b
+        image: registry.example.com/sidecar:v1
+        args: ["--sidecar=` + d2 + `"]
+---
+apiVersion: v1
+kind: ConfigMap
+data:
+  image: not a reference
+  other: ` + d2 + `
+`,
+		want: []string{d1, "registry.example.com/sidecar:v1", d2},
+	}, {
+		desc: "image refs file",
+		in:   d1 + "\n" + d2 + "\n",
+		want: []string{d1, d2},
+	}} {
+		t.Run(c.desc, func(t *testing.T) {
+			got, err := imageRefsFromYAML([]byte(c.in))
+			if err != nil {
+				t.Fatalf("imageRefsFromYAML() = %v", err)
+			}
+			if diff := cmp.Diff(c.want, got); diff != "" {
+				t.Errorf("imageRefsFromYAML() (-want +got) = %s", diff)
+			}
+		})
+	}
+}
+
+var (
+	amd64 = &v1.Platform{OS: "linux", Architecture: "amd64"}
+	arm64 = &v1.Platform{OS: "linux", Architecture: "arm64"}
+)
+
+// platformIndex returns an index of the given images for amd64 and arm64.
+func platformIndex(t *testing.T, amd, arm v1.Image) v1.ImageIndex {
+	t.Helper()
+	return mutate.AppendManifests(empty.Index,
+		mutate.IndexAddendum{Add: amd, Descriptor: v1.Descriptor{Platform: amd64}},
+		mutate.IndexAddendum{Add: arm, Descriptor: v1.Descriptor{Platform: arm64}},
+	)
+}
+
+// koImage returns an image with the base image annotations that ko adds.
+func koImage(t *testing.T, baseName string, baseDigest v1.Hash, platform *v1.Platform) v1.Image {
+	t.Helper()
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := img.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg = cfg.DeepCopy()
+	cfg.OS, cfg.Architecture = platform.OS, platform.Architecture
+	img, err = mutate.ConfigFile(img, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mutate.Annotations(img, map[string]string{
+		specsv1.AnnotationBaseImageName:   baseName,
+		specsv1.AnnotationBaseImageDigest: baseDigest.String(),
+	}).(v1.Image)
+}
+
+func TestBaseStatus(t *testing.T) {
+	s := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	defer s.Close()
+	reg := s.Listener.Addr().String()
+	baseName := reg + "/base:latest"
+
+	mustWrite := func(ref string, r interface{ Digest() (v1.Hash, error) }) name.Reference {
+		t.Helper()
+		tag, err := name.NewTag(ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch r := r.(type) {
+		case v1.ImageIndex:
+			err = remote.WriteIndex(tag, r)
+		case v1.Image:
+			err = remote.Write(tag, r)
+		}
+		if err != nil {
+			t.Fatalf("writing %s: %v", tag, err)
+		}
+		return tag
+	}
+
+	baseAMD, baseARM := mustRandom(), mustRandom()
+	baseIndex := platformIndex(t, baseAMD, baseARM)
+	mustWrite(baseName, baseIndex)
+	baseIndexDigest, err := baseIndex.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// An index built on the base index, and an image built on a single
+	// platform of it.
+	appAMD := koImage(t, baseName, mustDigest(baseAMD), amd64)
+	appARM := koImage(t, baseName, mustDigest(baseARM), arm64)
+	appIndex := mutate.Annotations(platformIndex(t, appAMD, appARM), map[string]string{
+		specsv1.AnnotationBaseImageName:   baseName,
+		specsv1.AnnotationBaseImageDigest: baseIndexDigest.String(),
+	}).(v1.ImageIndex)
+	indexRef := mustWrite(reg+"/app:latest", appIndex)
+	indexDigest, err := appIndex.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	single := koImage(t, baseName, mustDigest(baseARM), arm64)
+	singleRef := mustWrite(reg+"/single:latest", single)
+
+	indexImage := reg + "/app@" + indexDigest.String()
+	singleImage := reg + "/single@" + mustDigest(single).String()
+	// Children of the index, as recorded by --image-refs, are not reported
+	// separately.
+	childRef := reg + "/app@" + mustDigest(appAMD).String()
+
+	c := &baseChecker{}
+	got, err := c.check([]string{indexRef.String(), childRef, singleRef.String()})
+	if err != nil {
+		t.Fatalf("check() = %v", err)
+	}
+	want := []baseStatus{{
+		Image: indexImage, Platform: "linux/amd64", Base: baseName,
+		Digest: mustDigest(baseAMD).String(), Latest: mustDigest(baseAMD).String(),
+	}, {
+		Image: indexImage, Platform: "linux/arm64", Base: baseName,
+		Digest: mustDigest(baseARM).String(), Latest: mustDigest(baseARM).String(),
+	}, {
+		Image: singleImage, Platform: "linux/arm64", Base: baseName,
+		Digest: mustDigest(baseARM).String(), Latest: mustDigest(baseARM).String(),
+	}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("check() (-want +got) = %s", diff)
+	}
+
+	// Update the base image for amd64 only.
+	newAMD := mustRandom()
+	mustWrite(baseName, platformIndex(t, newAMD, baseARM))
+
+	c = &baseChecker{}
+	got, err = c.check([]string{indexRef.String(), singleRef.String()})
+	if err != nil {
+		t.Fatalf("check() = %v", err)
+	}
+	want[0].Latest = mustDigest(newAMD).String()
+	want[0].Outdated = true
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("check() (-want +got) = %s", diff)
+	}
+	if n := len(c.indexes); n != 1 {
+		t.Errorf("fetched %d base indexes, wanted 1", n)
+	}
+}
@@ -72,7 +72,10 @@ func addBuild(topLevel *cobra.Command) {
 			if err != nil {
 				return fmt.Errorf("error creating builder: %w", err)
 			}
-			publisher, err := makePublisher(po)
+			if err := po.LoadConfig(); err != nil {
+				return fmt.Errorf("error creating publisher: %w", err)
+			}
+			publisher, err := makePublisher(ctx, po)
 			if err != nil {
 				return fmt.Errorf("error creating publisher: %w", err)
 			}
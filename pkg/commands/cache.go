@@ -17,11 +17,16 @@ package commands
 import (
 	"bytes"
 	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/google/go-containerregistry/pkg/logs"
 	"github.com/google/go-containerregistry/pkg/name"
@@ -33,32 +38,48 @@ import (
 	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/google/go-containerregistry/pkg/v1/types"
 	"github.com/google/ko/pkg/build"
+	"github.com/google/ko/pkg/internal/atomicfile"
+	"github.com/google/ko/pkg/internal/flock"
 )
 
+// tagsFile is the name of the file in the on-disk cache that records the
+// digest each tag last resolved to, so that tags can be resolved offline.
+const tagsFile = "tags.json"
+
 type imageCache struct {
 	// In memory
 	cache sync.Map
 
 	// On disk
+	p *layout.Path
+	// keys has a *sync.Mutex per digest, so that each is only fetched once
+	// by this process; the lock files in keyLocksDir do the same across ko
+	// processes.
+	keys sync.Map
+	// mu, along with the lock file in the layout, guards changes to
+	// index.json and tags.json.
 	mu sync.Mutex
-	p  *layout.Path
 
 	// Over the network
 	puller *remote.Puller
+
+	// offline serves everything from the on-disk cache, and fails instead
+	// of going to the network.
+	offline bool
 }
 
-func newCache(puller *remote.Puller) (*imageCache, error) {
+func newCache(puller *remote.Puller, offline bool) (*imageCache, error) {
 	cache := &imageCache{
-		puller: puller,
+		puller:  puller,
+		offline: offline,
 	}
 	if kc := os.Getenv("KOCACHE"); kc != "" {
-		path := filepath.Join(kc, "img")
-		p, err := layout.FromPath(path)
+		if err := holdCacheLock(kc); err != nil {
+			return cache, err
+		}
+		p, err := openLayout(filepath.Join(kc, "img"))
 		if err != nil {
-			p, err = layout.Write(path, empty.Index)
-			if err != nil {
-				return cache, err
-			}
+			return cache, err
 		}
 		cache.p = &p
 	}
@@ -66,7 +87,269 @@ func newCache(puller *remote.Puller) (*imageCache, error) {
 	return cache, nil
 }
 
+// cacheLockFile is the name of the file in KOCACHE that ko processes hold a
+// shared lock on while they use the cache, and that "ko cache prune" holds
+// an exclusive lock on.
+const cacheLockFile = ".lock"
+
+// holdCacheLock holds a shared lock on KOCACHE for the rest of the process.
+func holdCacheLock(dir string) error {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+	return flock.Hold(filepath.Join(dir, cacheLockFile))
+}
+
+// layoutLockFile is the name of the file in the on-disk cache that ko
+// processes lock while they change index.json or tags.json.
+const layoutLockFile = ".lock"
+
+// openLayout opens the on-disk cache at path, creating it if it doesn't
+// exist yet.
+func openLayout(path string) (layout.Path, error) {
+	if p, err := layout.FromPath(path); err == nil {
+		return p, nil
+	}
+	if err := os.MkdirAll(path, os.ModePerm); err != nil {
+		return "", err
+	}
+	l, err := flock.Exclusive(filepath.Join(path, layoutLockFile))
+	if err != nil {
+		return "", err
+	}
+	defer l.Unlock()
+
+	// Another ko process may have created it while we waited.
+	if p, err := layout.FromPath(path); err == nil {
+		return p, nil
+	}
+	if err := atomicfile.WriteFile(filepath.Join(path, "oci-layout"), []byte(`{"imageLayoutVersion":"1.0.0"}`), 0o644); err != nil {
+		return "", err
+	}
+	index, err := empty.Index.RawManifest()
+	if err != nil {
+		return "", err
+	}
+	if err := atomicfile.WriteFile(filepath.Join(path, "index.json"), index, 0o644); err != nil {
+		return "", err
+	}
+	return layout.Path(path), nil
+}
+
+// lockLayout takes the lock that guards changes to index.json and tags.json,
+// both within this process and across ko processes, and returns the function
+// that releases it.
+func (i *imageCache) lockLayout() (func(), error) {
+	i.mu.Lock()
+	l, err := flock.Exclusive(filepath.Join(string(*i.p), layoutLockFile))
+	if err != nil {
+		i.mu.Unlock()
+		return nil, err
+	}
+	return func() {
+		if err := l.Unlock(); err != nil {
+			logs.Debug.Printf("unlocking image cache: %v", err)
+		}
+		i.mu.Unlock()
+	}, nil
+}
+
+// keyLocksDir is the directory of the on-disk cache with a lock file per
+// digest, which ko processes lock while they fetch and store the digest.
+const keyLocksDir = "locks"
+
+// keyLockPath returns the path of the lock file of h in the on-disk cache
+// at dir.
+func keyLockPath(dir string, h v1.Hash) string {
+	return filepath.Join(dir, keyLocksDir, h.Algorithm, h.Hex+".lock")
+}
+
+// lockKey takes the lock for a digest, both within this process and across
+// ko processes, and returns the function that releases it.
+func (i *imageCache) lockKey(h v1.Hash) (func(), error) {
+	v, _ := i.keys.LoadOrStore(h.String(), &sync.Mutex{})
+	mu := v.(*sync.Mutex)
+	mu.Lock()
+	path := keyLockPath(string(*i.p), h)
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		mu.Unlock()
+		return nil, err
+	}
+	l, err := flock.Exclusive(path)
+	if err != nil {
+		mu.Unlock()
+		return nil, err
+	}
+	return func() {
+		if err := l.Unlock(); err != nil {
+			logs.Debug.Printf("unlocking %s: %v", h, err)
+		}
+		mu.Unlock()
+	}, nil
+}
+
+// notCached returns the error for a ref that is needed offline but isn't in
+// the on-disk cache.
+func (i *imageCache) notCached(ref name.Reference) error {
+	return fmt.Errorf("%s is not in the image cache at %s; run \"ko cache warm\" while online to fetch it", ref, *i.p)
+}
+
+// readTags returns the tag to digest mapping of the on-disk cache.
+func (i *imageCache) readTags() (map[string]string, error) {
+	tags := map[string]string{}
+	b, err := os.ReadFile(filepath.Join(string(*i.p), tagsFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return tags, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &tags); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", tagsFile, err)
+	}
+	return tags, nil
+}
+
+// resolveTag returns the digest that ref last resolved to.
+func (i *imageCache) resolveTag(ref name.Reference) (string, error) {
+	tags, err := i.readTags()
+	if err != nil {
+		return "", err
+	}
+	dig, ok := tags[ref.Name()]
+	if !ok {
+		return "", i.notCached(ref)
+	}
+	return dig, nil
+}
+
+// recordTag records that ref resolved to dig.
+func (i *imageCache) recordTag(ref name.Reference, dig string) error {
+	unlock, err := i.lockLayout()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	tags, err := i.readTags()
+	if err != nil {
+		return err
+	}
+	if tags[ref.Name()] == dig {
+		return nil
+	}
+	tags[ref.Name()] = dig
+	b, err := json.MarshalIndent(tags, "", "  ")
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(filepath.Join(string(*i.p), tagsFile), b, 0o644)
+}
+
+// appendDescriptor adds desc to index.json, unless another ko process
+// already has.
+func (i *imageCache) appendDescriptor(desc v1.Descriptor) error {
+	unlock, err := i.lockLayout()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	ii, err := i.p.ImageIndex()
+	if err != nil {
+		return err
+	}
+	index, err := ii.IndexManifest()
+	if err != nil {
+		return err
+	}
+	for _, d := range index.Manifests {
+		if d.Digest == desc.Digest {
+			return nil
+		}
+	}
+	index.Manifests = append(index.Manifests, desc)
+	b, err := json.MarshalIndent(index, "", "   ")
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(filepath.Join(string(*i.p), "index.json"), b, 0o644)
+}
+
+// writeBlob writes the blob h to the on-disk cache, unless it's already
+// there.  Blobs are written under a temporary name and renamed once their
+// digest is verified, so other ko processes never see a partial blob.
+func (i *imageCache) writeBlob(h v1.Hash, rc io.ReadCloser) error {
+	defer rc.Close()
+	path := filepath.Join(string(*i.p), "blobs", h.Algorithm, h.Hex)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	hasher, err := v1.Hasher(h.Algorithm)
+	if err != nil {
+		return err
+	}
+	return atomicfile.Write(path, io.TeeReader(rc, hasher), 0o644, func() error {
+		if got := hex.EncodeToString(hasher.Sum(nil)); got != h.Hex {
+			return fmt.Errorf("blob %s has digest %s:%s", h, h.Algorithm, got)
+		}
+		return nil
+	})
+}
+
+// materialize writes the layers of img that aren't already in the on-disk
+// cache, so that img can be used offline.
+func (i *imageCache) materialize(img v1.Image) error {
+	layers, err := img.Layers()
+	if err != nil {
+		return err
+	}
+	for _, l := range layers {
+		h, err := l.Digest()
+		if err != nil {
+			return err
+		}
+		if rc, err := i.p.Blob(h); err == nil {
+			rc.Close()
+			continue
+		}
+		rc, err := l.Compressed()
+		if err != nil {
+			return fmt.Errorf("fetching layer %s: %w", h, err)
+		}
+		if err := i.writeBlob(h, rc); err != nil {
+			return fmt.Errorf("writing layer %s: %w", h, err)
+		}
+	}
+	return nil
+}
+
+// checkLayers returns an error if a layer of img isn't in the on-disk cache,
+// so that offline builds fail when they get the base image rather than part
+// way through the build.
+func (i *imageCache) checkLayers(ref name.Reference, img v1.Image) error {
+	m, err := img.Manifest()
+	if err != nil {
+		return err
+	}
+	for _, desc := range m.Layers {
+		_, err := os.Stat(filepath.Join(string(*i.p), "blobs", desc.Digest.Algorithm, desc.Digest.Hex))
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("layer %s of %s is not in KOCACHE at %s; run \"ko cache warm\" while online to fetch it", desc.Digest, ref, *i.p)
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (i *imageCache) get(ctx context.Context, ref name.Reference, missFunc baseFactory) (build.Result, error) {
+	if i.offline && i.p == nil {
+		return nil, errors.New("offline builds require KOCACHE to be set")
+	}
+
 	if v, ok := i.cache.Load(ref.String()); ok {
 		logs.Debug.Printf("cache hit: %s", ref.String())
 
@@ -90,6 +373,12 @@ func (i *imageCache) get(ctx context.Context, ref name.Reference, missFunc baseF
 		key := ""
 		if _, ok := ref.(name.Digest); ok {
 			key = ref.Identifier()
+		} else if i.offline {
+			dig, err := i.resolveTag(ref)
+			if err != nil {
+				return nil, err
+			}
+			key = dig
 		} else {
 			logs.Debug.Printf("cache miss due to tag: %s", ref.String())
 			result, err := miss(ctx, ref)
@@ -103,21 +392,27 @@ func (i *imageCache) get(ctx context.Context, ref name.Reference, missFunc baseF
 			}
 
 			key = dig.String()
+			if err := i.recordTag(ref, key); err != nil {
+				return result, err
+			}
 		}
 
-		// Use a pretty broad lock on the on-disk cache to avoid races.
-		i.mu.Lock()
-		defer i.mu.Unlock()
-
-		ii, err := i.p.ImageIndex()
+		h, err := v1.NewHash(key)
 		if err != nil {
-			return nil, fmt.Errorf("loading cache index: %w", err)
+			return nil, err
 		}
 
-		h, err := v1.NewHash(key)
+		// Only fetch and store each digest once.
+		unlock, err := i.lockKey(h)
 		if err != nil {
 			return nil, err
 		}
+		defer unlock()
+
+		ii, err := i.p.ImageIndex()
+		if err != nil {
+			return nil, fmt.Errorf("loading cache index: %w", err)
+		}
 		descs, err := partial.FindManifests(ii, match.Digests(h))
 		if err != nil {
 			return nil, err
@@ -126,6 +421,12 @@ func (i *imageCache) get(ctx context.Context, ref name.Reference, missFunc baseF
 			logs.Debug.Printf("cache hit: %s", ref.String())
 			desc := descs[0]
 
+			// Record the use, for "ko cache prune".
+			now := time.Now()
+			if err := os.Chtimes(filepath.Join(string(*i.p), "blobs", h.Algorithm, h.Hex), now, now); err != nil {
+				logs.Debug.Printf("recording use of %s: %v", ref, err)
+			}
+
 			var br build.Result
 			if desc.MediaType.IsIndex() {
 				idx, err := ii.ImageIndex(h)
@@ -145,12 +446,21 @@ func (i *imageCache) get(ctx context.Context, ref name.Reference, missFunc baseF
 				if err != nil {
 					return nil, err
 				}
+				if i.offline {
+					if err := i.checkLayers(ref, img); err != nil {
+						return nil, err
+					}
+				}
 			}
 			i.cache.Store(ref.String(), br)
 			return br, nil
 		}
 	}
 
+	if i.offline {
+		return nil, i.notCached(ref)
+	}
+
 	logs.Debug.Printf("cache miss: %s", ref.String())
 	result, err := miss(ctx, ref)
 	if err != nil {
@@ -170,7 +480,7 @@ func (i *imageCache) get(ctx context.Context, ref name.Reference, missFunc baseF
 			return result, err
 		}
 
-		if err := i.p.WriteBlob(desc.Digest, io.NopCloser(bytes.NewReader(manifest))); err != nil {
+		if err := i.writeBlob(desc.Digest, io.NopCloser(bytes.NewReader(manifest))); err != nil {
 			return result, err
 		}
 
@@ -193,7 +503,7 @@ func (i *imageCache) get(ctx context.Context, ref name.Reference, missFunc baseF
 				return result, err
 			}
 
-			if err := i.p.WriteBlob(id, io.NopCloser(bytes.NewReader(cf))); err != nil {
+			if err := i.writeBlob(id, io.NopCloser(bytes.NewReader(cf))); err != nil {
 				return result, err
 			}
 
@@ -208,7 +518,7 @@ func (i *imageCache) get(ctx context.Context, ref name.Reference, missFunc baseF
 			}
 		}
 
-		if err := i.p.AppendDescriptor(*desc); err != nil {
+		if err := i.appendDescriptor(*desc); err != nil {
 			return result, err
 		}
 	}
@@ -457,6 +767,9 @@ func (l *lazyLayer) Compressed() (io.ReadCloser, error) {
 	if rc, err := l.cache.p.Blob(l.desc.Digest); err == nil {
 		return rc, nil
 	}
+	if l.cache.offline {
+		return nil, l.cache.notCached(l.ref)
+	}
 
 	rl, err := l.cache.puller.Layer(context.TODO(), l.ref)
 	if err != nil {
@@ -0,0 +1,347 @@
+// Copyright 2026 ko Build Authors All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+
+	"github.com/google/ko/pkg/commands/options"
+	"github.com/google/ko/pkg/internal/flock"
+)
+
+func TestOfflineCache(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("KOCACHE", t.TempDir())
+
+	s, err := registryServerWithImage("base")
+	if err != nil {
+		t.Fatalf("could not create test registry server: %v", err)
+	}
+	reg := s.Listener.Addr().String()
+	baseImage := reg + "/multi:latest"
+	tag, err := name.NewTag(baseImage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	amd, arm := mustRandom(), mustRandom()
+	if err := remote.WriteIndex(tag, platformIndex(t, amd, arm)); err != nil {
+		t.Fatal(err)
+	}
+	dig, err := crane.Digest(baseImage)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bo := &options.BuildOptions{
+		BaseImage:          baseImage,
+		BaseImageOverrides: map[string]string{"example.com/single": reg + "/base"},
+		WorkingDirectory:   t.TempDir(),
+	}
+	if err := warmCache(ctx, bo, []string{"linux/arm64"}); err != nil {
+		t.Fatalf("warmCache() = %v", err)
+	}
+
+	// Nothing is fetched from the registry from here on.
+	s.Close()
+
+	bo.Offline = true
+	_, res, err := getBaseImage(bo)(ctx, "ko://example.com/helloworld")
+	if err != nil {
+		t.Fatalf("getBaseImage() = %v", err)
+	}
+	if got, err := res.Digest(); err != nil || got.String() != dig {
+		t.Errorf("Digest() = %v, %v; wanted %s", got, err, dig)
+	}
+	idx, ok := res.(v1.ImageIndex)
+	if !ok {
+		t.Fatalf("getBaseImage() = %T, wanted an index", res)
+	}
+
+	// The layers of the warmed platform are cached.
+	readLayers := func(h v1.Hash) error {
+		img, err := idx.Image(h)
+		if err != nil {
+			return err
+		}
+		layers, err := img.Layers()
+		if err != nil {
+			return err
+		}
+		for _, l := range layers {
+			rc, err := l.Compressed()
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(io.Discard, rc); err != nil {
+				return err
+			}
+			rc.Close()
+		}
+		return nil
+	}
+	if err := readLayers(mustDigest(arm)); err != nil {
+		t.Errorf("reading linux/arm64 layers: %v", err)
+	}
+	if err := readLayers(mustDigest(amd)); err == nil || !strings.Contains(err.Error(), "ko cache warm") {
+		t.Errorf("reading linux/amd64 layers = %v, wanted it to not be cached", err)
+	}
+
+	// Images with a layer missing from the cache fail when they are loaded.
+	layers, err := arm.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	lh := mustLayerDigest(t, layers[0])
+	if err := os.Remove(filepath.Join(os.Getenv("KOCACHE"), "img", "blobs", lh.Algorithm, lh.Hex)); err != nil {
+		t.Fatal(err)
+	}
+	_, res, err = getBaseImage(bo)(ctx, "ko://example.com/helloworld")
+	if err != nil {
+		t.Fatalf("getBaseImage() = %v", err)
+	}
+	if _, err := res.(v1.ImageIndex).Image(mustDigest(arm)); err == nil || !strings.Contains(err.Error(), "is not in KOCACHE") {
+		t.Errorf("Image(linux/arm64) = %v, wanted its missing layer to fail it", err)
+	}
+
+	if _, _, err := getBaseImage(bo)(ctx, "ko://example.com/single"); err != nil {
+		t.Errorf("getBaseImage() = %v", err)
+	}
+
+	bo.BaseImage = reg + "/other:latest"
+	if _, _, err := getBaseImage(bo)(ctx, "ko://example.com/helloworld"); err == nil || !strings.Contains(err.Error(), "is not in the image cache") {
+		t.Errorf("getBaseImage() = %v, wanted uncached base to fail", err)
+	}
+}
+
+func TestCacheKeyLock(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("KOCACHE", t.TempDir())
+
+	s, err := registryServerWithImage("base")
+	if err != nil {
+		t.Fatalf("could not create test registry server: %v", err)
+	}
+	defer s.Close()
+	img := mustRandom()
+	tag, err := name.NewTag(s.Listener.Addr().String() + "/base:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(tag, img); err != nil {
+		t.Fatal(err)
+	}
+	ref := tag.Digest(mustDigest(img).String())
+
+	puller, err := remote.NewPuller()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache, err := newCache(puller, false)
+	if err != nil {
+		t.Fatalf("newCache() = %v", err)
+	}
+
+	// Another ko process is fetching the image.
+	path := keyLockPath(string(*cache.p), mustDigest(img))
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	l, err := flock.Exclusive(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error)
+	go func() {
+		_, err := cache.get(ctx, ref, fetchRemote([]remote.Option{remote.Reuse(puller)}))
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("get() = %v while another process held the lock of the digest", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if err := l.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("get() = %v", err)
+	}
+}
+
+// cacheWorkerEnv makes TestCacheConcurrentProcesses act as one of the ko
+// processes sharing a KOCACHE, fetching the space-separated base images it
+// is set to.
+const cacheWorkerEnv = "KO_TEST_CACHE_WORKER"
+
+func TestCacheConcurrentProcesses(t *testing.T) {
+	if refs := os.Getenv(cacheWorkerEnv); refs != "" {
+		cacheWorker(t, strings.Fields(refs))
+		return
+	}
+	if testing.Short() {
+		t.Skip("skipping stress test in short mode")
+	}
+
+	s, err := registryServerWithImage("base")
+	if err != nil {
+		t.Fatalf("could not create test registry server: %v", err)
+	}
+	defer s.Close()
+	reg := s.Listener.Addr().String()
+	refs := []string{reg + "/base:latest"}
+	for i := range 4 {
+		tag, err := name.NewTag(fmt.Sprintf("%s/multi%d:latest", reg, i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := remote.WriteIndex(tag, platformIndex(t, mustRandom(), mustRandom())); err != nil {
+			t.Fatal(err)
+		}
+		refs = append(refs, tag.String())
+	}
+
+	kocache := t.TempDir()
+	const workers = 8
+	cmds := make([]*exec.Cmd, workers)
+	outs := make([]bytes.Buffer, workers)
+	for i := range workers {
+		// Each process fetches the images in a different order.
+		order := append(refs[i%len(refs):], refs[:i%len(refs)]...)
+		cmd := exec.Command(os.Args[0], "-test.run=^TestCacheConcurrentProcesses$")
+		cmd.Env = append(os.Environ(), "KOCACHE="+kocache, cacheWorkerEnv+"="+strings.Join(order, " "))
+		cmd.Stdout, cmd.Stderr = &outs[i], &outs[i]
+		if err := cmd.Start(); err != nil {
+			t.Fatal(err)
+		}
+		cmds[i] = cmd
+	}
+	for i, cmd := range cmds {
+		if err := cmd.Wait(); err != nil {
+			t.Errorf("worker %d: %v\n%s", i, err, outs[i].String())
+		}
+	}
+
+	// Every image is recorded once, and all of it is intact.
+	p, err := layout.FromPath(filepath.Join(kocache, "img"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ii, err := p.ImageIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	im, err := ii.IndexManifest()
+	if err != nil {
+		t.Fatalf("index.json is corrupt: %v", err)
+	}
+	seen := map[v1.Hash]bool{}
+	for _, desc := range im.Manifests {
+		if seen[desc.Digest] {
+			t.Errorf("index.json lists %s more than once", desc.Digest)
+		}
+		seen[desc.Digest] = true
+		if !desc.MediaType.IsImage() {
+			continue
+		}
+		img, err := ii.Image(desc.Digest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := validate.Image(img); err != nil {
+			t.Errorf("validate.Image(%s) = %v", desc.Digest, err)
+		}
+	}
+	// The base image, and each index and its two images.
+	if got, want := len(im.Manifests), 1+4*3; got != want {
+		t.Errorf("index.json lists %d manifests, wanted %d", got, want)
+	}
+
+	b, err := os.ReadFile(filepath.Join(kocache, "img", tagsFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags := map[string]string{}
+	if err := json.Unmarshal(b, &tags); err != nil {
+		t.Fatalf("%s is corrupt: %v", tagsFile, err)
+	}
+	for _, ref := range refs {
+		if dig, err := crane.Digest(ref); err != nil || tags[ref] != dig {
+			t.Errorf("tag %s = %q, wanted %q (%v)", ref, tags[ref], dig, err)
+		}
+	}
+}
+
+// cacheWorker fetches refs, and the layers of their images, into KOCACHE.
+func cacheWorker(t *testing.T, refs []string) {
+	ctx := context.Background()
+	puller, err := remote.NewPuller()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache, err := newCache(puller, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range refs {
+		ref, err := name.ParseReference(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := cache.get(ctx, ref, fetchRemote(nil))
+		if err != nil {
+			t.Fatalf("get(%s) = %v", ref, err)
+		}
+		var imgs []v1.Image
+		switch r := res.(type) {
+		case v1.ImageIndex:
+			im, err := r.IndexManifest()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, desc := range im.Manifests {
+				img, err := r.Image(desc.Digest)
+				if err != nil {
+					t.Fatal(err)
+				}
+				imgs = append(imgs, img)
+			}
+		case v1.Image:
+			imgs = append(imgs, r)
+		}
+		for _, img := range imgs {
+			if err := cache.materialize(img); err != nil {
+				t.Fatalf("materialize() = %v", err)
+			}
+		}
+	}
+}
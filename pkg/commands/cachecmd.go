@@ -0,0 +1,356 @@
+// Copyright 2026 ko Build Authors All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/docker/go-units"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/spf13/cobra"
+
+	"github.com/google/ko/pkg/commands/options"
+	"github.com/google/ko/pkg/internal/flock"
+)
+
+// addCache augments our CLI surface with cache.
+func addCache(topLevel *cobra.Command) {
+	cache := &cobra.Command{
+		Use:   "cache",
+		Short: "Manage the binaries and base images cached in KOCACHE.",
+		Args:  cobra.NoArgs,
+	}
+	addCacheDu(cache)
+	addCacheLs(cache)
+	addCachePrune(cache)
+	addCacheWarm(cache)
+	topLevel.AddCommand(cache)
+}
+
+func addCacheWarm(cache *cobra.Command) {
+	bo := &options.BuildOptions{}
+
+	warm := &cobra.Command{
+		Use:   "warm",
+		Short: "Fetch the base images configured in .ko.yaml into KOCACHE.",
+		Long: `This sub-command fetches the default base image and the base image overrides in .ko.yaml into the image cache in KOCACHE, including the layers of every configured platform, so that they can be built upon with --offline.
+
+Base image tags are resolved to the digests recorded in .ko.lock, if it exists.`,
+		Example: `
+  # Prepare to build offline.
+  export KOCACHE=~/.cache/ko
+  ko cache warm --platform=linux/amd64,linux/arm64
+
+  # Build without contacting registries.
+  ko build --offline --local ./cmd/app`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if err := bo.LoadConfig(); err != nil {
+				return err
+			}
+			platforms := bo.Platforms
+			if len(platforms) == 0 {
+				platforms = bo.DefaultPlatforms
+			}
+			if len(platforms) == 0 {
+				platforms = []string{envPlatform()}
+			}
+			return warmCache(cmd.Context(), bo, platforms)
+		},
+	}
+	warm.Flags().StringSliceVar(&bo.Platforms, "platform", nil,
+		"Which platforms of multi-platform base images to fetch (default defaultPlatforms in .ko.yaml, or the platform ko builds for). Format: all | <os>[/<arch>[/<variant>]][,platform]*")
+	warm.Flags().BoolVar(&bo.InsecureRegistry, "insecure-registry", false,
+		"Whether to skip TLS verification on the registry")
+	cache.AddCommand(warm)
+}
+
+// warmCache fetches the base images configured in bo, and their layers for
+// the given platforms, into the image cache.
+func warmCache(ctx context.Context, bo *options.BuildOptions, platforms []string) error {
+	if os.Getenv("KOCACHE") == "" {
+		return errors.New("KOCACHE must be set to the directory to cache base images in")
+	}
+	var specs []v1.Platform
+	if len(platforms) == 0 || platforms[0] != "all" {
+		for _, s := range platforms {
+			p, err := v1.ParsePlatform(s)
+			if err != nil {
+				return err
+			}
+			specs = append(specs, *p)
+		}
+	}
+	matches := func(p *v1.Platform) bool {
+		if p == nil || p.OS == "unknown" || p.Architecture == "unknown" {
+			return false
+		}
+		if specs == nil {
+			return true
+		}
+		for _, spec := range specs {
+			if p.Satisfies(spec) {
+				return true
+			}
+		}
+		return false
+	}
+
+	userAgent := ua()
+	if bo.UserAgent != "" {
+		userAgent = bo.UserAgent
+	}
+	ropt := []remote.Option{
+		remote.WithAuthFromKeychain(keychain),
+		remote.WithUserAgent(userAgent),
+	}
+	puller, err := remote.NewPuller(ropt...)
+	if err != nil {
+		return err
+	}
+	ropt = append(ropt, remote.Reuse(puller))
+	cache, err := newCache(puller, false)
+	if err != nil {
+		return err
+	}
+	fetch := fetchRemote(ropt)
+	locker := &baseLocker{
+		path: lockPath(bo),
+		ropt: ropt,
+	}
+
+	refs, err := configuredBases(bo)
+	if err != nil {
+		return err
+	}
+	for _, ref := range refs {
+		pinned, err := locker.pin(ctx, ref)
+		if err != nil {
+			return err
+		}
+		res, err := cache.get(ctx, pinned, fetch)
+		if err != nil {
+			return fmt.Errorf("fetching %s: %w", ref, err)
+		}
+		switch r := res.(type) {
+		case v1.ImageIndex:
+			im, err := r.IndexManifest()
+			if err != nil {
+				return err
+			}
+			found := false
+			for _, desc := range im.Manifests {
+				if !desc.MediaType.IsImage() || !matches(desc.Platform) {
+					continue
+				}
+				img, err := r.Image(desc.Digest)
+				if err != nil {
+					return err
+				}
+				if err := cache.materialize(img); err != nil {
+					return fmt.Errorf("fetching %s for %s: %w", ref, desc.Platform, err)
+				}
+				found = true
+			}
+			if !found {
+				return fmt.Errorf("%s has no images for platforms %v", ref, platforms)
+			}
+		case v1.Image:
+			if err := cache.materialize(r); err != nil {
+				return fmt.Errorf("fetching %s: %w", ref, err)
+			}
+		}
+		dig, err := res.Digest()
+		if err != nil {
+			return err
+		}
+		if _, ok := pinned.(name.Digest); ok {
+			log.Printf("Cached %s", pinned)
+		} else {
+			log.Printf("Cached %s@%s", pinned, dig)
+		}
+	}
+	return nil
+}
+
+// cacheDir returns the KOCACHE directory.
+func cacheDir() (string, error) {
+	kc := os.Getenv("KOCACHE")
+	if kc == "" {
+		return "", errors.New("KOCACHE is not set; ko only caches builds and base images when it is")
+	}
+	return kc, nil
+}
+
+func addCacheLs(cache *cobra.Command) {
+	ls := &cobra.Command{
+		Use:   "ls",
+		Short: "List the binaries and base images cached in KOCACHE.",
+		Long: `This sub-command lists the binaries and base images cached in KOCACHE, with their size on disk and when they were last used by a build.
+
+Base images that share layers each count the shared layers in their size.`,
+		Example: `
+  ko cache ls`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			kc, err := cacheDir()
+			if err != nil {
+				return err
+			}
+			inv, err := scanCache(kc)
+			if err != nil {
+				return err
+			}
+			return writeCacheEntries(cmd.OutOrStdout(), inv.entries, time.Now())
+		},
+	}
+	cache.AddCommand(ls)
+}
+
+func writeCacheEntries(w io.Writer, entries []*cacheEntry, now time.Time) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "TYPE\tNAME\tSIZE\tLAST USED")
+	for _, e := range entries {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s ago\n", e.Kind, e.Name, units.HumanSize(float64(e.Size)), units.HumanDuration(now.Sub(e.LastUsed)))
+	}
+	return tw.Flush()
+}
+
+func addCacheDu(cache *cobra.Command) {
+	du := &cobra.Command{
+		Use:   "du",
+		Short: "Show the disk space used by KOCACHE.",
+		Long:  `This sub-command shows the disk space used by the binaries and base images cached in KOCACHE, and how much of it "ko cache prune" would free by removing blobs that no base image uses.`,
+		Example: `
+  ko cache du`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			kc, err := cacheDir()
+			if err != nil {
+				return err
+			}
+			inv, err := scanCache(kc)
+			if err != nil {
+				return err
+			}
+			var bins, imgs []*cacheEntry
+			for _, e := range inv.entries {
+				if e.Kind == "bin" {
+					bins = append(bins, e)
+				} else {
+					imgs = append(imgs, e)
+				}
+			}
+			var blobs int64
+			for _, size := range inv.blobSizes {
+				blobs += size
+			}
+			binSize, imgSize := inv.size(bins), inv.size(imgs)
+
+			tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			fmt.Fprintf(tw, "bin\t%d\t%s\n", len(bins), units.HumanSize(float64(binSize)))
+			fmt.Fprintf(tw, "img\t%d\t%s\n", len(imgs), units.HumanSize(float64(imgSize)))
+			fmt.Fprintf(tw, "unused\t\t%s\n", units.HumanSize(float64(blobs-imgSize)))
+			fmt.Fprintf(tw, "total\t%d\t%s\n", len(inv.entries), units.HumanSize(float64(binSize+blobs)))
+			return tw.Flush()
+		},
+	}
+	cache.AddCommand(du)
+}
+
+func addCachePrune(cache *cobra.Command) {
+	var (
+		olderThan time.Duration
+		maxSize   string
+		dryRun    bool
+	)
+	prune := &cobra.Command{
+		Use:   "prune",
+		Short: "Remove binaries and base images from KOCACHE.",
+		Long: `This sub-command removes the binaries and base images cached in KOCACHE that were last used longer ago than --older-than, and then the least recently used ones until the cache fits in --max-size. Blobs of the image cache that no remaining base image uses are always removed.
+
+Builds using KOCACHE hold a shared lock on it, and prune waits for them to finish before removing anything.`,
+		Example: `
+  # Remove anything not used by a build in the last two weeks.
+  ko cache prune --older-than=336h
+
+  # Keep the cache under 10GB.
+  ko cache prune --max-size=10GB
+
+  # Show what would be removed.
+  ko cache prune --older-than=336h --dry-run`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			kc, err := cacheDir()
+			if err != nil {
+				return err
+			}
+			var max int64
+			if maxSize != "" {
+				if max, err = units.FromHumanSize(maxSize); err != nil {
+					return fmt.Errorf("parsing --max-size: %w", err)
+				}
+			}
+
+			path := filepath.Join(kc, cacheLockFile)
+			l, ok, err := flock.TryExclusive(path)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				log.Printf("Waiting for other ko processes using %s to finish", kc)
+				if l, err = flock.Exclusive(path); err != nil {
+					return err
+				}
+			}
+			defer l.Unlock()
+
+			inv, err := scanCache(kc)
+			if err != nil {
+				return err
+			}
+			remove := inv.planPrune(olderThan, max, time.Now())
+			if dryRun {
+				return writeCacheEntries(cmd.OutOrStdout(), remove, time.Now())
+			}
+			freed, err := inv.prune(remove)
+			for _, e := range remove {
+				log.Printf("Removed %s %s", e.Kind, e.Name)
+			}
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Removed %d entries, freed %s\n", len(remove), units.HumanSize(float64(freed)))
+			return nil
+		},
+	}
+	prune.Flags().DurationVar(&olderThan, "older-than", 0,
+		"Remove binaries and base images last used longer ago than this")
+	prune.Flags().StringVar(&maxSize, "max-size", "",
+		"Remove the least recently used binaries and base images until KOCACHE is at most this size, e.g. 10GB")
+	prune.Flags().BoolVar(&dryRun, "dry-run", false,
+		"List what would be removed, without removing it")
+	cache.AddCommand(prune)
+}
@@ -0,0 +1,395 @@
+// Copyright 2026 ko Build Authors All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+
+	"github.com/google/ko/pkg/internal/atomicfile"
+)
+
+// cacheEntry is a unit of KOCACHE that can be listed and pruned: the binary
+// built for an import path and platform, or a base image in the image cache.
+type cacheEntry struct {
+	Kind     string    `json:"kind"`
+	Name     string    `json:"name"`
+	Size     int64     `json:"size"`
+	LastUsed time.Time `json:"lastUsed"`
+
+	// dir is the directory holding a binary.
+	dir string
+	// digests are the entries of the image cache's index.json that make up
+	// a base image: the image or index itself, and any images of the index.
+	digests []v1.Hash
+	// blobs are the blobs of a base image that are in the image cache.
+	blobs []v1.Hash
+}
+
+// cacheInventory is what is in a KOCACHE directory.
+type cacheInventory struct {
+	dir     string
+	entries []*cacheEntry
+
+	// index is the index.json of the image cache, or nil if there isn't one.
+	index *v1.IndexManifest
+	// blobSizes has the size of every blob in the image cache.
+	blobSizes map[v1.Hash]int64
+}
+
+func (inv *cacheInventory) imgDir() string {
+	return filepath.Join(inv.dir, "img")
+}
+
+func (inv *cacheInventory) blobPath(h v1.Hash) string {
+	return filepath.Join(inv.imgDir(), "blobs", h.Algorithm, h.Hex)
+}
+
+// scanCache returns what is in the KOCACHE directory dir.
+func scanCache(dir string) (*cacheInventory, error) {
+	inv := &cacheInventory{dir: dir, blobSizes: map[v1.Hash]int64{}}
+	if err := inv.scanBinaries(); err != nil {
+		return nil, err
+	}
+	if err := inv.scanImages(); err != nil {
+		return nil, err
+	}
+	slices.SortFunc(inv.entries, func(a, b *cacheEntry) int {
+		if c := strings.Compare(a.Kind, b.Kind); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+	return inv, nil
+}
+
+// scanBinaries adds an entry for each directory under bin/ that holds files,
+// which are the binary built for an import path and platform, and the
+// metadata of the layers built from it.
+func (inv *cacheInventory) scanBinaries() error {
+	binDir := filepath.Join(inv.dir, "bin")
+	byDir := map[string]*cacheEntry{}
+	err := filepath.WalkDir(binDir, func(path string, d fs.DirEntry, err error) error {
+		if path == binDir && errors.Is(err, fs.ErrNotExist) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		dir := filepath.Dir(path)
+		e, ok := byDir[dir]
+		if !ok {
+			rel, err := filepath.Rel(binDir, dir)
+			if err != nil {
+				return err
+			}
+			e = &cacheEntry{Kind: "bin", Name: filepath.ToSlash(rel), dir: dir}
+			byDir[dir] = e
+			inv.entries = append(inv.entries, e)
+		}
+		e.Size += info.Size()
+		if info.ModTime().After(e.LastUsed) {
+			e.LastUsed = info.ModTime()
+		}
+		return nil
+	})
+	return err
+}
+
+// scanImages adds an entry for each base image in the image cache.
+func (inv *cacheInventory) scanImages() error {
+	b, err := os.ReadFile(filepath.Join(inv.imgDir(), "index.json"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if inv.index, err = v1.ParseIndexManifest(bytes.NewReader(b)); err != nil {
+		return err
+	}
+
+	mtimes := map[v1.Hash]time.Time{}
+	blobsDir := filepath.Join(inv.imgDir(), "blobs")
+	err = filepath.WalkDir(blobsDir, func(path string, d fs.DirEntry, err error) error {
+		if path == blobsDir && errors.Is(err, fs.ErrNotExist) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		h := v1.Hash{Algorithm: filepath.Base(filepath.Dir(path)), Hex: d.Name()}
+		inv.blobSizes[h] = info.Size()
+		mtimes[h] = info.ModTime()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	tags := map[string]string{}
+	if b, err := os.ReadFile(filepath.Join(inv.imgDir(), tagsFile)); err == nil {
+		if err := json.Unmarshal(b, &tags); err != nil {
+			return err
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	// The images of a cached index are cached as entries of their own;
+	// they are listed and pruned along with the index.
+	children := map[v1.Hash]bool{}
+	for _, desc := range inv.index.Manifests {
+		if desc.MediaType.IsIndex() {
+			for h := range inv.reachable(desc.Digest, desc.MediaType) {
+				if h != desc.Digest {
+					children[h] = true
+				}
+			}
+		}
+	}
+	for _, desc := range inv.index.Manifests {
+		if children[desc.Digest] {
+			continue
+		}
+		reachable := inv.reachable(desc.Digest, desc.MediaType)
+		var names []string
+		for tag, dig := range tags {
+			if dig == desc.Digest.String() {
+				names = append(names, tag)
+			}
+		}
+		slices.Sort(names)
+		if len(names) == 0 {
+			names = []string{desc.Digest.String()}
+		}
+		e := &cacheEntry{Kind: "img", Name: strings.Join(names, ", ")}
+		for _, d := range inv.index.Manifests {
+			if reachable[d.Digest] {
+				e.digests = append(e.digests, d.Digest)
+				if mtimes[d.Digest].After(e.LastUsed) {
+					e.LastUsed = mtimes[d.Digest]
+				}
+			}
+		}
+		for h := range reachable {
+			if size, ok := inv.blobSizes[h]; ok {
+				e.blobs = append(e.blobs, h)
+				e.Size += size
+			}
+		}
+		inv.entries = append(inv.entries, e)
+	}
+	return nil
+}
+
+// reachable returns the blobs referenced by the manifest h, including h.
+// Blobs that aren't in the cache are included, but not followed.
+func (inv *cacheInventory) reachable(h v1.Hash, mt types.MediaType) map[v1.Hash]bool {
+	seen := map[v1.Hash]bool{}
+	var walk func(h v1.Hash, mt types.MediaType)
+	walk = func(h v1.Hash, mt types.MediaType) {
+		if seen[h] {
+			return
+		}
+		seen[h] = true
+		b, err := os.ReadFile(inv.blobPath(h))
+		if err != nil {
+			return
+		}
+		switch {
+		case mt.IsIndex():
+			im, err := v1.ParseIndexManifest(bytes.NewReader(b))
+			if err != nil {
+				return
+			}
+			for _, desc := range im.Manifests {
+				walk(desc.Digest, desc.MediaType)
+			}
+		case mt.IsImage():
+			m, err := v1.ParseManifest(bytes.NewReader(b))
+			if err != nil {
+				return
+			}
+			seen[m.Config.Digest] = true
+			for _, desc := range m.Layers {
+				seen[desc.Digest] = true
+			}
+		}
+	}
+	walk(h, mt)
+	return seen
+}
+
+// size returns the size of entries, counting blobs shared between images once.
+func (inv *cacheInventory) size(entries []*cacheEntry) int64 {
+	var total int64
+	blobs := map[v1.Hash]bool{}
+	for _, e := range entries {
+		if e.Kind == "bin" {
+			total += e.Size
+			continue
+		}
+		for _, h := range e.blobs {
+			if !blobs[h] {
+				blobs[h] = true
+				total += inv.blobSizes[h]
+			}
+		}
+	}
+	return total
+}
+
+// planPrune returns the entries to remove so that none is older than
+// olderThan, and the rest take up at most maxSize, removing the least
+// recently used first.  Zero values impose no limit.
+func (inv *cacheInventory) planPrune(olderThan time.Duration, maxSize int64, now time.Time) []*cacheEntry {
+	var remove, keep []*cacheEntry
+	byAge := slices.Clone(inv.entries)
+	slices.SortStableFunc(byAge, func(a, b *cacheEntry) int {
+		return a.LastUsed.Compare(b.LastUsed)
+	})
+	for _, e := range byAge {
+		if olderThan > 0 && now.Sub(e.LastUsed) > olderThan {
+			remove = append(remove, e)
+		} else {
+			keep = append(keep, e)
+		}
+	}
+	for maxSize > 0 && len(keep) > 0 && inv.size(keep) > maxSize {
+		remove = append(remove, keep[0])
+		keep = keep[1:]
+	}
+	return remove
+}
+
+// prune removes the given entries, and then any blobs of the image cache
+// that are no longer referenced.  It returns the number of bytes freed.
+func (inv *cacheInventory) prune(remove []*cacheEntry) (int64, error) {
+	var freed int64
+	removed := map[*cacheEntry]bool{}
+	binDir := filepath.Join(inv.dir, "bin")
+	for _, e := range remove {
+		removed[e] = true
+		if e.Kind != "bin" {
+			continue
+		}
+		if err := os.RemoveAll(e.dir); err != nil {
+			return freed, err
+		}
+		freed += e.Size
+		// Clean up the directories of the import path, if now empty.
+		for dir := filepath.Dir(e.dir); dir != binDir && strings.HasPrefix(dir, binDir); dir = filepath.Dir(dir) {
+			if os.Remove(dir) != nil {
+				break
+			}
+		}
+	}
+	if inv.index == nil {
+		return freed, nil
+	}
+
+	keep := map[v1.Hash]bool{}
+	reachable := map[v1.Hash]bool{}
+	for _, e := range inv.entries {
+		if e.Kind != "img" || removed[e] {
+			continue
+		}
+		for _, h := range e.digests {
+			keep[h] = true
+		}
+	}
+	index := *inv.index
+	index.Manifests = slices.DeleteFunc(slices.Clone(index.Manifests), func(desc v1.Descriptor) bool {
+		return !keep[desc.Digest]
+	})
+	for _, desc := range index.Manifests {
+		for h := range inv.reachable(desc.Digest, desc.MediaType) {
+			reachable[h] = true
+		}
+	}
+	if len(index.Manifests) != len(inv.index.Manifests) {
+		b, err := json.Marshal(index)
+		if err != nil {
+			return freed, err
+		}
+		if err := atomicfile.WriteFile(filepath.Join(inv.imgDir(), "index.json"), b, 0o644); err != nil {
+			return freed, err
+		}
+		if err := inv.pruneTags(keep); err != nil {
+			return freed, err
+		}
+	}
+
+	for h, size := range inv.blobSizes {
+		if reachable[h] {
+			continue
+		}
+		if err := os.Remove(inv.blobPath(h)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return freed, err
+		}
+		// No ko process is using the cache, so neither is the lock of h.
+		if err := os.Remove(keyLockPath(inv.imgDir(), h)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return freed, err
+		}
+		freed += size
+	}
+	return freed, nil
+}
+
+// pruneTags forgets the tags of base images that are no longer cached.
+func (inv *cacheInventory) pruneTags(keep map[v1.Hash]bool) error {
+	path := filepath.Join(inv.imgDir(), tagsFile)
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	tags := map[string]string{}
+	if err := json.Unmarshal(b, &tags); err != nil {
+		return err
+	}
+	for tag, dig := range tags {
+		if h, err := v1.NewHash(dig); err != nil || !keep[h] {
+			delete(tags, tag)
+		}
+	}
+	if b, err = json.MarshalIndent(tags, "", "  "); err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(path, b, 0o644)
+}
@@ -0,0 +1,175 @@
+// Copyright 2026 ko Build Authors All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+)
+
+func TestPruneCache(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	old := now.Add(-30 * 24 * time.Hour)
+
+	// Two binaries, one built a month ago.
+	writeBin := func(rel string, mtime time.Time) {
+		d := filepath.Join(dir, "bin", rel)
+		if err := os.MkdirAll(d, os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range []string{"out", "buildid-to-diffid"} {
+			p := filepath.Join(d, f)
+			if err := os.WriteFile(p, []byte("0123456789"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chtimes(p, mtime, mtime); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	writeBin("example.com/old/linux-amd64", old)
+	writeBin("example.com/new/linux-amd64", now)
+
+	// A multi-platform base used a month ago, and an image used now.
+	p, err := layout.Write(filepath.Join(dir, "img"), empty.Index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	amd, arm := mustRandom(), mustRandom()
+	idx := platformIndex(t, amd, arm)
+	if err := p.AppendIndex(idx); err != nil {
+		t.Fatal(err)
+	}
+	for _, img := range []v1.Image{amd, arm} {
+		if err := p.AppendImage(img); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(filepath.Join(dir, "img", "blobs", "sha256", mustDigest(img).Hex), old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+	idxDigest, err := idx.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(filepath.Join(dir, "img", "blobs", "sha256", idxDigest.Hex), old, old); err != nil {
+		t.Fatal(err)
+	}
+	img := mustRandom()
+	if err := p.AppendImage(img); err != nil {
+		t.Fatal(err)
+	}
+	tags, err := json.Marshal(map[string]string{
+		"example.com/multi:latest":  idxDigest.String(),
+		"example.com/single:latest": mustDigest(img).String(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "img", tagsFile), tags, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// A blob left behind by an interrupted write.
+	if err := p.WriteBlob(v1.Hash{Algorithm: "sha256", Hex: "0000000000000000000000000000000000000000000000000000000000000000"}, io.NopCloser(strings.NewReader("orphan"))); err != nil {
+		t.Fatal(err)
+	}
+
+	inv, err := scanCache(dir)
+	if err != nil {
+		t.Fatalf("scanCache() = %v", err)
+	}
+	var names []string
+	for _, e := range inv.entries {
+		names = append(names, e.Kind+" "+e.Name)
+	}
+	want := []string{
+		"bin example.com/new/linux-amd64",
+		"bin example.com/old/linux-amd64",
+		"img example.com/multi:latest",
+		"img example.com/single:latest",
+	}
+	if !slices.Equal(names, want) {
+		t.Fatalf("scanCache() = %v, wanted %v", names, want)
+	}
+
+	remove := inv.planPrune(7*24*time.Hour, 0, now)
+	var removed []string
+	for _, e := range remove {
+		removed = append(removed, e.Kind+" "+e.Name)
+	}
+	slices.Sort(removed)
+	if want := []string{"bin example.com/old/linux-amd64", "img example.com/multi:latest"}; !slices.Equal(removed, want) {
+		t.Fatalf("planPrune() = %v, wanted %v", removed, want)
+	}
+
+	// Limiting the size also removes the least recently used of the rest.
+	single := inv.entries[3]
+	if got := inv.planPrune(7*24*time.Hour, single.Size, now); len(got) != 3 || slices.Contains(got, single) {
+		t.Errorf("planPrune() with a max size = %d entries, wanted all but %s", len(got), single.Name)
+	}
+
+	if _, err := inv.prune(remove); err != nil {
+		t.Fatalf("prune() = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "bin", "example.com", "old")); !os.IsNotExist(err) {
+		t.Errorf("stat old binary dir = %v, wanted it removed", err)
+	}
+
+	// The remaining image is still readable from the layout, and nothing
+	// else is left in it.
+	ii, err := layout.ImageIndexFromPath(filepath.Join(dir, "img"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ii.Image(mustDigest(img)); err != nil {
+		t.Errorf("Image() = %v", err)
+	}
+	inv, err = scanCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inv.entries) != 2 {
+		t.Fatalf("scanCache() after prune = %d entries, wanted 2", len(inv.entries))
+	}
+	var blobs int64
+	for _, size := range inv.blobSizes {
+		blobs += size
+	}
+	if blobs != inv.entries[1].Size {
+		t.Errorf("blobs left = %d bytes, wanted %d", blobs, inv.entries[1].Size)
+	}
+	b, err := os.ReadFile(filepath.Join(dir, "img", tagsFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gotTags map[string]string
+	if err := json.Unmarshal(b, &gotTags); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := gotTags["example.com/multi:latest"]; ok || len(gotTags) != 1 {
+		t.Errorf("tags = %v, wanted only example.com/single:latest", gotTags)
+	}
+}
@@ -32,6 +32,10 @@ func AddKubeCommands(topLevel *cobra.Command) {
 	addResolve(topLevel)
 	addBuild(topLevel)
 	addRun(topLevel)
+	addRebase(topLevel)
+	addBaseStatus(topLevel)
+	addLock(topLevel)
+	addCache(topLevel)
 }
 
 // check if kubectl is installed
@@ -20,6 +20,7 @@ import (
 	"io"
 	"log"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -51,6 +52,29 @@ var (
 	)
 )
 
+// destinationKeychain returns the keychain that a publish destination names,
+// or all of them.
+func destinationKeychain(name string) (authn.Keychain, error) {
+	switch name {
+	case "":
+		return keychain, nil
+	case "docker":
+		return authn.DefaultKeychain, nil
+	case "ecr":
+		return amazonKeychain, nil
+	case "google":
+		return google.Keychain, nil
+	case "github":
+		return github.Keychain, nil
+	case "azure":
+		return azureKeychain, nil
+	case "anonymous":
+		return authn.NewMultiKeychain(), nil
+	default:
+		return nil, fmt.Errorf("unknown keychain %q", name)
+	}
+}
+
 // getBaseImage returns a function that determines the base image for a given import path.
 func getBaseImage(bo *options.BuildOptions) build.GetBase {
 	userAgent := ua()
@@ -69,24 +93,19 @@ func getBaseImage(bo *options.BuildOptions) build.GetBase {
 	}
 	ropt = append(ropt, remote.Reuse(puller))
 
-	cache, err := newCache(puller)
-	if err != nil {
-		log.Printf("Image cache init failed: %v", err)
+	cache, cacheErr := newCache(puller, bo.Offline)
+	if cacheErr != nil {
+		log.Printf("Image cache init failed: %v", cacheErr)
 	}
 
-	fetch := func(ctx context.Context, ref name.Reference) (build.Result, error) {
-		ropt = append(ropt, remote.WithContext(ctx))
-
-		desc, err := remote.Get(ref, ropt...)
-		if err != nil {
-			return nil, err
-		}
-		if desc.MediaType.IsIndex() {
-			return desc.ImageIndex()
-		}
-		return desc.Image()
+	locker := &baseLocker{
+		path:   lockPath(bo),
+		update: bo.UpdateLock,
+		ropt:   ropt,
 	}
 
+	fetch := fetchRemote(ropt)
+
 	return func(ctx context.Context, s string) (name.Reference, build.Result, error) {
 		s = strings.TrimPrefix(s, build.StrictScheme)
 		// Viper configuration file keys are case insensitive, and are
@@ -99,6 +118,25 @@ func getBaseImage(bo *options.BuildOptions) build.GetBase {
 		if !ok || baseImage == "" {
 			baseImage = bo.BaseImage
 		}
+		if options.IsFileBaseImage(baseImage) {
+			if p := bo.BaseImagePolicy; p != nil {
+				if err := checkFileBasePolicy(p, baseImage); err != nil {
+					return nil, nil, fmt.Errorf("base image %q for %s is not allowed by baseImagePolicy: %w", baseImage, s, err)
+				}
+			}
+			result, err := loadFileBase(ctx, baseImage, bo.WorkingDirectory)
+			if err != nil {
+				return nil, nil, fmt.Errorf("loading base image %q for %s: %w", baseImage, s, err)
+			}
+			dig, err := result.Digest()
+			if err != nil {
+				return nil, nil, err
+			}
+			log.Printf("Using base %s (%s) for %s", baseImage, dig, s)
+			// Images loaded from files have no reference to record.
+			return nil, result, nil
+		}
+
 		var nameOpts []name.Option
 		if bo.InsecureRegistry {
 			nameOpts = append(nameOpts, name.Insecure)
@@ -107,6 +145,17 @@ func getBaseImage(bo *options.BuildOptions) build.GetBase {
 		if err != nil {
 			return nil, nil, fmt.Errorf("parsing base image (%q): %w", baseImage, err)
 		}
+		if p := bo.BaseImagePolicy; p != nil {
+			if err := checkBaseImagePolicy(p, ref); err != nil {
+				return nil, nil, fmt.Errorf("base image %q for %s is not allowed by baseImagePolicy: %w", baseImage, s, err)
+			}
+		}
+
+		// Pull tags by the digest recorded in the lock file, if there is one.
+		pinned, err := locker.pin(ctx, ref)
+		if err != nil {
+			return nil, nil, fmt.Errorf("base image %q for %s: %w", baseImage, s, err)
+		}
 
 		var result build.Result
 
@@ -117,26 +166,44 @@ func getBaseImage(bo *options.BuildOptions) build.GetBase {
 				return nil, nil, fmt.Errorf("loading %s from daemon: %w", ref, err)
 			}
 		} else {
-			result, err = cache.get(ctx, ref, fetch)
-			if err != nil {
+			result, err = cache.get(ctx, pinned, fetch)
+			if err != nil && bo.Offline {
+				if cacheErr != nil {
+					err = cacheErr
+				}
+				return nil, nil, fmt.Errorf("base image %q for %s: %w", baseImage, s, err)
+			} else if err != nil {
 				// We don't expect this to fail, usually, but the cache should also not be fatal.
 				// Log it so people can complain about it and we can fix the cache.
-				log.Printf("cache.get(%q) failed with %v", ref.String(), err)
+				log.Printf("cache.get(%q) failed with %v", pinned.String(), err)
 
-				result, err = fetch(ctx, ref)
+				result, err = fetch(ctx, pinned)
 				if err != nil {
-					return nil, nil, fmt.Errorf("pulling %s: %w", ref, err)
+					return nil, nil, fmt.Errorf("pulling %s: %w", pinned, err)
 				}
 			}
 		}
 
+		dig, err := result.Digest()
+		if err != nil {
+			return ref, result, err
+		}
+
+		if p := bo.BaseImagePolicy; p != nil && p.PublicKey != "" {
+			if bo.Offline {
+				return nil, nil, fmt.Errorf("base image %q for %s is not allowed by baseImagePolicy: signatures cannot be verified offline", baseImage, s)
+			}
+			if ref.Context().RegistryStr() == publish.LocalDomain {
+				return nil, nil, fmt.Errorf("base image %q for %s is not allowed by baseImagePolicy: signatures of daemon images cannot be verified", baseImage, s)
+			}
+			if err := verifyBaseSignature(ctx, p.PublicKey, ref, dig, ropt...); err != nil {
+				return nil, nil, fmt.Errorf("base image %q for %s is not allowed by baseImagePolicy: %w", baseImage, s, err)
+			}
+		}
+
 		if _, ok := ref.(name.Digest); ok {
 			log.Printf("Using base %s for %s", ref, s)
 		} else {
-			dig, err := result.Digest()
-			if err != nil {
-				return ref, result, err
-			}
 			log.Printf("Using base %s@%s for %s", ref, dig, s)
 		}
 
@@ -166,3 +233,18 @@ func getKoDataCreationTime() (*v1.Time, error) {
 }
 
 type baseFactory func(context.Context, name.Reference) (build.Result, error)
+
+// fetchRemote returns a baseFactory that pulls base images from their
+// registry.
+func fetchRemote(ropt []remote.Option) baseFactory {
+	return func(ctx context.Context, ref name.Reference) (build.Result, error) {
+		desc, err := remote.Get(ref, append(slices.Clip(ropt), remote.WithContext(ctx))...)
+		if err != nil {
+			return nil, err
+		}
+		if desc.MediaType.IsIndex() {
+			return desc.ImageIndex()
+		}
+		return desc.Image()
+	}
+}
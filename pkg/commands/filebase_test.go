@@ -0,0 +1,227 @@
+// Copyright 2026 ko Build Authors All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"archive/tar"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	specsv1 "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/google/ko/pkg/build"
+	"github.com/google/ko/pkg/commands/options"
+)
+
+// tarDir writes the contents of dir to a tar file at path.
+func tarDir(t *testing.T, dir, path string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	tw := tar.NewWriter(f)
+	defer tw.Close()
+	if err := tw.AddFS(os.DirFS(dir)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadFileBase(t *testing.T) {
+	dir := t.TempDir()
+	amd, arm := mustRandom(), mustRandom()
+	idx := platformIndex(t, amd, arm)
+	idxDigest, err := idx.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := mustRandom()
+
+	// A layout holding a tagged index and another image.
+	p, err := layout.Write(filepath.Join(dir, "layout"), empty.Index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.AppendIndex(idx, layout.WithAnnotations(map[string]string{specsv1.AnnotationRefName: "v1"})); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.AppendImage(other); err != nil {
+		t.Fatal(err)
+	}
+	tarDir(t, filepath.Join(dir, "layout"), filepath.Join(dir, "layout.tar"))
+
+	// A layout like apko writes, holding just the index.
+	single, err := layout.Write(filepath.Join(dir, "single"), empty.Index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := single.AppendIndex(idx); err != nil {
+		t.Fatal(err)
+	}
+
+	// A layout holding an image per platform.
+	platforms, err := layout.Write(filepath.Join(dir, "platforms"), empty.Index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := platforms.AppendImage(amd, layout.WithPlatform(*amd64)); err != nil {
+		t.Fatal(err)
+	}
+	if err := platforms.AppendImage(arm, layout.WithPlatform(*arm64)); err != nil {
+		t.Fatal(err)
+	}
+
+	tag, err := name.NewTag("example.com/base:v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tarball.WriteToFile(filepath.Join(dir, "docker.tar"), tag, other); err != nil {
+		t.Fatal(err)
+	}
+
+	// There is no containerd to load from.
+	t.Setenv("CONTAINERD_ADDRESS", filepath.Join(dir, "containerd.sock"))
+
+	for _, c := range []struct {
+		base    string
+		want    v1.Hash
+		index   bool
+		wantErr string
+	}{
+		{base: "oci-layout://layout:v1", want: idxDigest, index: true},
+		{base: "oci-layout://" + filepath.Join(dir, "layout") + "@" + mustDigest(other).String(), want: mustDigest(other)},
+		{base: "oci-layout://layout", wantErr: "select one with :tag or @digest"},
+		{base: "oci-layout://layout:v2", wantErr: `no image tagged "v2"`},
+		{base: "oci-layout://single", want: idxDigest, index: true},
+		{base: "oci-layout://platforms", index: true},
+		{base: "oci-archive://layout.tar:v1", want: idxDigest, index: true},
+		{base: "oci-archive://layout.tar@" + mustDigest(other).String(), want: mustDigest(other)},
+		{base: "oci-archive://docker.tar", wantErr: "not an OCI image layout archive"},
+		{base: "docker-archive://docker.tar", want: mustDigest(other)},
+		{base: "containerd://example.com/base:v1", wantErr: "from containerd"},
+	} {
+		t.Run(c.base, func(t *testing.T) {
+			res, err := loadFileBase(context.Background(), c.base, dir)
+			if c.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+					t.Fatalf("loadFileBase() = %v, wanted %q", err, c.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadFileBase() = %v", err)
+			}
+			got, err := res.Digest()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.want != (v1.Hash{}) && got != c.want {
+				t.Errorf("Digest() = %s, wanted %s", got, c.want)
+			}
+
+			// Read every image and layer, as a build and publish would.
+			var imgs []v1.Image
+			switch r := res.(type) {
+			case v1.ImageIndex:
+				if !c.index {
+					t.Fatalf("loadFileBase() = %T, wanted an image", res)
+				}
+				im, err := r.IndexManifest()
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, desc := range im.Manifests {
+					img, err := r.Image(desc.Digest)
+					if err != nil {
+						t.Fatal(err)
+					}
+					imgs = append(imgs, img)
+				}
+			case v1.Image:
+				if c.index {
+					t.Fatalf("loadFileBase() = %T, wanted an index", res)
+				}
+				imgs = append(imgs, r)
+			}
+			for _, img := range imgs {
+				layers, err := img.Layers()
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, l := range layers {
+					rc, err := l.Compressed()
+					if err != nil {
+						t.Fatal(err)
+					}
+					if _, err := io.Copy(io.Discard, rc); err != nil {
+						t.Fatal(err)
+					}
+					rc.Close()
+				}
+			}
+		})
+	}
+}
+
+func TestBuildWithLayoutBase(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	amd := mustRandom()
+	p, err := layout.Write(dir, empty.Index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.AppendIndex(platformIndex(t, amd, mustRandom())); err != nil {
+		t.Fatal(err)
+	}
+
+	builder, err := NewBuilder(ctx, &options.BuildOptions{
+		BaseImage:        "oci-layout://" + dir,
+		ConcurrentBuilds: 1,
+		Platforms:        []string{"linux/amd64"},
+		SBOM:             "none",
+	})
+	if err != nil {
+		t.Fatalf("NewBuilder(): %v", err)
+	}
+	res, err := builder.Build(ctx, build.StrictScheme+"github.com/google/ko/test")
+	if err != nil {
+		t.Fatalf("Build(): %v", err)
+	}
+	img, ok := res.(v1.Image)
+	if !ok {
+		t.Fatalf("Build() = %T, wanted an image", res)
+	}
+	m, err := img.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m.Annotations[specsv1.AnnotationBaseImageDigest], mustDigest(amd).String(); got != want {
+		t.Errorf("base digest annotation = %q, wanted %q", got, want)
+	}
+	if name, ok := m.Annotations[specsv1.AnnotationBaseImageName]; ok {
+		t.Errorf("base name annotation = %q, wanted none for a base loaded from a layout", name)
+	}
+}
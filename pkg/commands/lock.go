@@ -0,0 +1,355 @@
+// Copyright 2026 ko Build Authors All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/spf13/cobra"
+	"go.yaml.in/yaml/v4"
+
+	"github.com/google/ko/pkg/commands/options"
+	"github.com/google/ko/pkg/internal/atomicfile"
+	"github.com/google/ko/pkg/publish"
+)
+
+// lockFileName is the name of the file, next to `.ko.yaml`, that records
+// the digests base image tags resolved to.
+const lockFileName = ".ko.lock"
+
+// lockPath returns the path of the lock file of the `.ko.yaml` that bo was
+// loaded from.
+func lockPath(bo *options.BuildOptions) string {
+	dir := bo.ConfigDirectory
+	if dir == "" {
+		dir = bo.WorkingDirectory
+	}
+	return filepath.Join(dir, lockFileName)
+}
+
+const lockHeader = "# This file is generated by `ko lock`. Do not edit it by hand.\n"
+
+// baseLock is the contents of a `.ko.lock` file.
+type baseLock struct {
+	// Bases maps fully qualified base image tags to what they resolved to.
+	Bases map[string]lockedBase `yaml:"bases"`
+}
+
+// lockedBase records what a base image tag resolved to.
+type lockedBase struct {
+	Digest string `yaml:"digest"`
+
+	// Platforms maps each platform of a base image index to the digest of
+	// its image.
+	Platforms map[string]string `yaml:"platforms,omitempty"`
+}
+
+// compare returns an error if lb, what a tag resolves to now, is not what
+// was recorded.
+func (l lockedBase) compare(lb lockedBase) error {
+	var changed []string
+	for p, dig := range lb.Platforms {
+		if l.Platforms[p] != dig {
+			changed = append(changed, p)
+		}
+	}
+	for p := range l.Platforms {
+		if _, ok := lb.Platforms[p]; !ok {
+			changed = append(changed, p)
+		}
+	}
+	slices.Sort(changed)
+	switch {
+	case l.Digest != lb.Digest && len(changed) != 0:
+		return fmt.Errorf("moved from %s to %s, changing %s", l.Digest, lb.Digest, strings.Join(changed, ", "))
+	case l.Digest != lb.Digest:
+		return fmt.Errorf("moved from %s to %s", l.Digest, lb.Digest)
+	case len(changed) != 0:
+		return fmt.Errorf("is recorded with the wrong digests for %s", strings.Join(changed, ", "))
+	}
+	return nil
+}
+
+// readLock reads the lock file at path, returning nil if it does not exist.
+func readLock(path string) (*baseLock, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	lock := &baseLock{}
+	if err := yaml.Unmarshal(b, lock); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if lock.Bases == nil {
+		lock.Bases = map[string]lockedBase{}
+	}
+	return lock, nil
+}
+
+// write writes the lock file to path.
+func (l *baseLock) write(path string) error {
+	buf := bytes.NewBufferString(lockHeader)
+	e := yaml.NewEncoder(buf)
+	e.SetIndent(2)
+	if err := e.Encode(l); err != nil {
+		return err
+	}
+	if err := e.Close(); err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(path, buf.Bytes(), 0o644)
+}
+
+// lockable returns whether ref is a tag that should be recorded in the lock
+// file.  References by digest are already pinned, and images in the local
+// daemon cannot be pulled by digest.
+func lockable(ref name.Reference) bool {
+	_, ok := ref.(name.Tag)
+	return ok && ref.Context().RegistryStr() != publish.LocalDomain
+}
+
+// resolveLock returns what ref currently resolves to.
+func resolveLock(ref name.Reference, ropt ...remote.Option) (lockedBase, error) {
+	desc, err := remote.Get(ref, ropt...)
+	if err != nil {
+		return lockedBase{}, err
+	}
+	lb := lockedBase{Digest: desc.Digest.String()}
+	if !desc.MediaType.IsIndex() {
+		return lb, nil
+	}
+	im, err := v1.ParseIndexManifest(bytes.NewReader(desc.Manifest))
+	if err != nil {
+		return lockedBase{}, err
+	}
+	lb.Platforms = map[string]string{}
+	for _, m := range im.Manifests {
+		// Skip attestations, which are recorded with an unknown platform.
+		if m.Platform == nil || m.Platform.OS == "unknown" {
+			continue
+		}
+		lb.Platforms[m.Platform.String()] = m.Digest.String()
+	}
+	return lb, nil
+}
+
+// baseLocker pins base image tags to the digests recorded in `.ko.lock`.
+type baseLocker struct {
+	path   string
+	update bool
+	ropt   []remote.Option
+
+	mu      sync.Mutex
+	loaded  bool
+	lock    *baseLock
+	updated map[string]bool
+}
+
+// pin returns the reference to pull for the base image ref.  If there is no
+// lock file, and the lock is not being updated, ref is returned unchanged.
+func (l *baseLocker) pin(ctx context.Context, ref name.Reference) (name.Reference, error) {
+	if !lockable(ref) {
+		return ref, nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.loaded {
+		lock, err := readLock(l.path)
+		if err != nil {
+			return nil, err
+		}
+		if lock == nil && l.update {
+			lock = &baseLock{Bases: map[string]lockedBase{}}
+		}
+		l.lock, l.loaded = lock, true
+	}
+	if l.lock == nil {
+		return ref, nil
+	}
+
+	key := ref.Name()
+	if l.update && !l.updated[key] {
+		lb, err := resolveLock(ref, append(l.ropt, remote.WithContext(ctx))...)
+		if err != nil {
+			return nil, fmt.Errorf("resolving %s: %w", ref, err)
+		}
+		if old, ok := l.lock.Bases[key]; !ok || old.Digest != lb.Digest {
+			l.lock.Bases[key] = lb
+			if err := l.lock.write(l.path); err != nil {
+				return nil, fmt.Errorf("writing %s: %w", l.path, err)
+			}
+			log.Printf("Locked %s to %s in %s", ref, lb.Digest, l.path)
+		}
+		if l.updated == nil {
+			l.updated = map[string]bool{}
+		}
+		l.updated[key] = true
+	}
+
+	lb, ok := l.lock.Bases[key]
+	if !ok {
+		return nil, fmt.Errorf("%s is not recorded in %s; run \"ko lock\" or pass --update-lock", key, l.path)
+	}
+	return ref.Context().Digest(lb.Digest), nil
+}
+
+// configuredBases returns the distinct base images configured in bo, other
+// than images in the local daemon or loaded from files.
+func configuredBases(bo *options.BuildOptions) ([]name.Reference, error) {
+	var nameOpts []name.Option
+	if bo.InsecureRegistry {
+		nameOpts = append(nameOpts, name.Insecure)
+	}
+	seen := map[string]bool{}
+	var refs []name.Reference
+	for _, s := range append([]string{bo.BaseImage}, slices.Collect(maps.Values(bo.BaseImageOverrides))...) {
+		if s == "" || options.IsFileBaseImage(s) {
+			continue
+		}
+		ref, err := name.ParseReference(s, nameOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("parsing base image (%q): %w", s, err)
+		}
+		if ref.Context().RegistryStr() == publish.LocalDomain || seen[ref.Name()] {
+			continue
+		}
+		seen[ref.Name()] = true
+		refs = append(refs, ref)
+	}
+	slices.SortFunc(refs, func(a, b name.Reference) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+	return refs, nil
+}
+
+// checkLock returns an error if lock is missing, does not record exactly
+// the given base images, or records something other than what resolve says
+// they resolve to now.
+func checkLock(path string, lock *baseLock, refs []name.Reference, resolve func(name.Reference) (lockedBase, error)) error {
+	if lock == nil {
+		return fmt.Errorf("%s does not exist; run \"ko lock\" to create it", path)
+	}
+	var errs []error
+	want := map[string]bool{}
+	for _, ref := range refs {
+		want[ref.Name()] = true
+		locked, ok := lock.Bases[ref.Name()]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s is not recorded", ref.Name()))
+			continue
+		}
+		lb, err := resolve(ref)
+		if err != nil {
+			return fmt.Errorf("resolving %s: %w", ref, err)
+		}
+		if err := locked.compare(lb); err != nil {
+			errs = append(errs, fmt.Errorf("%s %w", ref.Name(), err))
+		}
+	}
+	for key := range lock.Bases {
+		if !want[key] {
+			errs = append(errs, fmt.Errorf("%s is no longer a base image", key))
+		}
+	}
+	if len(errs) != 0 {
+		slices.SortFunc(errs, func(a, b error) int { return strings.Compare(a.Error(), b.Error()) })
+		return fmt.Errorf("%s is out of date; run \"ko lock\" to update it: %w", path, errors.Join(errs...))
+	}
+	return nil
+}
+
+// addLock augments our CLI surface with lock.
+func addLock(topLevel *cobra.Command) {
+	bo := &options.BuildOptions{}
+	var check bool
+
+	lock := &cobra.Command{
+		Use:   "lock",
+		Short: "Record the digests of the configured base images in .ko.lock.",
+		Long: `This sub-command resolves the default base image and the base image overrides in .ko.yaml, and records the digest each tag points to (and the digest of each platform of a multi-platform base) in .ko.lock, next to .ko.yaml.
+
+When .ko.lock exists, builds use the recorded digests instead of resolving tags, so that building the same commit produces the same image. Run this sub-command again, or build with --update-lock, to pick up new base images.`,
+		Example: `
+  # Create or refresh .ko.lock.
+  ko lock
+
+  # Fail if .ko.lock is missing, does not match .ko.yaml, or a tag has moved, e.g. in CI.
+  ko lock --check`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if err := bo.LoadConfig(); err != nil {
+				return err
+			}
+			refs, err := configuredBases(bo)
+			if err != nil {
+				return err
+			}
+			refs = slices.DeleteFunc(refs, func(ref name.Reference) bool { return !lockable(ref) })
+			path := lockPath(bo)
+			old, err := readLock(path)
+			if err != nil {
+				return err
+			}
+
+			ropt := []remote.Option{
+				remote.WithAuthFromKeychain(keychain),
+				remote.WithUserAgent(ua()),
+				remote.WithContext(cmd.Context()),
+			}
+			if check {
+				return checkLock(path, old, refs, func(ref name.Reference) (lockedBase, error) {
+					return resolveLock(ref, ropt...)
+				})
+			}
+			lock := &baseLock{Bases: map[string]lockedBase{}}
+			for _, ref := range refs {
+				lb, err := resolveLock(ref, ropt...)
+				if err != nil {
+					return fmt.Errorf("resolving %s: %w", ref, err)
+				}
+				lock.Bases[ref.Name()] = lb
+				if old != nil && old.Bases[ref.Name()].Digest != lb.Digest {
+					log.Printf("Locked %s to %s (was %s)", ref.Name(), lb.Digest, old.Bases[ref.Name()].Digest)
+				} else {
+					log.Printf("Locked %s to %s", ref.Name(), lb.Digest)
+				}
+			}
+			return lock.write(path)
+		},
+	}
+	lock.Flags().BoolVar(&check, "check", false,
+		"Fail if .ko.lock is missing, does not record exactly the configured base images, or records digests that their tags no longer point to, instead of updating it.")
+	lock.Flags().BoolVar(&bo.InsecureRegistry, "insecure-registry", false,
+		"Whether to skip TLS verification on the registry")
+	topLevel.AddCommand(lock)
+}
@@ -0,0 +1,253 @@
+// Copyright 2026 ko Build Authors All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+
+	"github.com/google/ko/pkg/commands/options"
+)
+
+func TestBaseLock(t *testing.T) {
+	ctx := context.Background()
+	s, err := registryServerWithImage("base")
+	if err != nil {
+		t.Fatalf("could not create test registry server: %v", err)
+	}
+	defer s.Close()
+	reg := s.Listener.Addr().String()
+	baseImage := reg + "/base:latest"
+	tag, err := name.NewTag(baseImage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	amd, arm := mustRandom(), mustRandom()
+	if err := remote.WriteIndex(tag, platformIndex(t, amd, arm)); err != nil {
+		t.Fatal(err)
+	}
+	dig, err := crane.Digest(baseImage)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	lockPath := filepath.Join(dir, lockFileName)
+	bo := &options.BuildOptions{
+		BaseImage:        baseImage,
+		WorkingDirectory: dir,
+	}
+	getDigest := func(bo *options.BuildOptions, importpath string) (string, error) {
+		t.Helper()
+		ref, res, err := getBaseImage(bo)(ctx, importpath)
+		if err != nil {
+			return "", err
+		}
+		if got, want := ref.Name(), baseImage; got != want {
+			t.Errorf("getBaseImage() ref = %s, wanted %s", got, want)
+		}
+		d, err := res.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d.String(), nil
+	}
+
+	// Without a lock file, tags are resolved as usual.
+	if got, err := getDigest(bo, "ko://example.com/helloworld"); err != nil || got != dig {
+		t.Fatalf("getBaseImage() = %s, %v; wanted %s", got, err, dig)
+	}
+	if lock, err := readLock(lockPath); err != nil || lock != nil {
+		t.Fatalf("readLock() = %v, %v; wanted no lock file", lock, err)
+	}
+
+	// --update-lock creates the lock file.
+	bo.UpdateLock = true
+	if got, err := getDigest(bo, "ko://example.com/helloworld"); err != nil || got != dig {
+		t.Fatalf("getBaseImage() = %s, %v; wanted %s", got, err, dig)
+	}
+	lock, err := readLock(lockPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]lockedBase{
+		baseImage: {
+			Digest: dig,
+			Platforms: map[string]string{
+				"linux/amd64": mustDigest(amd).String(),
+				"linux/arm64": mustDigest(arm).String(),
+			},
+		},
+	}
+	if diff := cmp.Diff(want, lock.Bases); diff != "" {
+		t.Errorf("lock (-want +got) = %s", diff)
+	}
+
+	// Once locked, builds keep using the locked digest after the tag moves.
+	if err := crane.Push(mustRandom(), baseImage); err != nil {
+		t.Fatal(err)
+	}
+	bo.UpdateLock = false
+	if got, err := getDigest(bo, "ko://example.com/helloworld"); err != nil || got != dig {
+		t.Fatalf("getBaseImage() = %s, %v; wanted locked %s", got, err, dig)
+	}
+	resolve := func(ref name.Reference) (lockedBase, error) {
+		return resolveLock(ref)
+	}
+	refs, err := configuredBases(bo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkLock(lockPath, lock, refs, resolve); err == nil || !strings.Contains(err.Error(), "moved from "+dig) {
+		t.Errorf("checkLock() = %v, wanted the moved tag to be reported", err)
+	}
+
+	// Base images that aren't locked are an error.
+	bo.BaseImageOverrides = map[string]string{"example.com/other": reg + "/other:v1"}
+	if err := crane.Push(mustRandom(), reg+"/other:v1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := getDigest(bo, "ko://example.com/other"); err == nil || !strings.Contains(err.Error(), "is not recorded in") {
+		t.Fatalf("getBaseImage() = %v, wanted unlocked base to be rejected", err)
+	}
+	if refs, err = configuredBases(bo); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkLock(lockPath, lock, refs, resolve); err == nil || !strings.Contains(err.Error(), "other:v1 is not recorded") {
+		t.Errorf("checkLock() = %v, wanted stale lock to be reported", err)
+	}
+
+	// --update-lock picks up the new base image and records the new one.
+	bo.UpdateLock = true
+	newDig, err := crane.Digest(baseImage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := getDigest(bo, "ko://example.com/helloworld"); err != nil || got != newDig {
+		t.Fatalf("getBaseImage() = %s, %v; wanted %s", got, err, newDig)
+	}
+	if _, _, err := getBaseImage(bo)(ctx, "ko://example.com/other"); err != nil {
+		t.Fatalf("getBaseImage() = %v", err)
+	}
+	if lock, err = readLock(lockPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkLock(lockPath, lock, refs, resolve); err != nil {
+		t.Errorf("checkLock() = %v", err)
+	}
+	if got := lock.Bases[baseImage].Digest; got != newDig {
+		t.Errorf("locked digest = %s, wanted %s", got, newDig)
+	}
+}
+
+func TestCheckLock(t *testing.T) {
+	ref, err := name.ParseReference("alpine")
+	if err != nil {
+		t.Fatal(err)
+	}
+	refs := []name.Reference{ref}
+	current := lockedBase{
+		Digest:    "sha256:0000000000000000000000000000000000000000000000000000000000000001",
+		Platforms: map[string]string{"linux/amd64": "sha256:0000000000000000000000000000000000000000000000000000000000000002"},
+	}
+	resolve := func(name.Reference) (lockedBase, error) {
+		return current, nil
+	}
+	for _, c := range []struct {
+		desc    string
+		lock    *baseLock
+		wantErr string
+	}{{
+		desc:    "missing",
+		wantErr: "does not exist",
+	}, {
+		desc:    "missing base",
+		lock:    &baseLock{},
+		wantErr: "index.docker.io/library/alpine:latest is not recorded",
+	}, {
+		desc: "extra base",
+		lock: &baseLock{Bases: map[string]lockedBase{
+			"index.docker.io/library/alpine:latest": current,
+			"cgr.dev/chainguard/static:latest":      {},
+		}},
+		wantErr: "cgr.dev/chainguard/static:latest is no longer a base image",
+	}, {
+		desc: "moved",
+		lock: &baseLock{Bases: map[string]lockedBase{
+			"index.docker.io/library/alpine:latest": {
+				Digest:    "sha256:0000000000000000000000000000000000000000000000000000000000000003",
+				Platforms: current.Platforms,
+			},
+		}},
+		wantErr: "alpine:latest moved from sha256:0000000000000000000000000000000000000000000000000000000000000003",
+	}, {
+		desc: "wrong platforms",
+		lock: &baseLock{Bases: map[string]lockedBase{
+			"index.docker.io/library/alpine:latest": {
+				Digest:    current.Digest,
+				Platforms: map[string]string{"linux/arm64": current.Platforms["linux/amd64"]},
+			},
+		}},
+		wantErr: "wrong digests for linux/amd64, linux/arm64",
+	}, {
+		desc: "up to date",
+		lock: &baseLock{Bases: map[string]lockedBase{
+			"index.docker.io/library/alpine:latest": current,
+		}},
+	}} {
+		t.Run(c.desc, func(t *testing.T) {
+			err := checkLock(lockFileName, c.lock, refs, resolve)
+			switch {
+			case c.wantErr == "" && err != nil:
+				t.Errorf("checkLock() = %v", err)
+			case c.wantErr != "" && (err == nil || !strings.Contains(err.Error(), c.wantErr)):
+				t.Errorf("checkLock() = %v, wanted %q", err, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestLockPath(t *testing.T) {
+	wd, configDir := t.TempDir(), t.TempDir()
+	if err := os.WriteFile(filepath.Join(configDir, ".ko.yaml"), []byte("defaultBaseImage: alpine\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	bo := &options.BuildOptions{WorkingDirectory: wd}
+	if err := bo.LoadConfig(); err != nil {
+		t.Fatalf("LoadConfig() = %v", err)
+	}
+	if got, want := lockPath(bo), filepath.Join(wd, lockFileName); got != want {
+		t.Errorf("lockPath() = %s, wanted %s", got, want)
+	}
+
+	// The lock file is next to the .ko.yaml that KO_CONFIG_PATH names.
+	t.Setenv("KO_CONFIG_PATH", filepath.Join(configDir, ".ko.yaml"))
+	bo = &options.BuildOptions{WorkingDirectory: wd}
+	if err := bo.LoadConfig(); err != nil {
+		t.Fatalf("LoadConfig() = %v", err)
+	}
+	if got, want := lockPath(bo), filepath.Join(configDir, lockFileName); got != want {
+		t.Errorf("lockPath() = %s, wanted %s", got, want)
+	}
+}
@@ -18,8 +18,10 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
 	"reflect"
+	"strings"
 
 	"github.com/go-viper/mapstructure/v2"
 	"github.com/google/go-containerregistry/pkg/name"
@@ -78,6 +80,27 @@ type BuildOptions struct {
 
 	InsecureRegistry bool
 
+	// ConfigDirectory is the directory of the `.ko.yaml` that LoadConfig
+	// read, which is where `.ko.lock` is kept.  LoadConfig sets it to
+	// WorkingDirectory if there is no `.ko.yaml`.
+	ConfigDirectory string
+
+	// UpdateLock resolves base image tags again and records the result in
+	// `.ko.lock`, instead of using the digests already recorded there.
+	UpdateLock bool
+
+	// Offline resolves base images only from the image cache in KOCACHE,
+	// failing instead of contacting registries.
+	Offline bool
+
+	// Compression is how the layers ko builds are compressed: gzip, zstd or
+	// estargz.  Empty means the value in `.ko.yaml`, or gzip.
+	Compression string
+
+	// CompressionLevel is the level layers are compressed at.  Zero means
+	// the value in `.ko.yaml`, or the compression's default level.
+	CompressionLevel int
+
 	// Trimpath controls whether ko adds the `-trimpath` flag to `go build` by default.
 	// The `-trimpath` flags aids in achieving reproducible builds, but it removes path information that is useful for interactive debugging.
 	// Set this field to `false` and `DisableOptimizations` to `true` if you want to interactively debug the binary in the resulting image.
@@ -86,6 +109,63 @@ type BuildOptions struct {
 
 	// BuildConfigs stores the per-image build config from `.ko.yaml`.
 	BuildConfigs map[string]build.Config
+
+	// LicensePolicy stores the dependency license policy from `.ko.yaml`.
+	LicensePolicy *build.LicensePolicy
+
+	// BaseImagePolicy stores the base image policy from `.ko.yaml`.
+	BaseImagePolicy *BaseImagePolicy
+}
+
+// BaseImagePolicy restricts which base images may be built upon.
+type BaseImagePolicy struct {
+	// AllowedRegistries lists the registries base images may be pulled from,
+	// e.g. `cgr.dev` or `docker.io`.
+	AllowedRegistries []string `yaml:"allowedRegistries,omitempty"`
+
+	// AllowedRepositories lists the repositories base images may be pulled
+	// from.  Entries are matched against the fully qualified repository name
+	// (e.g. `index.docker.io/library/alpine`) using path.Match patterns, and
+	// an entry ending in `/**` matches every repository beneath it.
+	//
+	// When neither AllowedRegistries nor AllowedRepositories is set, base
+	// images may come from anywhere.  Otherwise a base image must match at
+	// least one entry of either list.
+	AllowedRepositories []string `yaml:"allowedRepositories,omitempty"`
+
+	// RequireDigest requires base images to be referenced by digest.
+	RequireDigest bool `yaml:"requireDigest,omitempty"`
+
+	// PublicKey is the path to a PEM-encoded public key.  When set, base
+	// images must have a cosign signature that verifies with this key.
+	PublicKey string `yaml:"publicKey,omitempty"`
+}
+
+// fileBaseImageSchemes are the prefixes of base images that are loaded from
+// files or containerd rather than pulled from a registry.
+var fileBaseImageSchemes = []string{"oci-layout://", "oci-archive://", "docker-archive://", "containerd://"}
+
+// IsFileBaseImage returns whether base names a base image that is loaded from
+// an OCI image layout, an image archive or containerd's image store rather
+// than pulled from a registry.
+func IsFileBaseImage(base string) bool {
+	for _, scheme := range fileBaseImageSchemes {
+		if strings.HasPrefix(base, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+func validateBaseImage(base string) error {
+	if IsFileBaseImage(base) {
+		if _, path, _ := strings.Cut(base, "://"); path == "" {
+			return errors.New("missing path")
+		}
+		return nil
+	}
+	_, err := name.ParseReference(base)
+	return err
 }
 
 func AddBuildOptions(cmd *cobra.Command, bo *BuildOptions) {
@@ -109,17 +189,21 @@ func AddBuildOptions(cmd *cobra.Command, bo *BuildOptions) {
 		"The default user the image should be run as.")
 	cmd.Flags().BoolVar(&bo.Debug, "debug", bo.Debug,
 		"Include Delve debugger into image and wrap around ko-app. This debugger will listen to port 40000.")
+	cmd.Flags().BoolVar(&bo.UpdateLock, "update-lock", bo.UpdateLock,
+		"Resolve base image tags and update the digests recorded in .ko.lock, instead of using the recorded digests.")
+	cmd.Flags().BoolVar(&bo.Offline, "offline", bo.Offline,
+		"Resolve base images only from the image cache in KOCACHE, without contacting registries (see ko cache warm).")
+	cmd.Flags().StringVar(&bo.Compression, "compression", bo.Compression,
+		"How to compress the layers ko builds: gzip, zstd or estargz (default gzip).")
+	cmd.Flags().IntVar(&bo.CompressionLevel, "compression-level", bo.CompressionLevel,
+		"The level to compress layers at: 1-9 for gzip and estargz, 1-22 for zstd (default the compression's default).")
 	bo.Trimpath = true
 }
 
-// LoadConfig reads build configuration from defaults, environment variables, and the `.ko.yaml` config file.
-func (bo *BuildOptions) LoadConfig() error {
+// readConfig reads the `.ko.yaml` config file in workingDirectory, or at
+// $KO_CONFIG_PATH, if there is one.
+func readConfig(workingDirectory string) (*viper.Viper, error) {
 	v := viper.New()
-	if bo.WorkingDirectory == "" {
-		bo.WorkingDirectory = "."
-	}
-	// If omitted, use this base image.
-	v.SetDefault("defaultBaseImage", configDefaultBaseImage)
 	const configName = ".ko"
 
 	v.SetConfigName(configName) // .yaml is implicit
@@ -130,7 +214,7 @@ func (bo *BuildOptions) LoadConfig() error {
 		/* #nosec G304 G703 -- KO_CONFIG_PATH is intentionally user-controlled. */
 		file, err := os.Stat(override)
 		if err != nil {
-			return fmt.Errorf("error looking for config file: %w", err)
+			return nil, fmt.Errorf("error looking for config file: %w", err)
 		}
 		if file.Mode().IsRegular() {
 			v.SetConfigFile(override)
@@ -139,24 +223,49 @@ func (bo *BuildOptions) LoadConfig() error {
 			/* #nosec G304 G703 -- path is derived from the user-controlled KO_CONFIG_PATH. */
 			file, err = os.Stat(path)
 			if err != nil {
-				return fmt.Errorf("error looking for config file: %w", err)
+				return nil, fmt.Errorf("error looking for config file: %w", err)
 			}
 			if file.Mode().IsRegular() {
 				v.SetConfigFile(path)
 			} else {
-				return fmt.Errorf("config file %s is not a regular file", path)
+				return nil, fmt.Errorf("config file %s is not a regular file", path)
 			}
 		} else {
-			return fmt.Errorf("config file %s is not a regular file", override)
+			return nil, fmt.Errorf("config file %s is not a regular file", override)
 		}
 	}
-	v.AddConfigPath(bo.WorkingDirectory)
+	v.AddConfigPath(workingDirectory)
 
 	if err := v.ReadInConfig(); err != nil {
 		if !errors.As(err, &viper.ConfigFileNotFoundError{}) {
-			return fmt.Errorf("error reading config file: %w", err)
+			return nil, fmt.Errorf("error reading config file: %w", err)
 		}
 	}
+	return v, nil
+}
+
+// useYAMLTagsAndUnmarshallers decodes config sections with the yaml tags and
+// UnmarshalYAML methods of their types.
+func useYAMLTagsAndUnmarshallers(c *mapstructure.DecoderConfig) {
+	c.TagName = "yaml" // defaults to `mapstructure:""`
+	c.DecodeHook = yamlUnmarshallerHookFunc
+}
+
+// LoadConfig reads build configuration from defaults, environment variables, and the `.ko.yaml` config file.
+func (bo *BuildOptions) LoadConfig() error {
+	if bo.WorkingDirectory == "" {
+		bo.WorkingDirectory = "."
+	}
+	v, err := readConfig(bo.WorkingDirectory)
+	if err != nil {
+		return err
+	}
+	bo.ConfigDirectory = bo.WorkingDirectory
+	if f := v.ConfigFileUsed(); f != "" {
+		bo.ConfigDirectory = filepath.Dir(f)
+	}
+	// If omitted, use this base image.
+	v.SetDefault("defaultBaseImage", configDefaultBaseImage)
 
 	if dp := v.GetStringSlice("defaultPlatforms"); len(dp) > 0 {
 		bo.DefaultPlatforms = dp
@@ -174,9 +283,19 @@ func (bo *BuildOptions) LoadConfig() error {
 		bo.DefaultLdflags = ldflags
 	}
 
+	if bo.Compression == "" {
+		bo.Compression = v.GetString("compression")
+	}
+	if bo.CompressionLevel == 0 {
+		bo.CompressionLevel = v.GetInt("compressionLevel")
+	}
+	if _, err := build.ParseCompression(bo.Compression, bo.CompressionLevel); err != nil {
+		return fmt.Errorf("'compression': %w", err)
+	}
+
 	if bo.BaseImage == "" {
 		ref := v.GetString("defaultBaseImage")
-		if _, err := name.ParseReference(ref); err != nil {
+		if err := validateBaseImage(ref); err != nil {
 			return fmt.Errorf("'defaultBaseImage': error parsing %q as image reference: %w", ref, err)
 		}
 		bo.BaseImage = ref
@@ -186,7 +305,7 @@ func (bo *BuildOptions) LoadConfig() error {
 		baseImageOverrides := map[string]string{}
 		overrides := v.GetStringMapString("baseImageOverrides")
 		for key, value := range overrides {
-			if _, err := name.ParseReference(value); err != nil {
+			if err := validateBaseImage(value); err != nil {
 				return fmt.Errorf("'baseImageOverrides': error parsing %q as image reference: %w", value, err)
 			}
 			baseImageOverrides[key] = value
@@ -196,10 +315,6 @@ func (bo *BuildOptions) LoadConfig() error {
 
 	if len(bo.BuildConfigs) == 0 {
 		var builds []build.Config
-		useYAMLTagsAndUnmarshallers := func(c *mapstructure.DecoderConfig) {
-			c.TagName = "yaml" // defaults to `mapstructure:""`
-			c.DecodeHook = yamlUnmarshallerHookFunc
-		}
 		if err := v.UnmarshalKey("builds", &builds, useYAMLTagsAndUnmarshallers); err != nil {
 			return fmt.Errorf("configuration section 'builds' cannot be parsed: %w", err)
 		}
@@ -210,6 +325,30 @@ func (bo *BuildOptions) LoadConfig() error {
 		bo.BuildConfigs = buildConfigs
 	}
 
+	if bo.LicensePolicy == nil && v.IsSet("licensePolicy") {
+		var policy build.LicensePolicy
+		if err := v.UnmarshalKey("licensePolicy", &policy, useYAMLTagsAndUnmarshallers); err != nil {
+			return fmt.Errorf("configuration section 'licensePolicy' cannot be parsed: %w", err)
+		}
+		bo.LicensePolicy = &policy
+	}
+
+	if bo.BaseImagePolicy == nil && v.IsSet("baseImagePolicy") {
+		var policy BaseImagePolicy
+		if err := v.UnmarshalKey("baseImagePolicy", &policy, useYAMLTagsAndUnmarshallers); err != nil {
+			return fmt.Errorf("configuration section 'baseImagePolicy' cannot be parsed: %w", err)
+		}
+		for _, repo := range policy.AllowedRepositories {
+			if _, err := path.Match(repo, ""); err != nil {
+				return fmt.Errorf("'baseImagePolicy': invalid repository pattern %q: %w", repo, err)
+			}
+		}
+		if policy.PublicKey != "" && !filepath.IsAbs(policy.PublicKey) {
+			policy.PublicKey = filepath.Join(bo.ConfigDirectory, policy.PublicKey)
+		}
+		bo.BaseImagePolicy = &policy
+	}
+
 	return nil
 }
 
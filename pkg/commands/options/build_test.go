@@ -16,6 +16,7 @@ package options
 
 import (
 	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
@@ -40,6 +41,27 @@ func TestDefaultBaseImage(t *testing.T) {
 	}
 }
 
+func TestFileBaseImages(t *testing.T) {
+	dir := t.TempDir()
+	config := `defaultBaseImage: oci-layout://build/layout:v1
+baseImageOverrides:
+  example.com/app: docker-archive://base.tar
+`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".ko.yaml"), []byte(config), 0o644))
+	bo := &BuildOptions{
+		WorkingDirectory: dir,
+	}
+	require.NoError(t, bo.LoadConfig())
+	require.Equal(t, "oci-layout://build/layout:v1", bo.BaseImage)
+	require.Equal(t, map[string]string{"example.com/app": "docker-archive://base.tar"}, bo.BaseImageOverrides)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".ko.yaml"), []byte("defaultBaseImage: oci-archive://\n"), 0o644))
+	bo = &BuildOptions{
+		WorkingDirectory: dir,
+	}
+	require.ErrorContains(t, bo.LoadConfig(), "missing path")
+}
+
 func TestDefaultPlatformsAll(t *testing.T) {
 	allBo := &BuildOptions{
 		WorkingDirectory: "testdata/config",
@@ -95,6 +117,63 @@ func TestDefaultLdFlags(t *testing.T) {
 	require.Equal(t, []string{"-s -w"}, bo.DefaultLdflags)
 }
 
+func TestCompression(t *testing.T) {
+	bo := &BuildOptions{
+		WorkingDirectory: "testdata/config",
+	}
+	err := bo.LoadConfig()
+	require.NoError(t, err)
+	require.Equal(t, "zstd", bo.Compression)
+	require.Equal(t, 19, bo.CompressionLevel)
+
+	// Flags take precedence over .ko.yaml.
+	bo = &BuildOptions{
+		WorkingDirectory: "testdata/config",
+		Compression:      "gzip",
+	}
+	require.ErrorContains(t, bo.LoadConfig(), "gzip compression level must be between 1 and 9")
+}
+
+func TestLicensePolicy(t *testing.T) {
+	bo := &BuildOptions{
+		WorkingDirectory: "testdata/config",
+	}
+	err := bo.LoadConfig()
+	require.NoError(t, err)
+	require.Equal(t, &build.LicensePolicy{
+		Allow: []string{"Apache-2.0", "MIT"},
+		Deny:  []string{"GPL-3.0-only"},
+	}, bo.LicensePolicy)
+}
+
+func TestBaseImagePolicy(t *testing.T) {
+	bo := &BuildOptions{
+		WorkingDirectory: "testdata/config",
+	}
+	err := bo.LoadConfig()
+	require.NoError(t, err)
+	publicKey, err := filepath.Abs(filepath.Join("testdata/config", "cosign.pub"))
+	require.NoError(t, err)
+	require.Equal(t, &BaseImagePolicy{
+		AllowedRegistries:   []string{"cgr.dev"},
+		AllowedRepositories: []string{"index.docker.io/library/alpine"},
+		RequireDigest:       true,
+		PublicKey:           publicKey,
+	}, bo.BaseImagePolicy)
+}
+
+func TestBaseImagePolicyConfigPath(t *testing.T) {
+	// A relative public key is found next to the .ko.yaml, wherever
+	// KO_CONFIG_PATH puts it.
+	t.Setenv("KO_CONFIG_PATH", "testdata/config")
+	bo := &BuildOptions{
+		WorkingDirectory: t.TempDir(),
+	}
+	err := bo.LoadConfig()
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join("testdata/config", "cosign.pub"), bo.BaseImagePolicy.PublicKey)
+}
+
 func TestBuildConfigWithWorkingDirectoryAndDirAndMain(t *testing.T) {
 	bo := &BuildOptions{
 		WorkingDirectory: "testdata/paths",
@@ -0,0 +1,190 @@
+// Copyright 2026 ko Build Authors All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/google/ko/pkg/internal/git"
+	"github.com/google/ko/pkg/publish"
+	"golang.org/x/tools/go/packages"
+)
+
+// parseImageName parses an imageName template of `.ko.yaml`.
+func parseImageName(text string) (*template.Template, error) {
+	return template.New("imageName").Option("missingkey=error").Parse(text)
+}
+
+// imageNameData is what imageName templates are executed with.  The module
+// and git information is only looked up for templates that use it.
+type imageNameData struct {
+	// Base is the repository that images are pushed to, like KO_DOCKER_REPO.
+	Base string
+	// ImportPath is the import path of the main package of the image.
+	ImportPath string
+
+	ctx context.Context
+	n   *templateNamer
+}
+
+// ModulePath is the path of the module that contains the main package.
+func (d imageNameData) ModulePath() (string, error) {
+	m, err := d.n.module(d.ctx, d.ImportPath)
+	if err != nil {
+		return "", err
+	}
+	return m.Path, nil
+}
+
+// RelativePath is the import path of the main package within its module,
+// which is empty for the package at the root of the module.
+func (d imageNameData) RelativePath() (string, error) {
+	m, err := d.n.module(d.ctx, d.ImportPath)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimPrefix(strings.TrimPrefix(d.ImportPath, m.Path), "/"), nil
+}
+
+// Git is the git information of the module that contains the main package,
+// with the same keys as the templates of builds.
+func (d imageNameData) Git() (map[string]any, error) {
+	dir := d.n.workingDirectory
+	if m, err := d.n.module(d.ctx, d.ImportPath); err == nil && m.Dir != "" {
+		dir = m.Dir
+	}
+	info, err := git.GetInfo(d.ctx, dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading git information of %s: %w", dir, err)
+	}
+	return info.TemplateValue(), nil
+}
+
+// templateNamer names images with the imageName templates of `.ko.yaml`.
+type templateNamer struct {
+	workingDirectory string
+	// defaultTemplate names the images that overrides doesn't.
+	defaultTemplate *template.Template
+	// overrides are the templates of the builds that have their own.
+	overrides map[string]*template.Template
+	// fallback names the images that no template does.
+	fallback publish.Namer
+
+	mu    sync.Mutex
+	names map[string]string
+	// errs are why the images of import paths couldn't be named, keyed by
+	// lower-cased import path, as publishers name images.
+	errs map[string]error
+	// modules has a *moduleLookup per import path, so that each is only
+	// loaded once, without holding up the naming of other images.
+	modules sync.Map
+}
+
+// moduleLookup is the module of an import path, once it is loaded.
+type moduleLookup struct {
+	once sync.Once
+	m    *packages.Module
+	err  error
+}
+
+// newTemplateNamer returns a namer of images from the overrides of import
+// paths and the default template, falling back to fallback.  The templates
+// were validated when they were loaded from `.ko.yaml`.
+func newTemplateNamer(workingDirectory, defaultTemplate string, overrides map[string]string, fallback publish.Namer) *templateNamer {
+	n := &templateNamer{
+		workingDirectory: workingDirectory,
+		overrides:        map[string]*template.Template{},
+		fallback:         fallback,
+		names:            map[string]string{},
+		errs:             map[string]error{},
+	}
+	if defaultTemplate != "" {
+		n.defaultTemplate = template.Must(parseImageName(defaultTemplate))
+	}
+	for importpath, text := range overrides {
+		n.overrides[importpath] = template.Must(parseImageName(text))
+	}
+	return n
+}
+
+// name returns the name of the image of importpath.  Namers can't fail, so
+// when the template does, the name is empty and err returns why.
+func (n *templateNamer) name(ctx context.Context, base, importpath string) string {
+	tmpl, ok := n.overrides[importpath]
+	if !ok {
+		tmpl = n.defaultTemplate
+	}
+	if tmpl == nil {
+		return n.fallback(base, importpath)
+	}
+
+	key := base + "\x00" + importpath
+	n.mu.Lock()
+	name, ok := n.names[key]
+	n.mu.Unlock()
+	if ok {
+		return name
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, imageNameData{Base: base, ImportPath: importpath, ctx: ctx, n: n}); err != nil {
+		n.mu.Lock()
+		n.errs[strings.ToLower(importpath)] = fmt.Errorf("naming the image of %s: %w", importpath, err)
+		n.mu.Unlock()
+		return ""
+	}
+	// Cleaning drops the trailing slash that an empty RelativePath leaves.
+	name = path.Clean(strings.TrimSpace(buf.String()))
+	n.mu.Lock()
+	n.names[key] = name
+	n.mu.Unlock()
+	return name
+}
+
+// err returns why the image of importpath couldn't be named, if it couldn't.
+func (n *templateNamer) err(importpath string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.errs[strings.ToLower(importpath)]
+}
+
+// module returns the module that contains importpath.
+func (n *templateNamer) module(ctx context.Context, importpath string) (*packages.Module, error) {
+	v, _ := n.modules.LoadOrStore(importpath, &moduleLookup{})
+	l := v.(*moduleLookup)
+	l.once.Do(func() {
+		l.m, l.err = n.loadModule(ctx, importpath)
+	})
+	return l.m, l.err
+}
+
+func (n *templateNamer) loadModule(ctx context.Context, importpath string) (*packages.Module, error) {
+	pkgs, err := packages.Load(&packages.Config{Context: ctx, Mode: packages.NeedName | packages.NeedModule, Dir: n.workingDirectory}, importpath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load package %s: %w", importpath, err)
+	}
+	if len(pkgs) == 1 && len(pkgs[0].Errors) > 0 {
+		return nil, fmt.Errorf("failed to load package %s: %w", importpath, pkgs[0].Errors[0])
+	}
+	if len(pkgs) != 1 || pkgs[0].Module == nil {
+		return nil, fmt.Errorf("package %s is not in a module", importpath)
+	}
+	return pkgs[0].Module, nil
+}
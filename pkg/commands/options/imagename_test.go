@@ -0,0 +1,102 @@
+// Copyright 2026 ko Build Authors All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestImageNameConfig(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":               "module example.com/app\n\ngo 1.21\n",
+		"main.go":              "package main\n\nfunc main() {}\n",
+		"team/service/main.go": "package main\n\nfunc main() {}\n",
+		"team/worker/main.go":  "package main\n\nfunc main() {}\n",
+		".ko.yaml": `imageName: "{{.Base}}/{{.ModulePath}}/{{.RelativePath}}"
+builds:
+- main: ./team/worker
+  image_name: "{{.Base}}/workers/{{.RelativePath}}"
+`,
+	}
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	po := &PublishOptions{WorkingDirectory: dir}
+	if err := po.LoadConfig(); err != nil {
+		t.Fatalf("LoadConfig() = %v", err)
+	}
+	namer := MakeNamer(context.Background(), po)
+	for importpath, want := range map[string]string{
+		"example.com/app/team/service": "registry.example.com/example.com/app/team/service",
+		"example.com/app/team/worker":  "registry.example.com/workers/team/worker",
+		// The empty relative path doesn't leave a trailing slash.
+		"example.com/app": "registry.example.com/example.com/app",
+	} {
+		if got := namer("registry.example.com", importpath); got != want {
+			t.Errorf("namer(%s) = %s, wanted %s", importpath, got, want)
+		}
+	}
+
+	if err := po.ImageNameError("example.com/app"); err != nil {
+		t.Errorf("ImageNameError() = %v", err)
+	}
+
+	// Naming fails for packages that don't exist, and says why.
+	if got := namer("registry.example.com", "example.com/app/missing"); got != "" {
+		t.Errorf("namer(missing) = %s, wanted empty", got)
+	}
+	if err := po.ImageNameError("example.com/app/missing"); err == nil || !strings.Contains(err.Error(), "example.com/app/missing") {
+		t.Errorf("ImageNameError(missing) = %v, wanted an error loading the package", err)
+	}
+
+	// Naming fails without the git information the template uses.
+	if err := os.WriteFile(filepath.Join(dir, ".ko.yaml"), []byte("imageName: \"{{.Base}}/{{.Git.ShortCommit}}\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	po = &PublishOptions{WorkingDirectory: dir}
+	if err := po.LoadConfig(); err != nil {
+		t.Fatalf("LoadConfig() = %v", err)
+	}
+	if got := MakeNamer(context.Background(), po)("registry.example.com", "example.com/app"); got != "" {
+		t.Errorf("namer() = %s outside of a git repository, wanted empty", got)
+	}
+	if err := po.ImageNameError("example.com/app"); err == nil || !strings.Contains(err.Error(), "git") {
+		t.Errorf("ImageNameError() = %v outside of a git repository, wanted a git error", err)
+	}
+
+	for _, bad := range []string{
+		"imageName: \"{{.Base\"\n",
+		"builds:\n- image_name: \"{{end}}\"\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, ".ko.yaml"), []byte(bad), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := (&PublishOptions{WorkingDirectory: dir}).LoadConfig(); err == nil {
+			t.Errorf("LoadConfig(%q) = nil, wanted an error", bad)
+		}
+	}
+}
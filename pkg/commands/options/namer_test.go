@@ -15,6 +15,7 @@
 package options_test
 
 import (
+	"context"
 	"path"
 	"testing"
 
@@ -24,7 +25,7 @@ import (
 func TestMakeNamer(t *testing.T) {
 	foreachTestCaseMakeNamer(func(tc testMakeNamerCase) {
 		t.Run(tc.name, func(t *testing.T) {
-			namer := options.MakeNamer(&tc.opts)
+			namer := options.MakeNamer(context.Background(), &tc.opts)
 			got := namer("registry.example.org/foo/bar", "example.org/sample/cmd/example")
 
 			if got != tc.want {
@@ -62,6 +63,33 @@ func testMakeNamerCases() []testMakeNamerCase {
 		opts: options.PublishOptions{ImageNamer: func(base string, importpath string) string {
 			return base + "-" + path.Base(importpath)
 		}},
+	}, {
+		name: "with image name template",
+		want: "registry.example.org/foo/bar/sample/example.org/sample/cmd/example",
+		opts: options.PublishOptions{ImageNameTemplate: "{{.Base}}/sample/{{.ImportPath}}"},
+	}, {
+		name: "with image name template and bare",
+		want: "registry.example.org/foo/bar",
+		opts: options.PublishOptions{ImageNameTemplate: "{{.Base}}/sample", Bare: true},
+	}, {
+		name: "with image name override",
+		want: "registry.example.org/foo/bar/override",
+		opts: options.PublishOptions{
+			ImageNameTemplate: "{{.Base}}/sample",
+			ImageNameOverrides: map[string]string{
+				"example.org/sample/cmd/example": "{{.Base}}/override",
+			},
+			Bare: true,
+		},
+	}, {
+		name: "with image name override of another import path",
+		want: "registry.example.org/foo/bar/example",
+		opts: options.PublishOptions{
+			ImageNameOverrides: map[string]string{
+				"example.org/sample/cmd/other": "{{.Base}}/override",
+			},
+			BaseImportPaths: true,
+		},
 	}}
 }
 
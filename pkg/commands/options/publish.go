@@ -15,14 +15,21 @@
 package options
 
 import (
+	"context"
 	"crypto/md5" // nolint: gosec // No strong cryptography needed.
 	"encoding/hex"
+	"errors"
+	"fmt"
 	"os"
 	"path"
+	"slices"
 
+	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/google/go-containerregistry/pkg/v1/daemon"
+	"github.com/google/ko/pkg/build"
 	"github.com/google/ko/pkg/publish"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 // PublishOptions encapsulates options when publishing.
@@ -46,6 +53,8 @@ type PublishOptions struct {
 	Tags []string
 	// TagOnly resolves images into tag-only references.
 	TagOnly bool
+	// ImmutableTags refuses to move tags that already point to other images.
+	ImmutableTags bool
 
 	// Push publishes images to a registry.
 	Push bool
@@ -53,11 +62,22 @@ type PublishOptions struct {
 	// Local publishes images to a local docker daemon.
 	Local            bool
 	InsecureRegistry bool
+	// LocalPlatform is the platform of the image to load into the local
+	// daemon or kind nodes from multi-platform images.  If unset, it is the
+	// platform of the daemon or the nodes.
+	LocalPlatform string
 
 	OCILayoutPath string
 	TarballFile   string
+	// TarballFormat is the format of TarballFile: docker or oci.
+	TarballFormat string
+	// IgnoreOutputErrors only logs the errors of writing OCILayoutPath and
+	// TarballFile while pushing.
+	IgnoreOutputErrors bool
 
 	ImageRefsFile string
+	// ImageRefsFormat is the format of ImageRefsFile: text or json.
+	ImageRefsFormat string
 
 	// PreserveImportPaths preserves the full import path after KO_DOCKER_REPO.
 	PreserveImportPaths bool
@@ -68,8 +88,56 @@ type PublishOptions struct {
 	// ImageNamer can be used to pass a custom image name function. When given
 	// PreserveImportPaths, BaseImportPaths, Bare has no effect.
 	ImageNamer publish.Namer
+	// ImageNameTemplate is the Go template that names images, from the
+	// `imageName` of `.ko.yaml`.  PreserveImportPaths, BaseImportPaths and
+	// Bare take precedence over it.
+	ImageNameTemplate string
+	// ImageNameOverrides are the image name templates of import paths, from
+	// the `image_name` of the builds in `.ko.yaml`.  They take precedence over
+	// everything but ImageNamer.
+	ImageNameOverrides map[string]string
+	// imageNames is shared by the namers of MakeNamer, so that
+	// ImageNameError sees why any of them failed.
+	imageNames *templateNamer
 
 	Jobs int
+
+	// WorkingDirectory is where the `.ko.yaml` config file is looked for.
+	WorkingDirectory string
+	// Destinations are more registries to push images to, from the
+	// `publish.destinations` section of `.ko.yaml`.
+	Destinations []PublishDestination
+}
+
+// PublishDestination is a registry to push images to, with naming and tags
+// of its own.
+type PublishDestination struct {
+	// Repo is the repository to push to, like KO_DOCKER_REPO.
+	Repo string `yaml:"repo"`
+	// Naming is how images are named under Repo: md5, preserve-import-paths,
+	// base-import-paths or bare.  It defaults to the naming of the command
+	// line.
+	Naming string `yaml:"naming"`
+	// Tags are the tags to push, which default to --tags.
+	Tags []string `yaml:"tags"`
+	// Insecure skips TLS verification of the registry.
+	Insecure bool `yaml:"insecure"`
+	// Keychain is where to find credentials for the registry: docker,
+	// ecr, google, github, azure or anonymous.  It defaults to all of them
+	// but anonymous.
+	Keychain string `yaml:"keychain"`
+	// Primary makes the references to this destination's images the ones
+	// that ko prints and resolves YAML with.  It defaults to KO_DOCKER_REPO,
+	// or to the first destination.
+	Primary bool `yaml:"primary"`
+}
+
+// namers are the naming strategies of destinations.
+var namers = map[string]publish.Namer{
+	"md5":                   packageWithMD5,
+	"preserve-import-paths": preserveImportPath,
+	"base-import-paths":     baseImportPaths,
+	"bare":                  bareDockerRepo,
 }
 
 func AddPublishArg(cmd *cobra.Command, po *PublishOptions) {
@@ -84,19 +152,31 @@ func AddPublishArg(cmd *cobra.Command, po *PublishOptions) {
 			"(may not work properly with --base-import-paths or --bare).")
 	cmd.Flags().BoolVar(&po.TagOnly, "tag-only", false,
 		"Include tags but not digests in resolved image references. Useful when digests are not preserved when images are repopulated.")
+	cmd.Flags().BoolVar(&po.ImmutableTags, "immutable-tags", po.ImmutableTags,
+		"Fail instead of moving tags that already point to other images in the registry, such as release tags.")
 
 	cmd.Flags().BoolVar(&po.Push, "push", true, "Push images to KO_DOCKER_REPO")
 
 	cmd.Flags().BoolVarP(&po.Local, "local", "L", po.Local,
 		"Load into images to local docker daemon.")
+	cmd.Flags().StringVar(&po.LocalPlatform, "local-platform", po.LocalPlatform,
+		"Platform of the image to load into the local daemon or kind from multi-platform images, such as linux/arm64. "+
+			"Defaults to the platform of the daemon or the kind nodes.")
 	cmd.Flags().BoolVar(&po.InsecureRegistry, "insecure-registry", po.InsecureRegistry,
 		"Whether to skip TLS verification on the registry")
 
 	cmd.Flags().StringVar(&po.OCILayoutPath, "oci-layout-path", "", "Path to save the OCI image layout of the built images")
 	cmd.Flags().StringVar(&po.TarballFile, "tarball", "", "File to save images tarballs")
+	cmd.Flags().StringVar(&po.TarballFormat, "tarball-format", "docker",
+		"Format of the --tarball file: docker for a docker-archive, or oci for an oci-archive, which can also hold multi-platform images.")
+	cmd.Flags().BoolVar(&po.IgnoreOutputErrors, "ignore-output-errors", po.IgnoreOutputErrors,
+		"Only log the errors of writing --oci-layout-path and --tarball instead of failing, as long as pushing succeeds.")
 
 	cmd.Flags().StringVar(&po.ImageRefsFile, "image-refs", "",
 		"Path to file where a list of the published image references will be written.")
+	cmd.Flags().StringVar(&po.ImageRefsFormat, "image-refs-format", "text",
+		"Format of the --image-refs file: text for one reference per line, or json for the reference, tags, "+
+			"platforms, SBOM, signature and attestation references of the image of each import path.")
 
 	cmd.Flags().BoolVarP(&po.PreserveImportPaths, "preserve-import-paths", "P", po.PreserveImportPaths,
 		"Whether to preserve the full import path after KO_DOCKER_REPO.")
@@ -124,15 +204,134 @@ func bareDockerRepo(base, _ string) string {
 	return base
 }
 
-func MakeNamer(po *PublishOptions) publish.Namer {
+// MakeDestinationNamer returns the namer of the destination d.
+func MakeDestinationNamer(ctx context.Context, po *PublishOptions, d PublishDestination) publish.Namer {
+	if n, ok := namers[d.Naming]; ok {
+		return n
+	}
+	return MakeNamer(ctx, po)
+}
+
+// LoadConfig reads the image names and the destinations to publish to from
+// the `.ko.yaml` config file, unless they are already set.
+func (po *PublishOptions) LoadConfig() error {
+	if po.WorkingDirectory == "" {
+		po.WorkingDirectory = "."
+	}
+	v, err := readConfig(po.WorkingDirectory)
+	if err != nil {
+		return err
+	}
+
+	if po.ImageNameTemplate == "" {
+		po.ImageNameTemplate = v.GetString("imageName")
+	}
+	if po.ImageNameTemplate != "" {
+		if _, err := parseImageName(po.ImageNameTemplate); err != nil {
+			return fmt.Errorf("'imageName': %w", err)
+		}
+	}
+
+	if len(po.ImageNameOverrides) == 0 {
+		overrides, err := loadImageNameOverrides(v, po.WorkingDirectory)
+		if err != nil {
+			return err
+		}
+		po.ImageNameOverrides = overrides
+	}
+
+	if len(po.Destinations) > 0 {
+		return nil
+	}
+	var destinations []PublishDestination
+	if err := v.UnmarshalKey("publish.destinations", &destinations, useYAMLTagsAndUnmarshallers); err != nil {
+		return fmt.Errorf("configuration section 'publish.destinations' cannot be parsed: %w", err)
+	}
+	primaries := 0
+	for _, d := range destinations {
+		if d.Repo == "" {
+			return errors.New("'publish.destinations': repo is required")
+		}
+		if _, err := name.NewRepository(d.Repo); err != nil {
+			return fmt.Errorf("'publish.destinations': failed to parse %q as repository: %w", d.Repo, err)
+		}
+		if _, ok := namers[d.Naming]; d.Naming != "" && !ok {
+			return fmt.Errorf("'publish.destinations': unknown naming %q for %s", d.Naming, d.Repo)
+		}
+		if d.Primary {
+			primaries++
+		}
+	}
+	if primaries > 1 {
+		return errors.New("'publish.destinations': only one destination can be primary")
+	}
+	po.Destinations = destinations
+	return nil
+}
+
+// loadImageNameOverrides returns the image_name templates of the builds in
+// `.ko.yaml` by import path.
+func loadImageNameOverrides(v *viper.Viper, workingDirectory string) (map[string]string, error) {
+	var builds []build.Config
+	if err := v.UnmarshalKey("builds", &builds, useYAMLTagsAndUnmarshallers); err != nil {
+		return nil, fmt.Errorf("configuration section 'builds' cannot be parsed: %w", err)
+	}
+	if !slices.ContainsFunc(builds, func(c build.Config) bool { return c.ImageName != "" }) {
+		// Don't look up the import paths of builds when nothing needs them.
+		return nil, nil
+	}
+	buildConfigs, err := createBuildConfigMap(workingDirectory, builds)
+	if err != nil {
+		return nil, fmt.Errorf("could not create build config map: %w", err)
+	}
+	overrides := map[string]string{}
+	for importpath, c := range buildConfigs {
+		if c.ImageName == "" {
+			continue
+		}
+		if _, err := parseImageName(c.ImageName); err != nil {
+			return nil, fmt.Errorf("'builds': entry %s: 'image_name': %w", c.ID, err)
+		}
+		overrides[importpath] = c.ImageName
+	}
+	return overrides, nil
+}
+
+// MakeNamer returns the namer of images for po.  The imageName templates of
+// `.ko.yaml` are executed with ctx, and ImageNameError reports why they fail.
+func MakeNamer(ctx context.Context, po *PublishOptions) publish.Namer {
 	if po.ImageNamer != nil {
 		return po.ImageNamer
-	} else if po.PreserveImportPaths {
-		return preserveImportPath
+	}
+	fallback := packageWithMD5
+	// The naming flags take precedence over the imageName of .ko.yaml.
+	defaultTemplate := ""
+	if po.PreserveImportPaths {
+		fallback = preserveImportPath
 	} else if po.BaseImportPaths {
-		return baseImportPaths
+		fallback = baseImportPaths
 	} else if po.Bare {
-		return bareDockerRepo
+		fallback = bareDockerRepo
+	} else {
+		defaultTemplate = po.ImageNameTemplate
+	}
+	if defaultTemplate == "" && len(po.ImageNameOverrides) == 0 {
+		return fallback
+	}
+	if po.imageNames == nil {
+		po.imageNames = newTemplateNamer(po.WorkingDirectory, defaultTemplate, po.ImageNameOverrides, fallback)
+	}
+	n := po.imageNames
+	return func(base, importpath string) string {
+		return n.name(ctx, base, importpath)
+	}
+}
+
+// ImageNameError returns why the imageName template of `.ko.yaml` couldn't
+// name the image of importpath, for the namers of MakeNamer, or nil.
+func (po *PublishOptions) ImageNameError(importpath string) error {
+	if po.imageNames == nil {
+		return nil
 	}
-	return packageWithMD5
+	return po.imageNames.err(importpath)
 }
@@ -0,0 +1,75 @@
+// Copyright 2026 ko Build Authors All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPublishDestinations(t *testing.T) {
+	dir := t.TempDir()
+	config := `publish:
+  destinations:
+  - repo: 123456789012.dkr.ecr.us-east-1.amazonaws.com/team
+    keychain: ecr
+  - repo: registry.example.com/customer
+    naming: bare
+    tags: [v1.2.3]
+    insecure: true
+    primary: true
+`
+	if err := os.WriteFile(filepath.Join(dir, ".ko.yaml"), []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	po := &PublishOptions{WorkingDirectory: dir}
+	if err := po.LoadConfig(); err != nil {
+		t.Fatalf("LoadConfig() = %v", err)
+	}
+	want := []PublishDestination{{
+		Repo:     "123456789012.dkr.ecr.us-east-1.amazonaws.com/team",
+		Keychain: "ecr",
+	}, {
+		Repo:     "registry.example.com/customer",
+		Naming:   "bare",
+		Tags:     []string{"v1.2.3"},
+		Insecure: true,
+		Primary:  true,
+	}}
+	if diff := cmp.Diff(want, po.Destinations); diff != "" {
+		t.Errorf("Destinations (-want +got): %s", diff)
+	}
+	if got, want := MakeDestinationNamer(context.Background(), po, po.Destinations[1])("registry.example.com/customer", "example.com/app"), "registry.example.com/customer"; got != want {
+		t.Errorf("namer() = %s, wanted %s", got, want)
+	}
+
+	for _, bad := range []string{
+		"publish:\n  destinations:\n  - naming: bare\n",
+		"publish:\n  destinations:\n  - repo: UPPER/case\n",
+		"publish:\n  destinations:\n  - repo: example.com/a\n    naming: short\n",
+		"publish:\n  destinations:\n  - repo: example.com/a\n    primary: true\n  - repo: example.com/b\n    primary: true\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, ".ko.yaml"), []byte(bad), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := (&PublishOptions{WorkingDirectory: dir}).LoadConfig(); err == nil {
+			t.Errorf("LoadConfig(%q) = nil, wanted an error", bad)
+		}
+	}
+}
@@ -16,6 +16,7 @@ package options
 
 import (
 	"errors"
+	"fmt"
 	"log"
 	"slices"
 	"strings"
@@ -52,6 +53,16 @@ func Validate(po *PublishOptions, bo *BuildOptions) error {
 		log.Print(localFlagsWarning)
 	}
 
+	if bo.Offline && bo.UpdateLock {
+		return errors.New("--update-lock cannot be used with --offline")
+	}
+
+	switch bo.SBOM {
+	case "", "none", "spdx":
+	default:
+		return fmt.Errorf("unsupported --sbom value %q, must be one of spdx or none", bo.SBOM)
+	}
+
 	if len(bo.Platforms) > 1 {
 		if slices.Contains(bo.Platforms, "all") {
 			return errors.New("all or specific platforms should be used")
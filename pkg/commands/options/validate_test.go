@@ -0,0 +1,37 @@
+// Copyright 2026 ko Build Authors All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import "testing"
+
+func TestValidateSBOM(t *testing.T) {
+	for _, c := range []struct {
+		sbom    string
+		wantErr bool
+	}{
+		{sbom: ""},
+		{sbom: "spdx"},
+		{sbom: "none"},
+		{sbom: "cyclonedx", wantErr: true},
+		{sbom: "go.version-m", wantErr: true},
+	} {
+		t.Run(c.sbom, func(t *testing.T) {
+			err := Validate(&PublishOptions{}, &BuildOptions{SBOM: c.sbom})
+			if gotErr := err != nil; gotErr != c.wantErr {
+				t.Errorf("Validate() = %v, wanted error: %t", err, c.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright 2026 ko Build Authors All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	ociremote "github.com/sigstore/cosign/v3/pkg/oci/remote"
+	"github.com/sigstore/sigstore/pkg/signature"
+	"github.com/sigstore/sigstore/pkg/signature/payload"
+
+	"github.com/google/ko/pkg/commands/options"
+)
+
+// checkFileBasePolicy returns an error if the policy does not allow the
+// given base image, which is loaded from a file or containerd (see
+// loadFileBase).  Such images aren't from any registry, and their signatures can't be verified.
+func checkFileBasePolicy(p *options.BaseImagePolicy, base string) error {
+	if p.RequireDigest && (strings.HasPrefix(base, "docker-archive://") || !strings.Contains(base, "@")) {
+		return errors.New("base images must be referenced by digest")
+	}
+	if len(p.AllowedRegistries) != 0 || len(p.AllowedRepositories) != 0 {
+		return errors.New("base images loaded from files are not in the allowed registries or repositories")
+	}
+	if p.PublicKey != "" {
+		return errors.New("signatures of base images loaded from files cannot be verified")
+	}
+	return nil
+}
+
+// checkBaseImagePolicy returns an error if the policy does not allow the
+// given base image reference.
+func checkBaseImagePolicy(p *options.BaseImagePolicy, ref name.Reference) error {
+	if p.RequireDigest {
+		if _, ok := ref.(name.Digest); !ok {
+			return errors.New("base images must be referenced by digest")
+		}
+	}
+	if len(p.AllowedRegistries) == 0 && len(p.AllowedRepositories) == 0 {
+		return nil
+	}
+	for _, reg := range p.AllowedRegistries {
+		r, err := name.NewRegistry(reg)
+		if err != nil {
+			return fmt.Errorf("parsing allowed registry %q: %w", reg, err)
+		}
+		if r.RegistryStr() == ref.Context().RegistryStr() {
+			return nil
+		}
+	}
+	repo := ref.Context().Name()
+	for _, pattern := range p.AllowedRepositories {
+		if prefix, ok := strings.CutSuffix(pattern, "/**"); ok {
+			if strings.HasPrefix(repo, prefix+"/") {
+				return nil
+			}
+			continue
+		}
+		if ok, _ := path.Match(pattern, repo); ok {
+			return nil
+		}
+	}
+	return fmt.Errorf("repository %s is not in the allowed registries or repositories", repo)
+}
+
+// verifyBaseSignature returns an error unless the image with the given digest
+// has a cosign signature that verifies with the public key at keyPath.  Only
+// signatures stored under the `sha256-<digest>.sig` tag are considered, and
+// no transparency log inclusion is checked.
+func verifyBaseSignature(ctx context.Context, keyPath string, ref name.Reference, digest v1.Hash, ropt ...remote.Option) error {
+	verifier, err := signature.LoadVerifierFromPEMFile(keyPath, crypto.SHA256)
+	if err != nil {
+		return fmt.Errorf("loading public key %s: %w", keyPath, err)
+	}
+	dig := ref.Context().Digest(digest.String())
+	sigTag, err := ociremote.SignatureTag(dig)
+	if err != nil {
+		return err
+	}
+	ropt = append(slices.Clip(ropt), remote.WithContext(ctx))
+	sigs, err := ociremote.Signatures(sigTag, ociremote.WithRemoteOptions(ropt...))
+	if err != nil {
+		return fmt.Errorf("fetching signatures for %s: %w", dig, err)
+	}
+	sl, err := sigs.Get()
+	if err != nil {
+		return fmt.Errorf("fetching signatures for %s: %w", dig, err)
+	}
+	for _, sig := range sl {
+		b64, err := sig.Base64Signature()
+		if err != nil {
+			continue
+		}
+		raw, err := base64.StdEncoding.DecodeString(b64)
+		if err != nil {
+			continue
+		}
+		pl, err := sig.Payload()
+		if err != nil {
+			continue
+		}
+		if err := verifier.VerifySignature(bytes.NewReader(raw), bytes.NewReader(pl)); err != nil {
+			continue
+		}
+		var sci payload.SimpleContainerImage
+		if err := json.Unmarshal(pl, &sci); err != nil {
+			continue
+		}
+		if sci.Critical.Image.DockerManifestDigest == digest.String() {
+			return nil
+		}
+	}
+	return fmt.Errorf("no signature of %s verifies with public key %s", dig, keyPath)
+}
@@ -0,0 +1,206 @@
+// Copyright 2026 ko Build Authors All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/sigstore/cosign/v3/pkg/oci/empty"
+	"github.com/sigstore/cosign/v3/pkg/oci/mutate"
+	ociremote "github.com/sigstore/cosign/v3/pkg/oci/remote"
+	"github.com/sigstore/cosign/v3/pkg/oci/static"
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+	"github.com/sigstore/sigstore/pkg/signature"
+	"github.com/sigstore/sigstore/pkg/signature/payload"
+
+	"github.com/google/ko/pkg/commands/options"
+)
+
+func TestCheckBaseImagePolicy(t *testing.T) {
+	const digest = "sha256:deadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeef"
+	for _, c := range []struct {
+		desc    string
+		policy  options.BaseImagePolicy
+		ref     string
+		wantErr string
+	}{{
+		desc: "empty policy",
+		ref:  "alpine",
+	}, {
+		desc:   "allowed registry",
+		policy: options.BaseImagePolicy{AllowedRegistries: []string{"cgr.dev"}},
+		ref:    "cgr.dev/chainguard/static",
+	}, {
+		desc:   "docker hub registry",
+		policy: options.BaseImagePolicy{AllowedRegistries: []string{"docker.io"}},
+		ref:    "alpine",
+	}, {
+		desc:    "disallowed registry",
+		policy:  options.BaseImagePolicy{AllowedRegistries: []string{"cgr.dev"}},
+		ref:     "alpine",
+		wantErr: "repository index.docker.io/library/alpine is not in the allowed",
+	}, {
+		desc:   "repository pattern",
+		policy: options.BaseImagePolicy{AllowedRepositories: []string{"gcr.io/distroless/*"}},
+		ref:    "gcr.io/distroless/static:nonroot",
+	}, {
+		desc:    "repository pattern does not cross segments",
+		policy:  options.BaseImagePolicy{AllowedRepositories: []string{"gcr.io/distroless/*"}},
+		ref:     "gcr.io/distroless/nested/static",
+		wantErr: "is not in the allowed",
+	}, {
+		desc:   "repository prefix",
+		policy: options.BaseImagePolicy{AllowedRepositories: []string{"gcr.io/distroless/**"}},
+		ref:    "gcr.io/distroless/nested/static",
+	}, {
+		desc:    "repository prefix requires separator",
+		policy:  options.BaseImagePolicy{AllowedRepositories: []string{"gcr.io/distroless/**"}},
+		ref:     "gcr.io/distroless-evil/static",
+		wantErr: "is not in the allowed",
+	}, {
+		desc:   "either list",
+		policy: options.BaseImagePolicy{AllowedRegistries: []string{"cgr.dev"}, AllowedRepositories: []string{"index.docker.io/library/alpine"}},
+		ref:    "alpine",
+	}, {
+		desc:    "require digest",
+		policy:  options.BaseImagePolicy{RequireDigest: true},
+		ref:     "cgr.dev/chainguard/static:latest",
+		wantErr: "must be referenced by digest",
+	}, {
+		desc:   "digest",
+		policy: options.BaseImagePolicy{RequireDigest: true},
+		ref:    "cgr.dev/chainguard/static@" + digest,
+	}} {
+		t.Run(c.desc, func(t *testing.T) {
+			ref, err := name.ParseReference(c.ref)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = checkBaseImagePolicy(&c.policy, ref)
+			switch {
+			case c.wantErr == "" && err != nil:
+				t.Errorf("checkBaseImagePolicy() = %v", err)
+			case c.wantErr != "" && (err == nil || !strings.Contains(err.Error(), c.wantErr)):
+				t.Errorf("checkBaseImagePolicy() = %v, wanted %q", err, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestGetBaseImageVerifiesSignature(t *testing.T) {
+	namespace := "base"
+	s, err := registryServerWithImage(namespace)
+	if err != nil {
+		t.Fatalf("could not create test registry server: %v", err)
+	}
+	defer s.Close()
+	baseImage := fmt.Sprintf("%s/%s", s.Listener.Addr().String(), namespace)
+	dig, err := crane.Digest(baseImage)
+	if err != nil {
+		t.Fatalf("crane.Digest(%s): %v", baseImage, err)
+	}
+
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := cryptoutils.MarshalPublicKeyToPEM(priv.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(t.TempDir(), "cosign.pub")
+	if err := os.WriteFile(keyPath, pub, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	bo := &options.BuildOptions{
+		BaseImage:       baseImage,
+		BaseImagePolicy: &options.BaseImagePolicy{PublicKey: keyPath},
+	}
+	if _, _, err := getBaseImage(bo)(context.Background(), "ko://example.com/helloworld"); err == nil || !strings.Contains(err.Error(), "no signature") {
+		t.Fatalf("getBaseImage() = %v, wanted unsigned base to be rejected", err)
+	}
+
+	// Sign the base image and attach the signature the way cosign does.
+	ref, err := name.NewDigest(baseImage + "@" + dig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pl, err := payload.Cosign{Image: ref}.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := signature.LoadECDSASigner(priv, crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := signer.SignMessage(bytes.NewReader(pl))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := static.NewSignature(pl, base64.StdEncoding.EncodeToString(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sigs, err := mutate.AppendSignatures(empty.Signatures(), false, sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sigTag, err := ociremote.SignatureTag(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(sigTag, sigs); err != nil {
+		t.Fatalf("remote.Write(%s): %v", sigTag, err)
+	}
+
+	_, res, err := getBaseImage(bo)(context.Background(), "ko://example.com/helloworld")
+	if err != nil {
+		t.Fatalf("getBaseImage(): %v", err)
+	}
+	if got, err := res.Digest(); err != nil || got.String() != dig {
+		t.Errorf("res.Digest() = %v, %v; wanted %s", got, err, dig)
+	}
+
+	// A different key must not be accepted.
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPub, err := cryptoutils.MarshalPublicKeyToPEM(other.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyPath, otherPub, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := getBaseImage(bo)(context.Background(), "ko://example.com/helloworld"); err == nil || !strings.Contains(err.Error(), "example.com/helloworld") {
+		t.Fatalf("getBaseImage() = %v, wanted signature from other key to be rejected", err)
+	}
+}
@@ -0,0 +1,697 @@
+// Copyright 2026 ko Build Authors All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"archive/tar"
+	"bytes"
+	"debug/buildinfo"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	specsv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/sigstore/cosign/v3/pkg/oci"
+	ocimutate "github.com/sigstore/cosign/v3/pkg/oci/mutate"
+	ociremote "github.com/sigstore/cosign/v3/pkg/oci/remote"
+	"github.com/sigstore/cosign/v3/pkg/oci/signed"
+	"github.com/sigstore/cosign/v3/pkg/oci/static"
+	ctypes "github.com/sigstore/cosign/v3/pkg/types"
+	"github.com/spf13/cobra"
+
+	"github.com/google/ko/internal/sbom"
+	"github.com/google/ko/pkg/build"
+	"github.com/google/ko/pkg/commands/options"
+)
+
+// addRebase augments our CLI surface with rebase.
+func addRebase(topLevel *cobra.Command) {
+	po := &options.PublishOptions{}
+	var sbomType string
+
+	rebase := &cobra.Command{
+		Use:   "rebase IMAGE...",
+		Short: "Rebase images built by ko onto the latest version of their base image.",
+		Long: `This sub-command replaces the base image layers of images built by ko with those of the image that their base image name currently points to, without rebuilding the Go binaries.
+
+The base image is found through the org.opencontainers.image.base.name and org.opencontainers.image.base.digest annotations that ko adds to the images it builds. Rebased images are published with the same naming and tagging flags as "ko build", using the import path of the binary they contain.`,
+		Example: `
+  # Rebase an image and publish it as:
+  #   ${KO_DOCKER_REPO}/<package name>-<hash of import path>
+  ko rebase registry.example.com/app-8e4b5c0a@sha256:...
+
+  # Rebase images and publish them with the given tags, using the
+  # import path of each binary after KO_DOCKER_REPO.
+  ko rebase --preserve-import-paths --tags=v1.2.3,latest \
+    registry.example.com/github.com/foo/bar/cmd/baz:v1.2.3 \
+    registry.example.com/github.com/foo/bar/cmd/blah:v1.2.3`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := options.Validate(po, &options.BuildOptions{SBOM: sbomType}); err != nil {
+				return fmt.Errorf("validating options: %w", err)
+			}
+			ctx := cmd.Context()
+
+			r := &rebaser{
+				ropt: []remote.Option{
+					remote.WithAuthFromKeychain(keychain),
+					remote.WithUserAgent(ua()),
+					remote.WithContext(ctx),
+				},
+			}
+			r.oopt = []ociremote.Option{ociremote.WithRemoteOptions(r.ropt...)}
+			if po.InsecureRegistry {
+				r.nopt = append(r.nopt, name.Insecure)
+			}
+			// Respect COSIGN_REPOSITORY, like the publisher does.
+			targetRepoOverride, err := ociremote.GetEnvTargetRepository()
+			if err != nil {
+				return err
+			}
+			if (targetRepoOverride != name.Repository{}) {
+				r.oopt = append(r.oopt, ociremote.WithTargetRepository(targetRepoOverride))
+			}
+			if sbomType != "none" {
+				r.sbomVersion = version()
+			}
+
+			if err := po.LoadConfig(); err != nil {
+				return fmt.Errorf("error creating publisher: %w", err)
+			}
+			publisher, err := makePublisher(ctx, po)
+			if err != nil {
+				return fmt.Errorf("error creating publisher: %w", err)
+			}
+			defer publisher.Close()
+
+			for _, arg := range args {
+				ref, err := name.ParseReference(arg, r.nopt...)
+				if err != nil {
+					return fmt.Errorf("parsing %q: %w", arg, err)
+				}
+				res, importpath, err := r.rebase(ref)
+				if err != nil {
+					return fmt.Errorf("rebasing %s: %w", ref, err)
+				}
+				if res == nil {
+					log.Printf("Base image of %s is up to date", ref)
+					continue
+				}
+				published, err := publisher.Publish(ctx, res, build.StrictScheme+importpath)
+				if err != nil {
+					return fmt.Errorf("error publishing %s: %w", importpath, err)
+				}
+				fmt.Println(published)
+			}
+			return nil
+		},
+	}
+	options.AddPublishArg(rebase, po)
+	rebase.Flags().StringVar(&sbomType, "sbom", "spdx",
+		"The SBOM media type to use (none will disable SBOM synthesis and upload).")
+	topLevel.AddCommand(rebase)
+}
+
+// rebaser replaces the base image layers of images built by ko.
+type rebaser struct {
+	ropt []remote.Option
+	nopt []name.Option
+	oopt []ociremote.Option
+
+	// sbomVersion is the ko version recorded in regenerated SBOMs.  When it
+	// is empty, no SBOMs are generated.  Licenses are carried over from the
+	// SBOMs of the original images.
+	sbomVersion string
+}
+
+// baseAnnotations returns the base image name and digest that ko recorded in
+// the given manifest annotations.
+func baseAnnotations(annotations map[string]string, opts ...name.Option) (name.Reference, v1.Hash, error) {
+	baseName, ok := annotations[specsv1.AnnotationBaseImageName]
+	if _, hasDigest := annotations[specsv1.AnnotationBaseImageDigest]; !ok && hasDigest {
+		return nil, v1.Hash{}, errors.New("no base image name was recorded, because the base image was not pulled from a registry")
+	} else if !ok {
+		return nil, v1.Hash{}, fmt.Errorf("missing %s annotation; was this built by ko?", specsv1.AnnotationBaseImageName)
+	}
+	baseDigest, ok := annotations[specsv1.AnnotationBaseImageDigest]
+	if !ok {
+		return nil, v1.Hash{}, fmt.Errorf("missing %s annotation; was this built by ko?", specsv1.AnnotationBaseImageDigest)
+	}
+	ref, err := name.ParseReference(baseName, opts...)
+	if err != nil {
+		return nil, v1.Hash{}, fmt.Errorf("parsing base image name %q: %w", baseName, err)
+	}
+	h, err := v1.NewHash(baseDigest)
+	if err != nil {
+		return nil, v1.Hash{}, fmt.Errorf("parsing base image digest %q: %w", baseDigest, err)
+	}
+	return ref, h, nil
+}
+
+// samePlatform reports whether a and b describe the same platform.  The
+// OS version is ignored, since it changes as Windows base images are patched.
+func samePlatform(a, b *v1.Platform) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.OS == b.OS && a.Architecture == b.Architecture && a.Variant == b.Variant
+}
+
+// platformDescriptor returns the descriptor of the image for the given
+// platform in the index manifest.
+func platformDescriptor(im *v1.IndexManifest, platform *v1.Platform) (*v1.Descriptor, error) {
+	for _, desc := range im.Manifests {
+		if samePlatform(desc.Platform, platform) {
+			return &desc, nil
+		}
+	}
+	return nil, fmt.Errorf("no image for platform %s", platform)
+}
+
+// rebase rebases the image or index at ref onto the image that its base
+// image name currently points to.  It returns the rebased result along with
+// the import path of its binary, or a nil result when the base is unchanged.
+func (r *rebaser) rebase(ref name.Reference) (build.Result, string, error) {
+	desc, err := remote.Get(ref, r.ropt...)
+	if err != nil {
+		return nil, "", err
+	}
+	if desc.MediaType.IsIndex() {
+		idx, err := desc.ImageIndex()
+		if err != nil {
+			return nil, "", err
+		}
+		return r.rebaseIndex(ref.Context(), idx)
+	}
+	img, err := desc.Image()
+	if err != nil {
+		return nil, "", err
+	}
+	m, err := img.Manifest()
+	if err != nil {
+		return nil, "", err
+	}
+	baseRef, baseDigest, err := baseAnnotations(m.Annotations, r.nopt...)
+	if err != nil {
+		return nil, "", err
+	}
+	cfg, err := img.ConfigFile()
+	if err != nil {
+		return nil, "", err
+	}
+
+	// When a single platform of a base index was used, the name refers to
+	// the index while the digest is that of the platform's image.
+	newBase, err := r.resolveBase(baseRef, cfg.Platform())
+	if err != nil {
+		return nil, "", err
+	}
+	newDigest, err := newBase.Digest()
+	if err != nil {
+		return nil, "", err
+	}
+	if newDigest == baseDigest {
+		return nil, "", nil
+	}
+	si, importpath, err := r.rebaseImage(img, ref.Context().Digest(desc.Digest.String()), baseRef.Context().Digest(baseDigest.String()), newBase)
+	if err != nil {
+		return nil, "", err
+	}
+	return si, importpath, nil
+}
+
+// resolveBase fetches the image that baseRef currently points to, selecting
+// the image for the platform if it is an index.
+func (r *rebaser) resolveBase(baseRef name.Reference, platform *v1.Platform) (v1.Image, error) {
+	desc, err := remote.Get(baseRef, r.ropt...)
+	if err != nil {
+		return nil, fmt.Errorf("fetching base image %s: %w", baseRef, err)
+	}
+	if !desc.MediaType.IsIndex() {
+		return desc.Image()
+	}
+	idx, err := desc.ImageIndex()
+	if err != nil {
+		return nil, err
+	}
+	im, err := idx.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+	pd, err := platformDescriptor(im, platform)
+	if err != nil {
+		return nil, fmt.Errorf("base image %s: %w", baseRef, err)
+	}
+	return idx.Image(pd.Digest)
+}
+
+// rebaseIndex rebases each image of an index built by ko onto the matching
+// image of the index that the base image name currently points to.  The
+// index was fetched from repo.
+func (r *rebaser) rebaseIndex(repo name.Repository, idx v1.ImageIndex) (build.Result, string, error) {
+	im, err := idx.IndexManifest()
+	if err != nil {
+		return nil, "", err
+	}
+	baseRef, baseDigest, err := baseAnnotations(im.Annotations, r.nopt...)
+	if err != nil {
+		return nil, "", err
+	}
+	desc, err := remote.Get(baseRef, r.ropt...)
+	if err != nil {
+		return nil, "", fmt.Errorf("fetching base image %s: %w", baseRef, err)
+	}
+	if desc.Digest == baseDigest {
+		return nil, "", nil
+	}
+	if !desc.MediaType.IsIndex() {
+		return nil, "", fmt.Errorf("base image %s is no longer an index", baseRef)
+	}
+	newBaseIndex, err := desc.ImageIndex()
+	if err != nil {
+		return nil, "", err
+	}
+	newIM, err := newBaseIndex.IndexManifest()
+	if err != nil {
+		return nil, "", err
+	}
+
+	var importpath string
+	adds := make([]ocimutate.IndexAddendum, 0, len(im.Manifests))
+	for _, desc := range im.Manifests {
+		img, err := idx.Image(desc.Digest)
+		if err != nil {
+			return nil, "", err
+		}
+		m, err := img.Manifest()
+		if err != nil {
+			return nil, "", err
+		}
+		_, oldDigest, err := baseAnnotations(m.Annotations, r.nopt...)
+		if err != nil {
+			return nil, "", fmt.Errorf("image for %s: %w", desc.Platform, err)
+		}
+		newDesc, err := platformDescriptor(newIM, desc.Platform)
+		if err != nil {
+			return nil, "", fmt.Errorf("base image %s: %w", baseRef, err)
+		}
+
+		var add oci.SignedImage = signed.Image(img)
+		if newDesc.Digest != oldDigest {
+			newBase, err := newBaseIndex.Image(newDesc.Digest)
+			if err != nil {
+				return nil, "", err
+			}
+			add, importpath, err = r.rebaseImage(img, repo.Digest(desc.Digest.String()), baseRef.Context().Digest(oldDigest.String()), newBase)
+			if err != nil {
+				return nil, "", fmt.Errorf("image for %s: %w", desc.Platform, err)
+			}
+		}
+		adds = append(adds, ocimutate.IndexAddendum{
+			Add: add,
+			Descriptor: v1.Descriptor{
+				URLs:        desc.URLs,
+				MediaType:   desc.MediaType,
+				Annotations: desc.Annotations,
+				Platform:    desc.Platform,
+			},
+		})
+	}
+	if importpath == "" {
+		// Only the index changed, e.g. because platforms we don't
+		// build for were updated.
+		return nil, "", nil
+	}
+
+	annotations := maps.Clone(im.Annotations)
+	annotations[specsv1.AnnotationBaseImageDigest] = desc.Digest.String()
+	mt, err := idx.MediaType()
+	if err != nil {
+		return nil, "", err
+	}
+	sii := ocimutate.AppendManifests(
+		mutate.Annotations(
+			mutate.IndexMediaType(empty.Index, mt),
+			annotations).(v1.ImageIndex),
+		adds...)
+
+	if r.sbomVersion != "" {
+		b, err := sbom.GenerateIndexSPDX(r.sbomVersion, sii)
+		if err != nil {
+			return nil, "", err
+		}
+		f, err := static.NewFile(b, static.WithLayerMediaType(ctypes.SPDXJSONMediaType))
+		if err != nil {
+			return nil, "", err
+		}
+		sii, err = ocimutate.AttachFileToImageIndex(sii, "sbom", f)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	return sii, importpath, nil
+}
+
+// rebaseImage replaces the layers of the image at oldBaseRef in orig, which
+// was fetched from origRef, with the layers of newBase.  It returns the
+// rebased image along with the import path of its binary.
+func (r *rebaser) rebaseImage(orig v1.Image, origRef, oldBaseRef name.Digest, newBase v1.Image) (oci.SignedImage, string, error) {
+	oldBase, err := remote.Image(oldBaseRef, r.ropt...)
+	if err != nil {
+		return nil, "", fmt.Errorf("fetching old base image %s: %w", oldBaseRef, err)
+	}
+	origMT, err := orig.MediaType()
+	if err != nil {
+		return nil, "", err
+	}
+	newMT, err := newBase.MediaType()
+	if err != nil {
+		return nil, "", err
+	}
+	if origMT != newMT {
+		return nil, "", fmt.Errorf("base image media type changed from %s to %s", origMT, newMT)
+	}
+
+	origLayers, err := orig.Layers()
+	if err != nil {
+		return nil, "", err
+	}
+	oldLayers, err := oldBase.Layers()
+	if err != nil {
+		return nil, "", err
+	}
+	if len(oldLayers) > len(origLayers) {
+		return nil, "", fmt.Errorf("image is not based on %s (too few layers)", oldBaseRef)
+	}
+	for i, l := range oldLayers {
+		want, err := l.Digest()
+		if err != nil {
+			return nil, "", err
+		}
+		got, err := origLayers[i].Digest()
+		if err != nil {
+			return nil, "", err
+		}
+		if got != want {
+			return nil, "", fmt.Errorf("image is not based on %s (layer %d mismatch)", oldBaseRef, i)
+		}
+	}
+	topLayers := origLayers[len(oldLayers):]
+
+	origCfg, err := orig.ConfigFile()
+	if err != nil {
+		return nil, "", err
+	}
+	oldCfg, err := oldBase.ConfigFile()
+	if err != nil {
+		return nil, "", err
+	}
+	newCfg, err := newBase.ConfigFile()
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Pair the layers above the old base with their history, keeping
+	// empty layers (e.g. ENV statements) in place.
+	var history []v1.History
+	if len(origCfg.History) >= len(oldCfg.History) {
+		history = origCfg.History[len(oldCfg.History):]
+	}
+	adds := make([]mutate.Addendum, 0, len(topLayers))
+	next := 0
+	for _, h := range history {
+		if h.EmptyLayer {
+			adds = append(adds, mutate.Addendum{History: h})
+			continue
+		}
+		if next == len(topLayers) {
+			break
+		}
+		adds = append(adds, mutate.Addendum{Layer: topLayers[next], History: h})
+		next++
+	}
+	for _, l := range topLayers[next:] {
+		adds = append(adds, mutate.Addendum{Layer: l})
+	}
+
+	img, err := mutate.Append(newBase, adds...)
+	if err != nil {
+		return nil, "", err
+	}
+	cfg, err := img.ConfigFile()
+	if err != nil {
+		return nil, "", err
+	}
+	cfg = cfg.DeepCopy()
+	cfg.Config = rebaseConfig(origCfg.Config, oldCfg.Config, newCfg.Config)
+	cfg.Author = origCfg.Author
+	if origCfg.Created != oldCfg.Created {
+		cfg.Created = origCfg.Created
+	}
+	img, err = mutate.ConfigFile(img, cfg)
+	if err != nil {
+		return nil, "", err
+	}
+
+	origM, err := orig.Manifest()
+	if err != nil {
+		return nil, "", err
+	}
+	oldM, err := oldBase.Manifest()
+	if err != nil {
+		return nil, "", err
+	}
+	newM, err := newBase.Manifest()
+	if err != nil {
+		return nil, "", err
+	}
+	newDigest, err := newBase.Digest()
+	if err != nil {
+		return nil, "", err
+	}
+	annotations := rebaseMap(origM.Annotations, oldM.Annotations, newM.Annotations)
+	annotations[specsv1.AnnotationBaseImageDigest] = newDigest.String()
+	img = mutate.Annotations(img, annotations).(v1.Image)
+
+	appPath, bi, err := appBuildInfo(origCfg, topLayers)
+	if err != nil {
+		return nil, "", err
+	}
+	si := signed.Image(img)
+	if r.sbomVersion != "" {
+		licenses, err := r.sbomLicenses(origRef)
+		if err != nil {
+			return nil, "", err
+		}
+		// Mimic the output of `go version -m` for the binary.
+		mod := fmt.Sprintf("%s: %s\n%s", appPath, bi.GoVersion, bi.String())
+		b, err := sbom.GenerateImageSPDX(r.sbomVersion, []byte(mod), si, licenses)
+		if err != nil {
+			return nil, "", err
+		}
+		f, err := static.NewFile(b, static.WithLayerMediaType(ctypes.SPDXJSONMediaType))
+		if err != nil {
+			return nil, "", err
+		}
+		si, err = ocimutate.AttachFileToImage(si, "sbom", f)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	return si, bi.Path, nil
+}
+
+// sbomLicenses returns the module licenses recorded in the SPDX SBOM that was
+// published for the image at ref, since the sources they were detected from
+// are not available when rebasing.  Images without one have no licenses.
+func (r *rebaser) sbomLicenses(ref name.Digest) (map[string]string, error) {
+	tag, err := ociremote.SBOMTag(ref, r.oopt...)
+	if err != nil {
+		return nil, err
+	}
+	img, err := remote.Image(tag, r.ropt...)
+	var terr *transport.Error
+	if errors.As(err, &terr) && terr.StatusCode == http.StatusNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("fetching SBOM %s: %w", tag, err)
+	}
+	layers, err := img.Layers()
+	if err != nil {
+		return nil, err
+	}
+	if len(layers) != 1 {
+		return nil, fmt.Errorf("SBOM %s has %d layers, wanted 1", tag, len(layers))
+	}
+	mt, err := layers[0].MediaType()
+	if err != nil {
+		return nil, err
+	}
+	if mt != ctypes.SPDXJSONMediaType {
+		return nil, nil
+	}
+	rc, err := layers[0].Uncompressed()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	b, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, err
+	}
+	licenses, err := sbom.Licenses(b)
+	if err != nil {
+		return nil, fmt.Errorf("reading SBOM %s: %w", tag, err)
+	}
+	return licenses, nil
+}
+
+// rebaseConfig returns the container config of an image with the settings
+// it inherited from the old base replaced by those of the new base.
+// Settings that differ from the old base were set by ko and are kept.
+func rebaseConfig(orig, oldBase, newBase v1.Config) v1.Config {
+	cfg := *newBase.DeepCopy()
+	if !slices.Equal(orig.Entrypoint, oldBase.Entrypoint) {
+		cfg.Entrypoint = orig.Entrypoint
+	}
+	if !slices.Equal(orig.Cmd, oldBase.Cmd) {
+		cfg.Cmd = orig.Cmd
+	}
+	if orig.User != oldBase.User {
+		cfg.User = orig.User
+	}
+	if orig.WorkingDir != oldBase.WorkingDir {
+		cfg.WorkingDir = orig.WorkingDir
+	}
+	cfg.Labels = rebaseMap(orig.Labels, oldBase.Labels, newBase.Labels)
+	cfg.Env = rebaseEnv(orig.Env, oldBase.Env, newBase.Env)
+	return cfg
+}
+
+// rebaseMap returns the entries of newBase, overlaid with the entries of orig
+// that differ from oldBase.
+func rebaseMap(orig, oldBase, newBase map[string]string) map[string]string {
+	out := maps.Clone(newBase)
+	if out == nil {
+		out = map[string]string{}
+	}
+	for k, v := range orig {
+		if ov, ok := oldBase[k]; ok && ov == v {
+			continue
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// rebaseEnv returns the environment of newBase, overlaid with the variables of
+// orig that differ from oldBase.  Variables that extend the value from the old
+// base (e.g. PATH) extend the value from the new base instead.
+func rebaseEnv(orig, oldBase, newBase []string) []string {
+	oldVals := make(map[string]string, len(oldBase))
+	for _, kv := range oldBase {
+		k, v, _ := strings.Cut(kv, "=")
+		oldVals[k] = v
+	}
+	out := slices.Clone(newBase)
+	for _, kv := range orig {
+		k, v, _ := strings.Cut(kv, "=")
+		ov, inOld := oldVals[k]
+		if inOld && ov == v {
+			continue
+		}
+		i := slices.IndexFunc(out, func(s string) bool {
+			return strings.HasPrefix(s, k+"=")
+		})
+		if i < 0 {
+			out = append(out, kv)
+			continue
+		}
+		if inOld && strings.HasPrefix(v, ov) {
+			v = strings.TrimPrefix(out[i], k+"=") + v[len(ov):]
+		}
+		out[i] = k + "=" + v
+	}
+	return out
+}
+
+// appBuildInfo finds the binary that the image's entrypoint runs in the given
+// layers, and returns its path along with its embedded build info.
+func appBuildInfo(cfg *v1.ConfigFile, layers []v1.Layer) (string, *buildinfo.BuildInfo, error) {
+	ep := cfg.Config.Entrypoint
+	if len(ep) == 0 {
+		return "", nil, errors.New("image has no entrypoint")
+	}
+	// When debugging, the entrypoint is delve and the app is its last
+	// argument.
+	appPath := ep[len(ep)-1]
+	want := strings.TrimPrefix(path.Clean("/"+strings.ReplaceAll(strings.TrimPrefix(appPath, `C:`), `\`, "/")), "/")
+
+	// Later layers shadow earlier ones.
+	for i := len(layers) - 1; i >= 0; i-- {
+		b, err := findFile(layers[i], want)
+		if err != nil {
+			return "", nil, err
+		}
+		if b == nil {
+			continue
+		}
+		bi, err := buildinfo.Read(bytes.NewReader(b))
+		if err != nil {
+			return "", nil, fmt.Errorf("reading build info of %s: %w", appPath, err)
+		}
+		return appPath, bi, nil
+	}
+	return "", nil, fmt.Errorf("binary %s not found in layers above the base image", appPath)
+}
+
+// findFile returns the contents of the regular file with the given path in
+// the layer, or nil if there is none.  Windows layers hold files under Files/.
+func findFile(l v1.Layer, want string) ([]byte, error) {
+	rc, err := l.Uncompressed()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	tr := tar.NewReader(rc)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := strings.TrimPrefix(path.Clean("/"+hdr.Name), "/")
+		if name == want || strings.TrimPrefix(name, "Files/") == want {
+			return io.ReadAll(tr)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+// Copyright 2026 ko Build Authors All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/crane"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	specsv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/sigstore/cosign/v3/pkg/oci"
+	ociremote "github.com/sigstore/cosign/v3/pkg/oci/remote"
+	"github.com/sigstore/cosign/v3/pkg/oci/static"
+	ctypes "github.com/sigstore/cosign/v3/pkg/types"
+
+	"github.com/google/ko/internal/sbom"
+	"github.com/google/ko/pkg/build"
+	"github.com/google/ko/pkg/commands/options"
+)
+
+func TestRebaseEnv(t *testing.T) {
+	got := rebaseEnv(
+		[]string{"PATH=/usr/bin:/ko-app", "TZ=UTC", "KO_DATA_PATH=/var/run/ko", "LANG=C"},
+		[]string{"PATH=/usr/bin", "TZ=UTC", "LANG=en_US"},
+		[]string{"PATH=/usr/local/bin:/usr/bin", "TZ=Etc/UTC", "SSL_CERT_FILE=/etc/ssl/certs/ca-certificates.crt"},
+	)
+	want := []string{
+		"PATH=/usr/local/bin:/usr/bin:/ko-app",
+		"TZ=Etc/UTC",
+		"SSL_CERT_FILE=/etc/ssl/certs/ca-certificates.crt",
+		"KO_DATA_PATH=/var/run/ko",
+		"LANG=C",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("rebaseEnv() (-want +got) = %s", diff)
+	}
+}
+
+func TestRebase(t *testing.T) {
+	ctx := context.Background()
+	s, err := registryServerWithImage("base")
+	if err != nil {
+		t.Fatalf("could not create test registry server: %v", err)
+	}
+	defer s.Close()
+	baseImage := fmt.Sprintf("%s/base", s.Listener.Addr().String())
+
+	builder, err := NewBuilder(ctx, &options.BuildOptions{
+		BaseImage:        baseImage,
+		ConcurrentBuilds: 1,
+		Platforms:        []string{"all"},
+		SBOM:             "none",
+	})
+	if err != nil {
+		t.Fatalf("NewBuilder(): %v", err)
+	}
+	publisher, err := NewPublisher(&options.PublishOptions{
+		DockerRepo: s.Listener.Addr().String(),
+		Tags:       []string{"latest"},
+		Push:       true,
+	})
+	if err != nil {
+		t.Fatalf("NewPublisher(): %v", err)
+	}
+	defer publisher.Close()
+	const importpath = "github.com/google/ko/test"
+	res, err := builder.Build(ctx, build.StrictScheme+importpath)
+	if err != nil {
+		t.Fatalf("Build(): %v", err)
+	}
+	ref, err := publisher.Publish(ctx, res, build.StrictScheme+importpath)
+	if err != nil {
+		t.Fatalf("Publish(): %v", err)
+	}
+
+	// Publish an SBOM recording the license of a dependency, as if it had
+	// been detected at build time.
+	const dep = "github.com/google/go-containerregistry"
+	digest, err := crane.Digest(ref.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sbomTag, err := ociremote.SBOMTag(ref.Context().Digest(digest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := static.NewFile([]byte(`{"packages":[{"name":"`+dep+`","licenseConcluded":"Apache-2.0"}]}`),
+		static.WithLayerMediaType(ctypes.SPDXJSONMediaType))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(sbomTag, f); err != nil {
+		t.Fatal(err)
+	}
+
+	r := &rebaser{sbomVersion: "devel"}
+	if got, _, err := r.rebase(ref); err != nil || got != nil {
+		t.Fatalf("rebase() = %v, %v; wanted no change for an up to date base", got, err)
+	}
+
+	// Publish a new version of the base image.
+	newBase, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := crane.Push(newBase, baseImage); err != nil {
+		t.Fatal(err)
+	}
+
+	got, gotImportpath, err := r.rebase(ref)
+	if err != nil {
+		t.Fatalf("rebase() = %v", err)
+	}
+	if gotImportpath != importpath {
+		t.Errorf("rebase() import path = %s, wanted %s", gotImportpath, importpath)
+	}
+	si, ok := got.(oci.SignedImage)
+	if !ok {
+		t.Fatalf("rebase() = %T, wanted oci.SignedImage", got)
+	}
+
+	m, err := si.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	newDigest := mustDigest(newBase)
+	if got := m.Annotations[specsv1.AnnotationBaseImageDigest]; got != newDigest.String() {
+		t.Errorf("base digest annotation = %s, wanted %s", got, newDigest)
+	}
+	if got := m.Annotations[specsv1.AnnotationBaseImageName]; got != baseImage+":latest" {
+		t.Errorf("base name annotation = %s, wanted %s", got, baseImage+":latest")
+	}
+
+	// The new base layers are followed by the layers ko added.
+	orig, err := crane.Pull(ref.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	origLayers, err := orig.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	baseLayers, err := newBase.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	layers, err := si.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The original base had a single layer.
+	if want := len(baseLayers) + len(origLayers) - 1; len(layers) != want {
+		t.Fatalf("got %d layers, wanted %d", len(layers), want)
+	}
+	for i, l := range layers {
+		want := baseLayers[min(i, len(baseLayers)-1)]
+		if i >= len(baseLayers) {
+			want = origLayers[i-len(baseLayers)+1]
+		}
+		if got, want := mustLayerDigest(t, l), mustLayerDigest(t, want); got != want {
+			t.Errorf("layer %d = %s, wanted %s", i, got, want)
+		}
+	}
+
+	origCfg, err := orig.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := si.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(origCfg.Config.Entrypoint, cfg.Config.Entrypoint); diff != "" {
+		t.Errorf("entrypoint (-want +got) = %s", diff)
+	}
+	if diff := cmp.Diff(origCfg.Config.Env, cfg.Config.Env); diff != "" {
+		t.Errorf("env (-want +got) = %s", diff)
+	}
+
+	att, err := si.Attachment("sbom")
+	if err != nil {
+		t.Fatalf("Attachment(sbom) = %v", err)
+	}
+	b, err := att.Payload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	licenses, err := sbom.Licenses(b)
+	if err != nil {
+		t.Fatalf("Licenses() = %v", err)
+	}
+	if got, want := licenses[dep], "Apache-2.0"; got != want {
+		t.Errorf("license of %s = %q, wanted %q", dep, got, want)
+	}
+}
+
+func mustLayerDigest(t *testing.T, l v1.Layer) v1.Hash {
+	t.Helper()
+	h, err := l.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
@@ -20,12 +20,16 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log"
 	"os"
 	"path"
+	"slices"
 	"strings"
 	"sync"
 
+	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"go.yaml.in/yaml/v4"
 	"golang.org/x/sync/errgroup"
 	"k8s.io/apimachinery/pkg/labels"
@@ -60,21 +64,7 @@ func gobuildOptions(bo *options.BuildOptions) ([]build.Option, error) {
 	}
 
 	if len(bo.Platforms) == 0 {
-		envPlatform := "linux/amd64"
-
-		goos, goarch, goarm := os.Getenv("GOOS"), os.Getenv("GOARCH"), os.Getenv("GOARM")
-
-		// Default to linux/amd64 unless GOOS and GOARCH are set.
-		if goos != "" && goarch != "" {
-			envPlatform = path.Join(goos, goarch)
-		}
-
-		// Use GOARM for variant if it's set and GOARCH is arm.
-		if strings.Contains(goarch, "arm") && goarm != "" {
-			envPlatform = path.Join(envPlatform, "v"+goarm)
-		}
-
-		bo.Platforms = []string{envPlatform}
+		bo.Platforms = []string{envPlatform()}
 	} else {
 		// Make sure these are all unset
 		for _, env := range []string{"GOOS", "GOARCH", "GOARM"} {
@@ -142,9 +132,40 @@ func gobuildOptions(bo *options.BuildOptions) ([]build.Option, error) {
 		opts = append(opts, build.WithSBOMDir(bo.SBOMDir))
 	}
 
+	if bo.LicensePolicy != nil {
+		opts = append(opts, build.WithLicensePolicy(*bo.LicensePolicy))
+	}
+
+	if bo.Compression != "" || bo.CompressionLevel != 0 {
+		c, err := build.ParseCompression(bo.Compression, bo.CompressionLevel)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, build.WithCompression(c, bo.CompressionLevel))
+	}
+
 	return opts, nil
 }
 
+// envPlatform returns the platform to build for when none is configured.
+func envPlatform() string {
+	platform := "linux/amd64"
+
+	goos, goarch, goarm := os.Getenv("GOOS"), os.Getenv("GOARCH"), os.Getenv("GOARM")
+
+	// Default to linux/amd64 unless GOOS and GOARCH are set.
+	if goos != "" && goarch != "" {
+		platform = path.Join(goos, goarch)
+	}
+
+	// Use GOARM for variant if it's set and GOARCH is arm.
+	if strings.Contains(goarch, "arm") && goarm != "" {
+		platform = path.Join(platform, "v"+goarm)
+	}
+
+	return platform
+}
+
 // NewBuilder creates a ko builder
 func NewBuilder(ctx context.Context, bo *options.BuildOptions) (build.Interface, error) {
 	return makeBuilder(ctx, bo)
@@ -179,19 +200,28 @@ func makeBuilder(ctx context.Context, bo *options.BuildOptions) (*build.Caching,
 	return build.NewCaching(innerBuilder)
 }
 
-// NewPublisher creates a ko publisher
+// NewPublisher creates a ko publisher.  Image names and destinations from
+// `.ko.yaml` are only used if po.LoadConfig was called first.
 func NewPublisher(po *options.PublishOptions) (publish.Interface, error) {
-	return makePublisher(po)
+	return makePublisher(context.Background(), po)
 }
 
-func makePublisher(po *options.PublishOptions) (publish.Interface, error) {
+// makePublisher creates the publisher of a command.  The imageName templates
+// of `.ko.yaml` are executed with ctx.
+func makePublisher(ctx context.Context, po *options.PublishOptions) (publish.Interface, error) {
 	// use each tag only once
 	po.Tags = unique(po.Tags)
+	// Whether images are pushed to a registry, and the tags of those whose
+	// references are published.
+	var (
+		pushed      bool
+		primaryTags []string
+	)
 	// Create the publish.Interface that we will use to publish image references
 	// to either a docker daemon or a container image registry.
 	innerPublisher, err := func() (publish.Interface, error) {
 		repoName := po.DockerRepo
-		namer := options.MakeNamer(po)
+		namer := options.MakeNamer(ctx, po)
 		// Default LocalDomain if unset.
 		if po.LocalDomain == "" {
 			po.LocalDomain = publish.LocalDomain
@@ -200,8 +230,26 @@ func makePublisher(po *options.PublishOptions) (publish.Interface, error) {
 		if po.Local && repoName == "" {
 			repoName = po.LocalDomain
 		}
+		var localPlatform *v1.Platform
+		if po.LocalPlatform != "" {
+			p, err := v1.ParsePlatform(po.LocalPlatform)
+			if err != nil {
+				return nil, fmt.Errorf("parsing --local-platform: %w", err)
+			}
+			localPlatform = p
+		}
 		// When in doubt, if repoName is under the local domain, default to --local.
 		po.Local = po.Local || strings.HasPrefix(repoName, po.LocalDomain)
+		if len(po.Destinations) > 0 {
+			switch {
+			case po.Local:
+				log.Print("Ignoring publish.destinations of .ko.yaml, since images are published to the local daemon")
+			case slices.ContainsFunc(localDomains, func(d string) bool { return strings.HasPrefix(repoName, d) }):
+				log.Printf("Ignoring publish.destinations of .ko.yaml, since images are published to %s", repoName)
+			case !po.Push:
+				log.Print("Ignoring publish.destinations of .ko.yaml, since images aren't pushed")
+			}
+		}
 		if po.Local {
 			// TODO(jonjohnsonjr): I'm assuming that nobody will
 			// use local with other publishers, but that might
@@ -210,12 +258,32 @@ func makePublisher(po *options.PublishOptions) (publish.Interface, error) {
 			return publish.NewDaemon(namer, po.Tags,
 				publish.WithDockerClient(po.DockerClient),
 				publish.WithLocalDomain(po.LocalDomain),
+				publish.WithLocalPlatform(localPlatform),
 			)
 		}
+		if strings.HasPrefix(repoName, publish.ContainerdDomain) {
+			return publish.NewContainerd(repoName, namer, po.Tags)
+		}
+		if strings.HasPrefix(repoName, publish.PodmanDomain) {
+			return publish.NewPodman(repoName, namer, po.Tags, publish.WithLocalPlatform(localPlatform))
+		}
 		if strings.HasPrefix(repoName, publish.KindDomain) {
-			return publish.NewKindPublisher(repoName, namer, po.Tags), nil
+			return publish.NewKindPublisher(repoName, namer, po.Tags, publish.WithKindPlatform(localPlatform)), nil
+		}
+		if strings.HasPrefix(repoName, publish.K3dDomain) {
+			return publish.NewK3dPublisher(repoName, namer, po.Tags, publish.WithKindPlatform(localPlatform)), nil
+		}
+		if strings.HasPrefix(repoName, publish.MinikubeDomain) {
+			return publish.NewMinikubePublisher(repoName, namer, po.Tags, publish.WithKindPlatform(localPlatform)), nil
 		}
 
+		// Without KO_DOCKER_REPO, images are named after the primary
+		// destination.
+		envRepo := repoName
+		if repoName == "" && len(po.Destinations) > 0 {
+			d := primaryDestination(po.Destinations)
+			repoName, namer = d.Repo, options.MakeDestinationNamer(ctx, po, d)
+		}
 		if repoName == "" && po.Push {
 			return nil, errors.New("KO_DOCKER_REPO environment variable is unset")
 		}
@@ -227,30 +295,26 @@ func makePublisher(po *options.PublishOptions) (publish.Interface, error) {
 
 		publishers := []publish.Interface{}
 		if po.OCILayoutPath != "" {
-			lp := publish.NewLayout(po.OCILayoutPath)
+			lp := publish.NewLayout(po.OCILayoutPath,
+				publish.WithLayoutNamer(repoName, namer),
+				publish.WithLayoutTags(po.Tags),
+			)
 			publishers = append(publishers, lp)
 		}
 		if po.TarballFile != "" {
-			tp := publish.NewTarball(po.TarballFile, repoName, namer, po.Tags)
+			format, err := publish.ParseTarballFormat(po.TarballFormat)
+			if err != nil {
+				return nil, err
+			}
+			tp := publish.NewTarball(po.TarballFile, repoName, namer, po.Tags, publish.WithTarballFormat(format))
 			publishers = append(publishers, tp)
 		}
-		userAgent := ua()
-		if po.UserAgent != "" {
-			userAgent = po.UserAgent
-		}
 		if po.Push {
-			dp, err := publish.NewDefault(repoName,
-				publish.WithUserAgent(userAgent),
-				publish.WithAuthFromKeychain(keychain),
-				publish.WithNamer(namer),
-				publish.WithTags(po.Tags),
-				publish.WithTagOnly(po.TagOnly),
-				publish.Insecure(po.InsecureRegistry),
-				publish.WithJobs(po.Jobs),
-			)
+			dp, tags, err := makePushers(ctx, po, envRepo, namer)
 			if err != nil {
 				return nil, err
 			}
+			pushed, primaryTags = true, tags
 			publishers = append(publishers, dp)
 		}
 
@@ -270,21 +334,131 @@ func makePublisher(po *options.PublishOptions) (publish.Interface, error) {
 			})
 		}
 
-		return publish.MultiPublisher(publishers...), nil
+		// The last publisher is the primary, which is the pushing one when
+		// there is one.
+		last := len(publishers) - 1
+		return publish.NewMulti(publishers[last], publishers[:last],
+			publish.WithIgnoreSecondaryErrors(po.IgnoreOutputErrors),
+		), nil
 	}()
 	if err != nil {
 		return nil, err
 	}
 
 	if po.ImageRefsFile != "" {
-		innerPublisher, err = publish.NewRecorder(innerPublisher, po.ImageRefsFile)
+		format, err := publish.ParseImageRefsFormat(po.ImageRefsFormat)
+		if err != nil {
+			return nil, err
+		}
+		opts := []publish.RecorderOption{publish.WithImageRefsFormat(format)}
+		if pushed {
+			opts = append(opts, publish.WithRecorderPushed(primaryTags))
+		}
+		innerPublisher, err = publish.NewRecorder(innerPublisher, po.ImageRefsFile, opts...)
 		if err != nil {
 			return nil, err
 		}
 	}
 
 	// Wrap publisher in a memoizing publisher implementation.
-	return publish.NewCaching(innerPublisher)
+	return publish.NewCaching(namingPublisher{Interface: innerPublisher, po: po})
+}
+
+// namingPublisher reports why the imageName template of `.ko.yaml` couldn't
+// name an image, rather than the error of publishing it without a name.
+type namingPublisher struct {
+	publish.Interface
+	po *options.PublishOptions
+}
+
+// Publish implements publish.Interface
+func (n namingPublisher) Publish(ctx context.Context, br build.Result, s string) (name.Reference, error) {
+	ref, err := n.Interface.Publish(ctx, br, s)
+	if nerr := n.po.ImageNameError(strings.TrimPrefix(s, build.StrictScheme)); nerr != nil {
+		return nil, nerr
+	}
+	return ref, err
+}
+
+// localDomains are the prefixes of KO_DOCKER_REPO that publish to a local
+// runtime or cluster, rather than push to a registry.
+var localDomains = []string{
+	publish.ContainerdDomain,
+	publish.PodmanDomain,
+	publish.KindDomain,
+	publish.K3dDomain,
+	publish.MinikubeDomain,
+}
+
+// primaryDestination returns the destination marked primary, or else the
+// first one.
+func primaryDestination(destinations []options.PublishDestination) options.PublishDestination {
+	for _, d := range destinations {
+		if d.Primary {
+			return d
+		}
+	}
+	return destinations[0]
+}
+
+// makePushers returns the publisher that pushes to KO_DOCKER_REPO, if it is
+// set, and to each of the destinations of .ko.yaml, concurrently, with the
+// tags that the primary of them pushes.
+func makePushers(ctx context.Context, po *options.PublishOptions, envRepo string, namer publish.Namer) (publish.Interface, []string, error) {
+	userAgent := ua()
+	if po.UserAgent != "" {
+		userAgent = po.UserAgent
+	}
+	newDefault := func(repo string, namer publish.Namer, tags []string, insecure bool, kc authn.Keychain) (publish.Interface, error) {
+		return publish.NewDefault(repo,
+			publish.WithUserAgent(userAgent),
+			publish.WithAuthFromKeychain(kc),
+			publish.WithNamer(namer),
+			publish.WithTags(tags),
+			publish.WithTagOnly(po.TagOnly),
+			publish.WithImmutableTags(po.ImmutableTags),
+			publish.Insecure(insecure),
+			publish.WithJobs(po.Jobs),
+		)
+	}
+
+	// KO_DOCKER_REPO comes first, and is the primary unless a destination
+	// is marked primary.
+	var pushers []publish.Interface
+	var pusherTags [][]string
+	primary := 0
+	if envRepo != "" {
+		p, err := newDefault(envRepo, namer, po.Tags, po.InsecureRegistry, keychain)
+		if err != nil {
+			return nil, nil, err
+		}
+		pushers = append(pushers, p)
+		pusherTags = append(pusherTags, po.Tags)
+	}
+	for _, d := range po.Destinations {
+		kc, err := destinationKeychain(d.Keychain)
+		if err != nil {
+			return nil, nil, fmt.Errorf("publish destination %s: %w", d.Repo, err)
+		}
+		tags := po.Tags
+		if len(d.Tags) > 0 {
+			tags = unique(d.Tags)
+		}
+		p, err := newDefault(d.Repo, options.MakeDestinationNamer(ctx, po, d), tags, d.Insecure || po.InsecureRegistry, kc)
+		if err != nil {
+			return nil, nil, fmt.Errorf("publish destination %s: %w", d.Repo, err)
+		}
+		if d.Primary {
+			primary = len(pushers)
+		}
+		pushers = append(pushers, p)
+		pusherTags = append(pusherTags, tags)
+	}
+	if len(pushers) == 1 {
+		return pushers[0], pusherTags[0], nil
+	}
+	mirrors := slices.Delete(slices.Clone(pushers), primary, primary+1)
+	return publish.NewMulti(pushers[primary], mirrors), pusherTags[primary], nil
 }
 
 // nopPublisher simulates publishing without actually publishing anything, to
@@ -322,6 +322,141 @@ func TestNewPublisherCanPublish(t *testing.T) {
 	}
 }
 
+func TestNewPublisherDestinations(t *testing.T) {
+	importpath := "github.com/google/ko/test"
+	var hosts []string
+	for range 3 {
+		s := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+		defer s.Close()
+		hosts = append(hosts, s.Listener.Addr().String())
+	}
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := mustDigest(img)
+
+	for _, test := range []struct {
+		description string
+		dockerRepo  string
+		primary     bool
+		wantPrimary string
+	}{{
+		description: "KO_DOCKER_REPO is primary",
+		dockerRepo:  hosts[0] + "/env",
+		wantPrimary: hosts[0] + "/env",
+	}, {
+		description: "first destination is primary",
+		wantPrimary: hosts[1] + "/ecr/test",
+	}, {
+		description: "marked destination is primary",
+		dockerRepo:  hosts[0] + "/env",
+		primary:     true,
+		wantPrimary: hosts[2] + "/customer/" + importpath,
+	}} {
+		t.Run(test.description, func(t *testing.T) {
+			dir := t.TempDir()
+			config := fmt.Sprintf(`publish:
+  destinations:
+  - repo: %s/ecr
+    naming: base-import-paths
+  - repo: %s/customer
+    naming: preserve-import-paths
+    tags: [v1.2.3]
+    primary: %t
+`, hosts[1], hosts[2], test.primary)
+			if err := os.WriteFile(path.Join(dir, ".ko.yaml"), []byte(config), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			po := &options.PublishOptions{
+				DockerRepo:       test.dockerRepo,
+				Push:             true,
+				Tags:             []string{"latest"},
+				Bare:             true,
+				WorkingDirectory: dir,
+			}
+			if err := po.LoadConfig(); err != nil {
+				t.Fatalf("LoadConfig(): %v", err)
+			}
+			publisher, err := NewPublisher(po)
+			if err != nil {
+				t.Fatalf("NewPublisher(): %v", err)
+			}
+			defer publisher.Close()
+			ref, err := publisher.Publish(context.Background(), img, build.StrictScheme+importpath)
+			if err != nil {
+				t.Fatalf("publisher.Publish(): %v", err)
+			}
+
+			wants := []string{
+				hosts[1] + "/ecr/test:latest",
+				hosts[2] + "/customer/" + importpath + ":v1.2.3",
+			}
+			if test.dockerRepo != "" {
+				wants = append(wants, test.dockerRepo+":latest")
+			}
+			for _, want := range wants {
+				got, err := crane.Digest(want)
+				if err != nil {
+					t.Fatalf("crane.Digest(%s): %v", want, err)
+				}
+				if got != h.String() {
+					t.Errorf("%s = %s, wanted %s", want, got, h)
+				}
+			}
+			if got := ref.Context().Name(); got != test.wantPrimary {
+				t.Errorf("Publish() = %v, wanted it in %s", ref, test.wantPrimary)
+			}
+		})
+	}
+
+	// Without LoadConfig, as when embedding ko, .ko.yaml is not read.
+	dir := t.TempDir()
+	config := fmt.Sprintf("publish:\n  destinations:\n  - repo: %s/embedded\n", hosts[1])
+	if err := os.WriteFile(path.Join(dir, ".ko.yaml"), []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	publisher, err := NewPublisher(&options.PublishOptions{
+		DockerRepo:       hosts[0] + "/embedder",
+		Push:             true,
+		Tags:             []string{"latest"},
+		Bare:             true,
+		WorkingDirectory: dir,
+	})
+	if err != nil {
+		t.Fatalf("NewPublisher(): %v", err)
+	}
+	defer publisher.Close()
+	if _, err := publisher.Publish(context.Background(), img, build.StrictScheme+importpath); err != nil {
+		t.Fatalf("publisher.Publish(): %v", err)
+	}
+	if _, err := crane.Digest(hosts[1] + "/embedded:latest"); err == nil {
+		t.Errorf("NewPublisher() pushed to the destinations of %s", dir)
+	}
+}
+
+func TestNewPublisherImageNameError(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(path.Join(dir, "go.mod"), []byte("module example.com/app\n\ngo 1.21\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	publisher, err := NewPublisher(&options.PublishOptions{
+		DockerRepo:        "registry.example.com/repo",
+		ImageNameTemplate: "{{.Base}}/{{.Git.ShortCommit}}",
+		WorkingDirectory:  dir,
+	})
+	if err != nil {
+		t.Fatalf("NewPublisher(): %v", err)
+	}
+	defer publisher.Close()
+
+	// The template's error is reported, rather than the invalid name.
+	_, err = publisher.Publish(context.Background(), foo, build.StrictScheme+"example.com/app")
+	if err == nil || !strings.Contains(err.Error(), "git") {
+		t.Errorf("Publish() = %v, wanted the error of the imageName template", err)
+	}
+}
+
 // registryServerWithImage starts a local registry and pushes a random image.
 // Use this to speed up tests, by not having to reach out to gcr.io for the default base image.
 // The registry uses a NOP logger to avoid spamming test logs.
@@ -0,0 +1,51 @@
+// Copyright 2026 ko Build Authors All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package atomicfile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file")
+	if err := WriteFile(path, []byte("old"), 0o644); err != nil {
+		t.Fatalf("WriteFile() = %v", err)
+	}
+
+	errBad := errors.New("bad contents")
+	if err := Write(path, strings.NewReader("new"), 0o644, func() error { return errBad }); !errors.Is(err, errBad) {
+		t.Fatalf("Write() = %v, wanted %v", err, errBad)
+	}
+	if b, err := os.ReadFile(path); err != nil || string(b) != "old" {
+		t.Errorf("ReadFile() = %q, %v; wanted the failed write to leave %q", b, err, "old")
+	}
+
+	if err := WriteFile(path, []byte("new"), 0o644); err != nil {
+		t.Fatalf("WriteFile() = %v", err)
+	}
+	if b, err := os.ReadFile(path); err != nil || string(b) != "new" {
+		t.Errorf("ReadFile() = %q, %v; wanted %q", b, err, "new")
+	}
+
+	// No temporary files are left behind.
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) != 1 {
+		t.Errorf("ReadDir() = %v, %v; wanted just the file", entries, err)
+	}
+}
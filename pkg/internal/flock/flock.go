@@ -0,0 +1,95 @@
+// Copyright 2026 ko Build Authors All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package flock implements advisory file locks that are shared between
+// processes, to coordinate ko processes that use the same KOCACHE.
+package flock
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Lock is a lock held on a file.
+type Lock struct {
+	f *os.File
+}
+
+// Shared blocks until it holds a shared lock on the file at path, creating
+// the file if necessary.  Any number of shared locks may be held at once.
+func Shared(path string) (*Lock, error) {
+	l, _, err := lock(path, false, true)
+	return l, err
+}
+
+// Exclusive blocks until it holds an exclusive lock on the file at path,
+// creating the file if necessary.
+func Exclusive(path string) (*Lock, error) {
+	l, _, err := lock(path, true, true)
+	return l, err
+}
+
+// TryExclusive is like Exclusive, but returns false instead of blocking if
+// the file is locked by someone else.
+func TryExclusive(path string) (*Lock, bool, error) {
+	return lock(path, true, false)
+}
+
+func lock(path string, exclusive, wait bool) (*Lock, bool, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, false, err
+	}
+	locked, err := lockFile(f, exclusive, wait)
+	if err != nil || !locked {
+		f.Close()
+		if err != nil {
+			return nil, false, fmt.Errorf("locking %s: %w", path, err)
+		}
+		return nil, false, nil
+	}
+	return &Lock{f: f}, true, nil
+}
+
+// Unlock releases the lock.
+func (l *Lock) Unlock() error {
+	err := unlockFile(l.f)
+	if cerr := l.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+var (
+	heldMu sync.Mutex
+	held   = map[string]*Lock{}
+)
+
+// Hold takes a shared lock on the file at path, unless this process already
+// holds one, and keeps it until the process exits.
+func Hold(path string) error {
+	heldMu.Lock()
+	defer heldMu.Unlock()
+
+	if _, ok := held[path]; ok {
+		return nil
+	}
+	l, err := Shared(path)
+	if err != nil {
+		return err
+	}
+	held[path] = l
+	return nil
+}
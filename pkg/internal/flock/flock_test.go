@@ -0,0 +1,67 @@
+// Copyright 2026 ko Build Authors All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flock
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lock")
+
+	s1, err := Shared(path)
+	if err != nil {
+		t.Fatalf("Shared() = %v", err)
+	}
+	s2, err := Shared(path)
+	if err != nil {
+		t.Fatalf("Shared() = %v", err)
+	}
+	if _, ok, err := TryExclusive(path); err != nil || ok {
+		t.Fatalf("TryExclusive() = %v, %v; wanted it to fail while shared locks are held", ok, err)
+	}
+
+	if err := s1.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s2.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	ex, ok, err := TryExclusive(path)
+	if err != nil || !ok {
+		t.Fatalf("TryExclusive() = %v, %v; wanted it to succeed", ok, err)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		l, err := Shared(path)
+		if err != nil {
+			t.Errorf("Shared() = %v", err)
+			return
+		}
+		l.Unlock()
+	}()
+	select {
+	case <-done:
+		t.Fatal("Shared() returned while an exclusive lock was held")
+	case <-time.After(100 * time.Millisecond):
+	}
+	if err := ex.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+}
@@ -0,0 +1,45 @@
+// Copyright 2026 ko Build Authors All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package uname maps the machine names that `uname -m` prints to the
+// platforms of images.
+package uname
+
+import (
+	"fmt"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// Platform returns the Linux platform of a machine given its `uname -m`.
+func Platform(machine string) (*v1.Platform, error) {
+	p := &v1.Platform{OS: "linux"}
+	switch machine {
+	case "x86_64", "amd64":
+		p.Architecture = "amd64"
+	case "aarch64", "arm64":
+		p.Architecture = "arm64"
+	case "armv7l", "armv7":
+		p.Architecture, p.Variant = "arm", "v7"
+	case "armv6l", "armv6":
+		p.Architecture, p.Variant = "arm", "v6"
+	case "i386", "i686":
+		p.Architecture = "386"
+	case "ppc64le", "s390x", "riscv64":
+		p.Architecture = machine
+	default:
+		return nil, fmt.Errorf("unknown node architecture %q", machine)
+	}
+	return p, nil
+}
@@ -0,0 +1,42 @@
+// Copyright 2026 ko Build Authors All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package uname
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+func TestPlatform(t *testing.T) {
+	for machine, want := range map[string]*v1.Platform{
+		"x86_64":  {OS: "linux", Architecture: "amd64"},
+		"aarch64": {OS: "linux", Architecture: "arm64"},
+		"armv7l":  {OS: "linux", Architecture: "arm", Variant: "v7"},
+		"s390x":   {OS: "linux", Architecture: "s390x"},
+	} {
+		got, err := Platform(machine)
+		if err != nil {
+			t.Errorf("Platform(%q) = %v", machine, err)
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("Platform(%q) (-want +got): %s", machine, diff)
+		}
+	}
+	if _, err := Platform("vax"); err == nil {
+		t.Error("Platform(vax) = nil, wanted an error")
+	}
+}
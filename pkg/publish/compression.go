@@ -0,0 +1,84 @@
+// Copyright 2026 ko Build Authors All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package publish
+
+import (
+	"fmt"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// gzipLayers returns img with any zstd layers recompressed with gzip, for
+// destinations such as the Docker daemon's image tarballs that can't load
+// zstd layers.  The layers' contents, and so the image's config, are
+// unchanged, but its digest is not.
+func gzipLayers(img v1.Image) (v1.Image, error) {
+	m, err := img.Manifest()
+	if err != nil {
+		return nil, err
+	}
+	var zstd bool
+	for _, desc := range m.Layers {
+		if desc.MediaType == types.OCILayerZStd {
+			zstd = true
+		}
+	}
+	if !zstd {
+		return img, nil
+	}
+
+	layers, err := img.Layers()
+	if err != nil {
+		return nil, err
+	}
+	adds := make([]mutate.Addendum, len(layers))
+	for i, l := range layers {
+		desc := m.Layers[i]
+		if desc.MediaType == types.OCILayerZStd {
+			desc.MediaType = types.OCILayer
+			l, err = tarball.LayerFromOpener(l.Uncompressed, tarball.WithMediaType(desc.MediaType))
+			if err != nil {
+				return nil, fmt.Errorf("recompressing layer %s: %w", desc.Digest, err)
+			}
+		}
+		adds[i] = mutate.Addendum{
+			Layer:       l,
+			MediaType:   desc.MediaType,
+			Annotations: desc.Annotations,
+		}
+	}
+
+	out := mutate.ConfigMediaType(mutate.MediaType(empty.Image, m.MediaType), m.Config.MediaType)
+	out, err = mutate.Append(out, adds...)
+	if err != nil {
+		return nil, err
+	}
+	cf, err := img.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	out, err = mutate.ConfigFile(out, cf)
+	if err != nil {
+		return nil, err
+	}
+	if len(m.Annotations) > 0 {
+		out = mutate.Annotations(out, m.Annotations).(v1.Image)
+	}
+	return out, nil
+}
@@ -0,0 +1,131 @@
+// Copyright 2026 ko Build Authors All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package publish
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/compression"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+)
+
+// zstdImage returns an image with a gzip layer and an annotated zstd layer.
+func zstdImage(t *testing.T) v1.Image {
+	t.Helper()
+	gz, err := random.Layer(1024, types.OCILayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	rc, err := gz.Uncompressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.Copy(&buf, rc); err != nil {
+		t.Fatal(err)
+	}
+	zstd, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(buf.Bytes())), nil
+	}, tarball.WithCompression(compression.ZStd), tarball.WithMediaType(types.OCILayerZStd))
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := mutate.Append(mutate.MediaType(empty.Image, types.OCIManifestSchema1),
+		mutate.Addendum{Layer: gz},
+		mutate.Addendum{Layer: zstd, Annotations: map[string]string{"a": "b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+func TestGzipLayers(t *testing.T) {
+	img := zstdImage(t)
+	got, err := gzipLayers(img)
+	if err != nil {
+		t.Fatalf("gzipLayers() = %v", err)
+	}
+	if err := validate.Image(got); err != nil {
+		t.Fatalf("validate.Image() = %v", err)
+	}
+	m, err := got.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, desc := range m.Layers {
+		if desc.MediaType != types.OCILayer {
+			t.Errorf("layer %d: mediaType = %q, wanted %q", i, desc.MediaType, types.OCILayer)
+		}
+	}
+	if got := m.Layers[1].Annotations["a"]; got != "b" {
+		t.Errorf("layer annotations = %v, wanted them kept", m.Layers[1].Annotations)
+	}
+	// The layers hold the same files, so the config is the same.
+	wantCF, err := img.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotCF, err := got.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(wantCF, gotCF); diff != "" {
+		t.Errorf("ConfigFile() (-want +got): %s", diff)
+	}
+
+	// Images without zstd layers are left alone.
+	plain, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := gzipLayers(plain); err != nil || got != v1.Image(plain) {
+		t.Errorf("gzipLayers(gzip image) = %v, %v; wanted it unchanged", got, err)
+	}
+}
+
+func TestKindLoadsGzipDigest(t *testing.T) {
+	var loaded v1.Image
+	kp := &kindPublisher{
+		base:  KindDomain,
+		namer: func(base, _ string) string { return base + "/app" },
+		write: func(_ context.Context, _ name.Tag, img v1.Image) error {
+			loaded = img
+			return nil
+		},
+	}
+	ref, err := kp.Publish(context.Background(), zstdImage(t), "example.com/app")
+	if err != nil {
+		t.Fatalf("Publish() = %v", err)
+	}
+	h, err := loaded.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The reference names the image that is loaded, not the zstd one.
+	if got, want := ref.Identifier(), h.Hex; got != want {
+		t.Errorf("Publish() = %v, wanted tag %v", ref, want)
+	}
+}
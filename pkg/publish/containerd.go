@@ -0,0 +1,119 @@
+// Copyright 2026 ko Build Authors All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package publish
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/ko/pkg/build"
+	"github.com/google/ko/pkg/publish/containerd"
+)
+
+const (
+	// ContainerdDomain is a sentinel "registry" that represents side-loading images into containerd.
+	ContainerdDomain = "containerd.local"
+)
+
+type containerdPublisher struct {
+	base      string
+	namer     Namer
+	tags      []string
+	address   string
+	namespace string
+
+	client *containerd.Client
+}
+
+// ContainerdOption is a functional option for NewContainerd.
+type ContainerdOption func(*containerdPublisher)
+
+// WithContainerdAddress is a functional option for the socket of containerd,
+// instead of $CONTAINERD_ADDRESS or /run/containerd/containerd.sock.
+func WithContainerdAddress(address string) ContainerdOption {
+	return func(c *containerdPublisher) {
+		c.address = address
+	}
+}
+
+// WithContainerdNamespace is a functional option for the containerd
+// namespace to import images into, instead of $CONTAINERD_NAMESPACE or
+// "default".  Kubernetes uses "k8s.io".
+func WithContainerdNamespace(namespace string) ContainerdOption {
+	return func(c *containerdPublisher) {
+		c.namespace = namespace
+	}
+}
+
+// NewContainerd returns a new publish.Interface that imports images into a
+// containerd namespace.
+func NewContainerd(base string, namer Namer, tags []string, opts ...ContainerdOption) (Interface, error) {
+	c := &containerdPublisher{
+		base:  base,
+		namer: namer,
+		tags:  tags,
+	}
+	for _, option := range opts {
+		option(c)
+	}
+	client, err := containerd.New(c.address, c.namespace)
+	if err != nil {
+		return nil, err
+	}
+	c.client = client
+	return c, nil
+}
+
+// Publish implements publish.Interface.
+func (c *containerdPublisher) Publish(ctx context.Context, br build.Result, s string) (name.Reference, error) {
+	s = strings.TrimPrefix(s, build.StrictScheme)
+	// https://github.com/google/go-containerregistry/issues/212
+	s = strings.ToLower(s)
+
+	h, err := br.Digest()
+	if err != nil {
+		return nil, err
+	}
+	repo := c.namer(c.base, s)
+	digestTag, err := name.NewTag(fmt.Sprintf("%s:%s", repo, h.Hex))
+	if err != nil {
+		return nil, err
+	}
+	tags := []name.Tag{digestTag}
+	for _, tagName := range c.tags {
+		tag, err := name.NewTag(fmt.Sprintf("%s:%s", repo, tagName))
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+
+	// containerd holds multi-platform images as they are, and the runtime
+	// unpacks the platform it needs.
+	log.Printf("Loading %v", digestTag)
+	if err := c.client.Write(ctx, br, tags...); err != nil {
+		return nil, err
+	}
+	log.Printf("Loaded %v", digestTag)
+
+	return &digestTag, nil
+}
+
+func (c *containerdPublisher) Close() error {
+	return c.client.Close()
+}
@@ -0,0 +1,91 @@
+// Copyright 2026 ko Build Authors All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package containerd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	contentapi "github.com/containerd/containerd/api/services/content/v1"
+	imagesapi "github.com/containerd/containerd/api/services/images/v1"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"google.golang.org/grpc/metadata"
+)
+
+// Get returns the descriptor of the image or index that the image named ref
+// points to.
+func (c *Client) Get(ctx context.Context, ref string) (*v1.Descriptor, error) {
+	ctx = metadata.AppendToOutgoingContext(ctx, namespaceHeader, c.namespace)
+	resp, err := c.images.Get(ctx, &imagesapi.GetImageRequest{Name: ref})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get image %s from containerd: %w", ref, err)
+	}
+	target := resp.GetImage().GetTarget()
+	if target == nil {
+		return nil, fmt.Errorf("image %s in containerd has no target", ref)
+	}
+	h, err := v1.NewHash(target.Digest)
+	if err != nil {
+		return nil, fmt.Errorf("image %s in containerd: %w", ref, err)
+	}
+	return &v1.Descriptor{
+		MediaType: types.MediaType(target.MediaType),
+		Digest:    h,
+		Size:      target.Size,
+	}, nil
+}
+
+// Open returns a reader of the blob h in containerd's content store.
+func (c *Client) Open(ctx context.Context, h v1.Hash) (io.ReadCloser, error) {
+	ctx = metadata.AppendToOutgoingContext(ctx, namespaceHeader, c.namespace)
+	ctx, cancel := context.WithCancel(ctx)
+	stream, err := c.content.Read(ctx, &contentapi.ReadContentRequest{Digest: h.String()})
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to read blob %s: %w", h, err)
+	}
+	return &blobReader{h: h, stream: stream, cancel: cancel}, nil
+}
+
+// blobReader reads a blob from the chunks that containerd streams.
+type blobReader struct {
+	h      v1.Hash
+	stream contentapi.Content_ReadClient
+	cancel context.CancelFunc
+	buf    []byte
+}
+
+func (r *blobReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		resp, err := r.stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return 0, io.EOF
+		} else if err != nil {
+			return 0, fmt.Errorf("failed to read blob %s: %w", r.h, err)
+		}
+		r.buf = resp.Data
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+func (r *blobReader) Close() error {
+	r.cancel()
+	return nil
+}
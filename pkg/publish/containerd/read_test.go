@@ -0,0 +1,101 @@
+// Copyright 2026 ko Build Authors All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package containerd
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+func TestRead(t *testing.T) {
+	_, sock := serve(t)
+	c, err := New(sock, "")
+	if err != nil {
+		t.Fatalf("New() = %v", err)
+	}
+	defer c.Close()
+
+	idx, err := random.Index(3*chunkSize/2, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag, err := name.NewTag("registry.example.com/base:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Write(context.Background(), idx, tag); err != nil {
+		t.Fatalf("Write() = %v", err)
+	}
+
+	desc, err := c.Get(context.Background(), tag.String())
+	if err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+	h, err := idx.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if desc.Digest != h || desc.MediaType != types.OCIImageIndex {
+		t.Errorf("Get() = %v %v, wanted %v %v", desc.MediaType, desc.Digest, types.OCIImageIndex, h)
+	}
+
+	// Layers are read back whole, however many chunks they take.
+	m, err := idx.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := idx.Image(m.Manifests[0].Digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	layers, err := img.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	lh, err := layers[0].Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc, err := c.Open(context.Background(), lh)
+	if err != nil {
+		t.Fatalf("Open() = %v", err)
+	}
+	got, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("ReadAll() = %v", err)
+	}
+	rc.Close()
+	lrc, err := layers[0].Compressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := io.ReadAll(lrc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Open() read %d bytes, wanted the %d of the layer", len(got), len(want))
+	}
+
+	if _, err := c.Get(context.Background(), "registry.example.com/missing:latest"); err == nil {
+		t.Error("Get(missing) = nil, wanted an error")
+	}
+}
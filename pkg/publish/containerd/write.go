@@ -0,0 +1,328 @@
+// Copyright 2026 ko Build Authors All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package containerd
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	contentapi "github.com/containerd/containerd/api/services/content/v1"
+	imagesapi "github.com/containerd/containerd/api/services/images/v1"
+	leasesapi "github.com/containerd/containerd/api/services/leases/v1"
+	"github.com/containerd/containerd/api/types"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/ko/pkg/build"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// The same environment variables as ctr and nerdctl.
+	addressEnvKey   = "CONTAINERD_ADDRESS"
+	namespaceEnvKey = "CONTAINERD_NAMESPACE"
+
+	defaultAddress   = "/run/containerd/containerd.sock"
+	defaultNamespace = "default"
+
+	// gRPC metadata that containerd reads the namespace and lease from.
+	namespaceHeader = "containerd-namespace"
+	leaseHeader     = "containerd-lease"
+
+	// Labels that tell containerd's garbage collector what to keep.
+	gcExpireLabel = "containerd.io/gc.expire"
+	gcRefLabel    = "containerd.io/gc.ref.content."
+
+	chunkSize = 1 << 20
+)
+
+// Client imports images into a containerd namespace over its gRPC socket.
+type Client struct {
+	conn      *grpc.ClientConn
+	namespace string
+	content   contentapi.ContentClient
+	images    imagesapi.ImagesClient
+	leases    leasesapi.LeasesClient
+}
+
+// New returns a Client of the containerd at address, for namespace.  If they
+// are empty, they default to $CONTAINERD_ADDRESS and $CONTAINERD_NAMESPACE,
+// and then to /run/containerd/containerd.sock and the default namespace.
+func New(address, namespace string) (*Client, error) {
+	if address == "" {
+		address = os.Getenv(addressEnvKey)
+	}
+	if address == "" {
+		address = defaultAddress
+	}
+	if namespace == "" {
+		namespace = os.Getenv(namespaceEnvKey)
+	}
+	if namespace == "" {
+		namespace = defaultNamespace
+	}
+	if !strings.Contains(address, "://") {
+		address = "unix://" + address
+	}
+
+	conn, err := grpc.NewClient(address,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithAuthority("localhost"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to containerd at %s: %w", address, err)
+	}
+	return &Client{
+		conn:      conn,
+		namespace: namespace,
+		content:   contentapi.NewContentClient(conn),
+		images:    imagesapi.NewImagesClient(conn),
+		leases:    leasesapi.NewLeasesClient(conn),
+	}, nil
+}
+
+// Close closes the connection to containerd.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Write saves the image or index into containerd as each of the tags.
+func (c *Client) Write(ctx context.Context, br build.Result, tags ...name.Tag) error {
+	ctx = metadata.AppendToOutgoingContext(ctx, namespaceHeader, c.namespace)
+
+	// Keep the blobs from being garbage collected until the images that
+	// refer to them exist.
+	lease, err := c.leases.Create(ctx, &leasesapi.CreateRequest{
+		ID:     "ko-" + randomHex(),
+		Labels: map[string]string{gcExpireLabel: time.Now().Add(time.Hour).UTC().Format(time.RFC3339)},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create lease in containerd: %w", err)
+	}
+	defer func() {
+		_, _ = c.leases.Delete(ctx, &leasesapi.DeleteRequest{ID: lease.Lease.ID})
+	}()
+	leased := metadata.AppendToOutgoingContext(ctx, leaseHeader, lease.Lease.ID)
+
+	desc, err := c.writeResult(leased, br)
+	if err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		if err := c.tag(leased, tag.String(), desc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Client) tag(ctx context.Context, ref string, desc *types.Descriptor) error {
+	img := &imagesapi.Image{Name: ref, Target: desc}
+	if _, err := c.images.Create(ctx, &imagesapi.CreateImageRequest{Image: img}); status.Code(err) == codes.AlreadyExists {
+		_, err = c.images.Update(ctx, &imagesapi.UpdateImageRequest{Image: img})
+		if err != nil {
+			return fmt.Errorf("failed to update image %s: %w", ref, err)
+		}
+	} else if err != nil {
+		return fmt.Errorf("failed to create image %s: %w", ref, err)
+	}
+	return nil
+}
+
+// writeResult writes the blobs of br, children first, and returns its
+// descriptor.
+func (c *Client) writeResult(ctx context.Context, br build.Result) (*types.Descriptor, error) {
+	labels := map[string]string{}
+	switch br := br.(type) {
+	case v1.ImageIndex:
+		m, err := br.IndexManifest()
+		if err != nil {
+			return nil, err
+		}
+		for i, desc := range m.Manifests {
+			var child build.Result
+			switch {
+			case desc.MediaType.IsIndex():
+				child, err = br.ImageIndex(desc.Digest)
+			case desc.MediaType.IsImage():
+				child, err = br.Image(desc.Digest)
+			default:
+				return nil, fmt.Errorf("unsupported media type %q for %s", desc.MediaType, desc.Digest)
+			}
+			if err != nil {
+				return nil, err
+			}
+			if _, err := c.writeResult(ctx, child); err != nil {
+				return nil, err
+			}
+			labels[fmt.Sprintf("%sm.%d", gcRefLabel, i)] = desc.Digest.String()
+		}
+	case v1.Image:
+		layers, err := br.Layers()
+		if err != nil {
+			return nil, err
+		}
+		for i, l := range layers {
+			mt, err := l.MediaType()
+			if err != nil {
+				return nil, err
+			}
+			if !mt.IsDistributable() {
+				continue
+			}
+			h, err := l.Digest()
+			if err != nil {
+				return nil, err
+			}
+			size, err := l.Size()
+			if err != nil {
+				return nil, err
+			}
+			if err := c.writeBlob(ctx, h, size, l.Compressed, nil); err != nil {
+				return nil, err
+			}
+			labels[fmt.Sprintf("%sl.%d", gcRefLabel, i)] = h.String()
+		}
+		h, err := br.ConfigName()
+		if err != nil {
+			return nil, err
+		}
+		cfg, err := br.RawConfigFile()
+		if err != nil {
+			return nil, err
+		}
+		if err := c.writeBlob(ctx, h, int64(len(cfg)), opener(cfg), nil); err != nil {
+			return nil, err
+		}
+		labels[gcRefLabel+"config"] = h.String()
+	default:
+		return nil, fmt.Errorf("unsupported result type %T", br)
+	}
+
+	mt, err := br.MediaType()
+	if err != nil {
+		return nil, err
+	}
+	h, err := br.Digest()
+	if err != nil {
+		return nil, err
+	}
+	m, err := br.RawManifest()
+	if err != nil {
+		return nil, err
+	}
+	if err := c.writeBlob(ctx, h, int64(len(m)), opener(m), labels); err != nil {
+		return nil, err
+	}
+	return &types.Descriptor{MediaType: string(mt), Digest: h.String(), Size: int64(len(m))}, nil
+}
+
+// writeBlob writes a blob to containerd's content store, unless it is
+// already there.
+func (c *Client) writeBlob(ctx context.Context, h v1.Hash, size int64, open func() (io.ReadCloser, error), labels map[string]string) error {
+	if _, err := c.content.Info(ctx, &contentapi.InfoRequest{Digest: h.String()}); err == nil {
+		return nil
+	} else if status.Code(err) != codes.NotFound {
+		return fmt.Errorf("failed to get blob %s: %w", h, err)
+	}
+
+	rc, err := open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	// Each write has a ref of its own, so concurrent writes of the same blob
+	// don't wait on each other.  Only the first to commit is kept.
+	ref := fmt.Sprintf("ko-%s-%s", h.Hex, randomHex())
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := c.content.Write(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to write blob %s: %w", h, err)
+	}
+	send := func(req *contentapi.WriteContentRequest) error {
+		req.Ref, req.Total, req.Expected = ref, size, h.String()
+		if err := stream.Send(req); err != nil {
+			return err
+		}
+		_, err := stream.Recv()
+		return err
+	}
+
+	var offset int64
+	buf := make([]byte, chunkSize)
+	for {
+		n, err := io.ReadFull(rc, buf)
+		if n > 0 {
+			if err := send(&contentapi.WriteContentRequest{
+				Action: contentapi.WriteAction_WRITE,
+				Offset: offset,
+				Data:   buf[:n],
+			}); err != nil {
+				return writeErr(h, err)
+			}
+			offset += int64(n)
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		} else if err != nil {
+			return fmt.Errorf("failed to read blob %s: %w", h, err)
+		}
+	}
+	if offset != size {
+		return fmt.Errorf("blob %s has %d bytes, expected %d", h, offset, size)
+	}
+	if err := send(&contentapi.WriteContentRequest{
+		Action: contentapi.WriteAction_COMMIT,
+		Offset: offset,
+		Labels: labels,
+	}); err != nil {
+		return writeErr(h, err)
+	}
+	return nil
+}
+
+// writeErr returns the error from writing the blob h, which is none if
+// another write of it got there first.
+func writeErr(h v1.Hash, err error) error {
+	if status.Code(err) == codes.AlreadyExists {
+		return nil
+	}
+	return fmt.Errorf("failed to write blob %s: %w", h, err)
+}
+
+func opener(b []byte) func() (io.ReadCloser, error) {
+	return func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(b)), nil
+	}
+}
+
+func randomHex() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,350 @@
+// Copyright 2026 ko Build Authors All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package containerd
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	contentapi "github.com/containerd/containerd/api/services/content/v1"
+	imagesapi "github.com/containerd/containerd/api/services/images/v1"
+	leasesapi "github.com/containerd/containerd/api/services/leases/v1"
+	"github.com/containerd/containerd/api/types"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// fakeContainerd keeps the blobs and images written to it in memory.
+type fakeContainerd struct {
+	mu         sync.Mutex
+	blobs      map[string][]byte
+	labels     map[string]map[string]string
+	images     map[string]string
+	mediaTypes map[string]string
+	leases     map[string]bool
+	namespaces map[string]bool
+	writes     int
+}
+
+func (f *fakeContainerd) checkNamespace(ctx context.Context) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	ns := md.Get(namespaceHeader)
+	if len(ns) != 1 {
+		return status.Error(codes.FailedPrecondition, "namespace is required")
+	}
+	f.namespaces[ns[0]] = true
+	return nil
+}
+
+func (f *fakeContainerd) checkLease(ctx context.Context) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if l := md.Get(leaseHeader); len(l) != 1 || !f.leases[l[0]] {
+		return status.Errorf(codes.FailedPrecondition, "no lease %v", l)
+	}
+	return nil
+}
+
+// The services of a fakeContainerd, whose methods have the same names.
+type (
+	fakeContent struct {
+		contentapi.UnimplementedContentServer
+		*fakeContainerd
+	}
+	fakeImages struct {
+		imagesapi.UnimplementedImagesServer
+		*fakeContainerd
+	}
+	fakeLeases struct {
+		leasesapi.UnimplementedLeasesServer
+		*fakeContainerd
+	}
+)
+
+func (f fakeContent) Info(ctx context.Context, req *contentapi.InfoRequest) (*contentapi.InfoResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.checkNamespace(ctx); err != nil {
+		return nil, err
+	}
+	b, ok := f.blobs[req.Digest]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "%s not found", req.Digest)
+	}
+	return &contentapi.InfoResponse{Info: &contentapi.Info{Digest: req.Digest, Size: int64(len(b))}}, nil
+}
+
+// Read streams the blob in chunks of at most chunkSize bytes.
+func (f fakeContent) Read(req *contentapi.ReadContentRequest, stream contentapi.Content_ReadServer) error {
+	f.mu.Lock()
+	err := f.checkNamespace(stream.Context())
+	data, ok := f.blobs[req.Digest]
+	f.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return status.Errorf(codes.NotFound, "%s not found", req.Digest)
+	}
+	for offset := 0; offset < len(data); offset += chunkSize {
+		chunk := data[offset:min(offset+chunkSize, len(data))]
+		if err := stream.Send(&contentapi.ReadContentResponse{Offset: int64(offset), Data: chunk}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f fakeContent) Write(stream contentapi.Content_WriteServer) error {
+	var data []byte
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		f.mu.Lock()
+		if err := f.checkNamespace(stream.Context()); err != nil {
+			f.mu.Unlock()
+			return err
+		}
+		if err := f.checkLease(stream.Context()); err != nil {
+			f.mu.Unlock()
+			return err
+		}
+		f.mu.Unlock()
+		if req.Offset != int64(len(data)) {
+			return status.Errorf(codes.OutOfRange, "write at %d, expected %d", req.Offset, len(data))
+		}
+		data = append(data, req.Data...)
+		if req.Action == contentapi.WriteAction_COMMIT {
+			if got := fmt.Sprintf("sha256:%x", sha256.Sum256(data)); got != req.Expected {
+				return status.Errorf(codes.FailedPrecondition, "got digest %s, expected %s", got, req.Expected)
+			}
+			f.mu.Lock()
+			f.blobs[req.Expected] = data
+			f.labels[req.Expected] = req.Labels
+			f.writes++
+			f.mu.Unlock()
+		}
+		if err := stream.Send(&contentapi.WriteContentResponse{Action: req.Action, Offset: int64(len(data))}); err != nil {
+			return err
+		}
+	}
+}
+
+func (f fakeImages) Create(ctx context.Context, req *imagesapi.CreateImageRequest) (*imagesapi.CreateImageResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.checkNamespace(ctx); err != nil {
+		return nil, err
+	}
+	if _, ok := f.images[req.Image.Name]; ok {
+		return nil, status.Errorf(codes.AlreadyExists, "%s exists", req.Image.Name)
+	}
+	if _, ok := f.blobs[req.Image.Target.Digest]; !ok {
+		return nil, status.Errorf(codes.NotFound, "%s not found", req.Image.Target.Digest)
+	}
+	f.images[req.Image.Name] = req.Image.Target.Digest
+	f.mediaTypes[req.Image.Name] = req.Image.Target.MediaType
+	return &imagesapi.CreateImageResponse{Image: req.Image}, nil
+}
+
+func (f fakeImages) Get(ctx context.Context, req *imagesapi.GetImageRequest) (*imagesapi.GetImageResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.checkNamespace(ctx); err != nil {
+		return nil, err
+	}
+	dig, ok := f.images[req.Name]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "%s not found", req.Name)
+	}
+	return &imagesapi.GetImageResponse{Image: &imagesapi.Image{
+		Name:   req.Name,
+		Target: &types.Descriptor{MediaType: f.mediaTypes[req.Name], Digest: dig, Size: int64(len(f.blobs[dig]))},
+	}}, nil
+}
+
+func (f fakeImages) Update(ctx context.Context, req *imagesapi.UpdateImageRequest) (*imagesapi.UpdateImageResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.checkNamespace(ctx); err != nil {
+		return nil, err
+	}
+	f.images[req.Image.Name] = req.Image.Target.Digest
+	f.mediaTypes[req.Image.Name] = req.Image.Target.MediaType
+	return &imagesapi.UpdateImageResponse{Image: req.Image}, nil
+}
+
+func (f fakeLeases) Create(ctx context.Context, req *leasesapi.CreateRequest) (*leasesapi.CreateResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.checkNamespace(ctx); err != nil {
+		return nil, err
+	}
+	f.leases[req.ID] = true
+	return &leasesapi.CreateResponse{Lease: &leasesapi.Lease{ID: req.ID, Labels: req.Labels}}, nil
+}
+
+func (f fakeLeases) Delete(_ context.Context, req *leasesapi.DeleteRequest) (*emptypb.Empty, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.leases, req.ID)
+	return &emptypb.Empty{}, nil
+}
+
+func serve(t *testing.T) (*fakeContainerd, string) {
+	t.Helper()
+	f := &fakeContainerd{
+		blobs:      map[string][]byte{},
+		labels:     map[string]map[string]string{},
+		images:     map[string]string{},
+		mediaTypes: map[string]string{},
+		leases:     map[string]bool{},
+		namespaces: map[string]bool{},
+	}
+	sock := filepath.Join(t.TempDir(), "containerd.sock")
+	lis, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer()
+	contentapi.RegisterContentServer(s, fakeContent{fakeContainerd: f})
+	imagesapi.RegisterImagesServer(s, fakeImages{fakeContainerd: f})
+	leasesapi.RegisterLeasesServer(s, fakeLeases{fakeContainerd: f})
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+	return f, sock
+}
+
+func TestWrite(t *testing.T) {
+	f, sock := serve(t)
+	c, err := New(sock, "k8s.io")
+	if err != nil {
+		t.Fatalf("New() = %v", err)
+	}
+	defer c.Close()
+
+	// Big enough to be written in more than one chunk.
+	idx, err := random.Index(3*chunkSize/2, 2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := idx.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag1, err := name.NewTag("containerd.local/test:" + h.Hex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag2, err := name.NewTag("containerd.local/test:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Write(context.Background(), idx, tag1, tag2); err != nil {
+		t.Fatalf("Write() = %v", err)
+	}
+
+	// The index, 2 images with 2 layers and a config each.
+	if got, want := len(f.blobs), 1+2*(1+2+1); got != want {
+		t.Errorf("wrote %d blobs, wanted %d", got, want)
+	}
+	for _, tag := range []name.Tag{tag1, tag2} {
+		if got := f.images[tag.String()]; got != h.String() {
+			t.Errorf("image %s = %s, wanted %s", tag, got, h)
+		}
+	}
+	if !f.namespaces["k8s.io"] || len(f.namespaces) != 1 {
+		t.Errorf("used namespaces %v, wanted k8s.io", f.namespaces)
+	}
+	if len(f.leases) != 0 {
+		t.Errorf("leases %v left behind", f.leases)
+	}
+
+	// The garbage collector can tell what the index and images refer to.
+	m, err := idx.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := f.labels[h.String()]["containerd.io/gc.ref.content.m.1"], m.Manifests[1].Digest.String(); got != want {
+		t.Errorf("index gc label = %q, wanted %q", got, want)
+	}
+	img, err := idx.Image(m.Manifests[0].Digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := img.ConfigName()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := f.labels[m.Manifests[0].Digest.String()]["containerd.io/gc.ref.content.config"]; got != cfg.String() {
+		t.Errorf("image gc label = %q, wanted %q", got, cfg)
+	}
+
+	// Writing it again only moves the tags.
+	writes := f.writes
+	if err := c.Write(context.Background(), idx, tag2); err != nil {
+		t.Fatalf("Write() = %v", err)
+	}
+	if f.writes != writes {
+		t.Errorf("wrote %d blobs again", f.writes-writes)
+	}
+
+	// Single images work too.
+	single, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Write(context.Background(), single, tag2); err != nil {
+		t.Fatalf("Write() = %v", err)
+	}
+	if d, err := single.Digest(); err != nil {
+		t.Fatal(err)
+	} else if got := f.images[tag2.String()]; got != d.String() {
+		t.Errorf("image %s = %s, wanted %s", tag2, got, d)
+	}
+}
+
+func TestWriteUnavailable(t *testing.T) {
+	c, err := New(filepath.Join(t.TempDir(), "missing.sock"), "")
+	if err != nil {
+		t.Fatalf("New() = %v", err)
+	}
+	defer c.Close()
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag, err := name.NewTag("containerd.local/test:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Write(context.Background(), img, tag); err == nil {
+		t.Error("Write() = nil, wanted an error")
+	}
+}
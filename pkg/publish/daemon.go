@@ -17,14 +17,18 @@ package publish
 import (
 	"context"
 	"fmt"
+	"io"
 	"log"
-	"os"
+	"runtime"
+	"slices"
 	"strings"
+	"sync"
 
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/daemon"
 	"github.com/google/ko/pkg/build"
+	"github.com/moby/moby/client"
 )
 
 const (
@@ -35,10 +39,23 @@ const (
 // demon is intentionally misspelled to avoid name collision (and drive Jon nuts).
 // [Narrator: Jon wasn't the only one driven nuts.]
 type demon struct {
-	base   string
-	client daemon.Client
-	namer  Namer
-	tags   []string
+	base     string
+	client   daemon.Client
+	namer    Namer
+	tags     []string
+	platform *v1.Platform
+
+	// What to load from multi-platform images, found on first use.
+	once      sync.Once
+	loadIndex bool
+	targetErr error
+}
+
+// daemonInfo is the part of the docker client that tells what the daemon
+// can load.
+type daemonInfo interface {
+	ServerVersion(context.Context, client.ServerVersionOptions) (client.ServerVersionResult, error)
+	Info(context.Context, client.InfoOptions) (client.SystemInfoResult, error)
 }
 
 // DaemonOption is a functional option for NewDaemon.
@@ -64,6 +81,15 @@ func WithDockerClient(client daemon.Client) DaemonOption {
 	}
 }
 
+// WithLocalPlatform is a functional option for the platform of the image to
+// load from multi-platform images, instead of the daemon's own.
+func WithLocalPlatform(p *v1.Platform) DaemonOption {
+	return func(i *demon) error {
+		i.platform = p
+		return nil
+	}
+}
+
 // NewDaemon returns a new publish.Interface that publishes images to a container daemon.
 func NewDaemon(namer Namer, tags []string, opts ...DaemonOption) (Interface, error) {
 	d := &demon{
@@ -76,9 +102,68 @@ func NewDaemon(namer Namer, tags []string, opts ...DaemonOption) (Interface, err
 			return nil, err
 		}
 	}
+	if d.client == nil {
+		c, err := client.New(client.FromEnv)
+		if err != nil {
+			return nil, err
+		}
+		d.client = c
+	}
 	return d, nil
 }
 
+// loadTarget works out what to load from multi-platform images: the whole
+// index if the daemon uses the containerd image store, which can hold them,
+// or else the image for the daemon's platform.
+func (d *demon) loadTarget(ctx context.Context) (bool, error) {
+	d.once.Do(func() {
+		if d.platform != nil {
+			return
+		}
+		info, ok := d.client.(daemonInfo)
+		if !ok {
+			d.platform = &v1.Platform{OS: "linux", Architecture: runtime.GOARCH}
+			return
+		}
+		_, _ = d.client.Ping(ctx, client.PingOptions{NegotiateAPIVersion: true})
+		v, err := info.ServerVersion(ctx, client.ServerVersionOptions{})
+		if err != nil {
+			d.targetErr = fmt.Errorf("getting the platform of the docker daemon: %w", err)
+			return
+		}
+		d.platform = &v1.Platform{OS: v.Os, Architecture: v.Arch}
+		i, err := info.Info(ctx, client.InfoOptions{})
+		if err != nil {
+			d.targetErr = fmt.Errorf("getting the image store of the docker daemon: %w", err)
+			return
+		}
+		d.loadIndex = slices.Contains(i.Info.DriverStatus, [2]string{"driver-type", "io.containerd.snapshotter.v1"})
+	})
+	return d.loadIndex, d.targetErr
+}
+
+// writeIndex loads all of idx into the daemon, with each of the tags.
+func (d *demon) writeIndex(ctx context.Context, idx v1.ImageIndex, tags ...name.Tag) error {
+	refs := make(map[name.Reference]build.Result, len(tags))
+	for _, tag := range tags {
+		refs[tag] = idx
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		_ = pw.CloseWithError(writeOCIArchive(pw, refs))
+	}()
+	resp, err := d.client.ImageLoad(ctx, pr, client.ImageLoadWithQuiet(false))
+	if err != nil {
+		pr.CloseWithError(err)
+		return fmt.Errorf("error loading image: %w", err)
+	}
+	defer resp.Close()
+	if _, err := io.Copy(io.Discard, resp); err != nil {
+		return fmt.Errorf("error reading load response body: %w", err)
+	}
+	return nil
+}
+
 func (d *demon) getOpts(ctx context.Context) []daemon.Option {
 	return []daemon.Option{
 		daemon.WithContext(ctx),
@@ -92,46 +177,35 @@ func (d *demon) Publish(ctx context.Context, br build.Result, s string) (name.Re
 	// https://github.com/google/go-containerregistry/issues/212
 	s = strings.ToLower(s)
 
-	// There's no way to write an index to a kind, so attempt to downcast it to an image.
+	// Daemons without the containerd image store can't hold an index, so
+	// load the image for the daemon's platform.
 	var img v1.Image
 	switch i := br.(type) {
 	case v1.Image:
 		img = i
 	case v1.ImageIndex:
-		im, err := i.IndexManifest()
+		loadIndex, err := d.loadTarget(ctx)
 		if err != nil {
 			return nil, err
 		}
-		goos, goarch := os.Getenv("GOOS"), os.Getenv("GOARCH")
-		if goos == "" {
-			goos = "linux"
+		if loadIndex {
+			return d.publishIndex(ctx, i, s)
 		}
-		if goarch == "" {
-			goarch = "amd64"
-		}
-		for _, manifest := range im.Manifests {
-			if manifest.Platform == nil {
-				continue
-			}
-			if manifest.Platform.OS != goos {
-				continue
-			}
-			if manifest.Platform.Architecture != goarch {
-				continue
-			}
-			img, err = i.Image(manifest.Digest)
-			if err != nil {
-				return nil, err
-			}
-			break
-		}
-		if img == nil {
-			return nil, fmt.Errorf("failed to find %s/%s image in index for image: %v", goos, goarch, s)
+		img, err = imageForPlatform(i, d.platform)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", s, err)
 		}
 	default:
 		return nil, fmt.Errorf("failed to interpret %s result as image: %v", s, br)
 	}
 
+	// The daemon can't load zstd layers.
+	img, err := gzipLayers(img)
+	if err != nil {
+		return nil, err
+	}
+
+	// The digest is that of the image that is loaded.
 	h, err := img.Digest()
 	if err != nil {
 		return nil, err
@@ -165,6 +239,34 @@ func (d *demon) Publish(ctx context.Context, br build.Result, s string) (name.Re
 	return &digestTag, nil
 }
 
+// publishIndex loads the whole of idx into the daemon, tagged with its
+// digest and each of the tags.
+func (d *demon) publishIndex(ctx context.Context, idx v1.ImageIndex, s string) (name.Reference, error) {
+	h, err := idx.Digest()
+	if err != nil {
+		return nil, err
+	}
+	digestTag, err := name.NewTag(fmt.Sprintf("%s:%s", d.namer(d.base, s), h.Hex))
+	if err != nil {
+		return nil, err
+	}
+	tags := []name.Tag{digestTag}
+	for _, tagName := range d.tags {
+		tag, err := name.NewTag(fmt.Sprintf("%s:%s", d.namer(d.base, s), tagName))
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+
+	log.Printf("Loading %v with all its platforms", digestTag)
+	if err := d.writeIndex(ctx, idx, tags...); err != nil {
+		return nil, err
+	}
+	log.Printf("Loaded %v", digestTag)
+	return &digestTag, nil
+}
+
 func (d *demon) Close() error {
 	return nil
 }
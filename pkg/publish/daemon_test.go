@@ -15,14 +15,23 @@
 package publish_test
 
 import (
+	"archive/tar"
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"strings"
 	"testing"
 
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
 	"github.com/google/go-containerregistry/pkg/v1/random"
 	kotesting "github.com/google/ko/pkg/internal/testing"
 	"github.com/google/ko/pkg/publish"
+	"github.com/moby/moby/api/types/system"
+	"github.com/moby/moby/client"
 )
 
 func TestDaemon(t *testing.T) {
@@ -98,3 +107,142 @@ func TestDaemonDomain(t *testing.T) {
 		t.Errorf("Publish() = %v, wanted prefix %v", got, want)
 	}
 }
+
+// infoDaemon is a daemon that says what platform and image store it has, and
+// records the archives loaded into it.
+type infoDaemon struct {
+	kotesting.MockDaemon
+	arch       string
+	containerd bool
+	loaded     [][]byte
+}
+
+func (d *infoDaemon) ServerVersion(context.Context, client.ServerVersionOptions) (client.ServerVersionResult, error) {
+	return client.ServerVersionResult{Os: "linux", Arch: d.arch}, nil
+}
+
+func (d *infoDaemon) Info(context.Context, client.InfoOptions) (client.SystemInfoResult, error) {
+	var i system.Info
+	if d.containerd {
+		i.DriverStatus = [][2]string{{"driver-type", "io.containerd.snapshotter.v1"}}
+	}
+	return client.SystemInfoResult{Info: i}, nil
+}
+
+func (d *infoDaemon) ImageLoad(ctx context.Context, r io.Reader, opts ...client.ImageLoadOption) (client.ImageLoadResult, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	d.loaded = append(d.loaded, b)
+	return d.MockDaemon.ImageLoad(ctx, r, opts...)
+}
+
+func platformIndex(t *testing.T) (v1.ImageIndex, map[string]v1.Hash) {
+	t.Helper()
+	idx := v1.ImageIndex(empty.Index)
+	digests := map[string]v1.Hash{}
+	for _, p := range []string{"linux/amd64", "linux/arm64", "linux/arm/v7"} {
+		img, err := random.Image(1024, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		platform, err := v1.ParsePlatform(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		idx = mutate.AppendManifests(idx, mutate.IndexAddendum{
+			Add:        img,
+			Descriptor: v1.Descriptor{Platform: platform},
+		})
+		if digests[p], err = img.Digest(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return idx, digests
+}
+
+func TestDaemonPlatform(t *testing.T) {
+	importpath := "github.com/google/ko"
+	idx, digests := platformIndex(t)
+	idxDigest, err := idx.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	armv7, err := v1.ParsePlatform("linux/arm/v7")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		desc   string
+		client *infoDaemon
+		opts   []publish.DaemonOption
+		want   v1.Hash
+	}{{
+		desc:   "daemon platform",
+		client: &infoDaemon{arch: "arm64"},
+		want:   digests["linux/arm64"],
+	}, {
+		desc:   "override",
+		client: &infoDaemon{arch: "arm64"},
+		opts:   []publish.DaemonOption{publish.WithLocalPlatform(armv7)},
+		want:   digests["linux/arm/v7"],
+	}, {
+		desc:   "containerd image store",
+		client: &infoDaemon{arch: "arm64", containerd: true},
+		want:   idxDigest,
+	}} {
+		t.Run(c.desc, func(t *testing.T) {
+			def, err := publish.NewDaemon(md5Hash, []string{"latest"}, append(c.opts, publish.WithDockerClient(c.client))...)
+			if err != nil {
+				t.Fatalf("NewDaemon() = %v", err)
+			}
+			ref, err := def.Publish(context.Background(), idx, importpath)
+			if err != nil {
+				t.Fatalf("Publish() = %v", err)
+			}
+			if want := md5Hash("ko.local", importpath) + ":" + c.want.Hex; ref.String() != want {
+				t.Errorf("Publish() = %v, wanted %v", ref, want)
+			}
+			if !c.client.containerd {
+				return
+			}
+
+			// The whole index is loaded as an OCI archive with all its
+			// names.
+			if len(c.client.loaded) != 1 {
+				t.Fatalf("loaded %d archives, wanted 1", len(c.client.loaded))
+			}
+			if names := ociArchiveNames(t, c.client.loaded[0]); len(names) != 2 {
+				t.Errorf("archive names = %v, wanted the digest and latest", names)
+			}
+		})
+	}
+}
+
+// ociArchiveNames returns the names in the index.json of an OCI archive.
+func ociArchiveNames(t *testing.T, b []byte) []string {
+	t.Helper()
+	tr := tar.NewReader(bytes.NewReader(b))
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Name != "index.json" {
+			continue
+		}
+		var m v1.IndexManifest
+		if err := json.NewDecoder(tr).Decode(&m); err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, desc := range m.Manifests {
+			names = append(names, desc.Annotations["org.opencontainers.image.ref.name"])
+		}
+		return names
+	}
+}
@@ -22,12 +22,14 @@ import (
 	"net/http"
 	"path"
 	"runtime"
+	"slices"
 	"strings"
 
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
 	"github.com/google/go-containerregistry/pkg/v1/types"
 	"github.com/sigstore/cosign/v3/pkg/oci"
 	ociremote "github.com/sigstore/cosign/v3/pkg/oci/remote"
@@ -46,7 +48,10 @@ type defalt struct {
 	insecure bool
 	jobs     int
 
+	immutableTags bool
+
 	pusher *remote.Pusher
+	ropt   []remote.Option
 	oopt   []ociremote.Option
 }
 
@@ -64,6 +69,8 @@ type defaultOpener struct {
 	insecure  bool
 	ropt      []remote.Option
 	jobs      int
+
+	immutableTags bool
 }
 
 // Namer is a function from a supported import path to the portion of the resulting
@@ -114,8 +121,12 @@ func (do *defaultOpener) Open() (Interface, error) {
 		tagOnly:  do.tagOnly,
 		insecure: do.insecure,
 		jobs:     do.jobs,
-		pusher:   pusher,
-		oopt:     oopt,
+
+		immutableTags: do.immutableTags,
+
+		pusher: pusher,
+		ropt:   do.ropt,
+		oopt:   oopt,
 	}, nil
 }
 
@@ -233,23 +244,48 @@ func (d *defalt) Publish(ctx context.Context, br build.Result, s string) (name.R
 		no = append(no, name.Insecure)
 	}
 
-	g, ctx := errgroup.WithContext(ctx)
-	g.SetLimit(d.jobs)
-	for i, tagName := range d.tags {
+	h, err := br.Digest()
+	if err != nil {
+		return nil, err
+	}
+	repo, err := name.NewRepository(d.namer(d.base, s), no...)
+	if err != nil {
+		return nil, err
+	}
+	tags := make([]name.Tag, 0, len(d.tags))
+	for _, tagName := range d.tags {
 		tag, err := name.NewTag(fmt.Sprintf("%s:%s", d.namer(d.base, s), tagName), no...)
 		if err != nil {
 			return nil, err
 		}
+		tags = append(tags, tag)
+	}
+
+	// Don't rewrite what is already in the registry.
+	sboms, err := d.sbomTags(ctx, repo, br)
+	if err != nil {
+		return nil, err
+	}
+	published, pending, err := d.preflight(ctx, repo.Digest(h.String()), sboms, tags)
+	if err != nil {
+		return nil, err
+	}
+	if published && len(pending) == 0 {
+		log.Printf("Skipping %v, already published", repo.Digest(h.String()))
+	}
 
-		if i == 0 {
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(d.jobs)
+	for i, tag := range pending {
+		if i == 0 && !published {
 			log.Printf("Publishing %v", tag)
 			g.Go(func() error {
-				return d.pushResult(ctx, tag, br)
+				return d.pushResult(gctx, tag, br)
 			})
 		} else {
 			g.Go(func() error {
 				log.Printf("Tagging %v", tag)
-				return d.pusher.Push(ctx, tag, br)
+				return d.pusher.Push(gctx, tag, br)
 			})
 		}
 	}
@@ -262,10 +298,6 @@ func (d *defalt) Publish(ctx context.Context, br build.Result, s string) (name.R
 		return name.NewTag(fmt.Sprintf("%s:%s", d.namer(d.base, s), d.tags[0]))
 	}
 
-	h, err := br.Digest()
-	if err != nil {
-		return nil, err
-	}
 	ref := fmt.Sprintf("%s@%s", d.namer(d.base, s), h)
 	if len(d.tags) == 1 && d.tags[0] != latestTag {
 		// If a single tag is explicitly set (not latest), then this
@@ -280,6 +312,119 @@ func (d *defalt) Publish(ctx context.Context, br build.Result, s string) (name.R
 	return &dig, nil
 }
 
+// sbomTags returns the tags that the SBOMs of br are pushed to, for each
+// image or index in br that has one.
+func (d *defalt) sbomTags(ctx context.Context, repo name.Repository, br build.Result) ([]name.Tag, error) {
+	var sboms []name.Tag
+	add := func(_ context.Context, se oci.SignedEntity) error {
+		if _, err := se.Attachment("sbom"); err != nil {
+			return nil
+		}
+		h, err := se.(interface{ Digest() (v1.Hash, error) }).Digest()
+		if err != nil {
+			return err
+		}
+		ref, err := ociremote.SBOMTag(repo.Digest(h.String()), d.oopt...)
+		if err != nil {
+			return err
+		}
+		sboms = append(sboms, ref)
+		return nil
+	}
+	switch br := br.(type) {
+	case oci.SignedImageIndex:
+		if err := walk.SignedEntity(ctx, br, add); err != nil {
+			return nil, err
+		}
+	case oci.SignedImage:
+		if err := add(ctx, br); err != nil {
+			return nil, err
+		}
+	}
+	return sboms, nil
+}
+
+// preflight looks up the digest, its SBOM tags and the tags in the registry,
+// and returns whether the digest is already there, with its SBOMs, and the
+// tags that don't point to it yet.  With immutable tags, tags that point
+// elsewhere are an error.
+func (d *defalt) preflight(ctx context.Context, digest name.Digest, sboms, tags []name.Tag) (bool, []name.Tag, error) {
+	h, err := v1.NewHash(digest.DigestStr())
+	if err != nil {
+		return false, nil, err
+	}
+	var published bool
+	current := make([]*v1.Hash, len(tags))
+	sbomsFound := make([]bool, len(sboms))
+
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(d.jobs)
+	g.Go(func() error {
+		existing, err := d.head(ctx, digest)
+		published = existing != nil
+		return err
+	})
+	for i, sbom := range sboms {
+		g.Go(func() error {
+			existing, err := d.head(ctx, sbom)
+			sbomsFound[i] = existing != nil
+			return err
+		})
+	}
+	for i, tag := range tags {
+		g.Go(func() error {
+			var err error
+			current[i], err = d.head(ctx, tag)
+			return err
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return false, nil, err
+	}
+	if slices.Contains(sbomsFound, false) {
+		published = false
+	}
+
+	var pending []name.Tag
+	for i, tag := range tags {
+		switch {
+		case current[i] == nil:
+			pending = append(pending, tag)
+		case *current[i] == h:
+			// Already published.
+		case d.immutableTags:
+			return false, nil, fmt.Errorf("%v already points to %v, and tags are immutable", tag, current[i])
+		default:
+			pending = append(pending, tag)
+		}
+	}
+	// SBOMs are pushed along with the image, so an image that is missing
+	// some is pushed to its first tag again, even if its tags all point to
+	// it already.
+	if !published && len(pending) == 0 && len(tags) != 0 {
+		pending = tags[:1]
+	}
+	return published, pending, nil
+}
+
+// head returns the digest that ref points to in the registry, or nil if it
+// doesn't exist.  If the registry can't say, ref is assumed not to exist,
+// unless tags are immutable.
+func (d *defalt) head(ctx context.Context, ref name.Reference) (*v1.Hash, error) {
+	desc, err := remote.Head(ref, append(d.ropt, remote.WithContext(ctx))...)
+	if err != nil {
+		var terr *transport.Error
+		if errors.As(err, &terr) && terr.StatusCode == http.StatusNotFound {
+			return nil, nil
+		}
+		if d.immutableTags {
+			return nil, fmt.Errorf("checking %v: %w", ref, err)
+		}
+		return nil, nil
+	}
+	return &desc.Digest, nil
+}
+
 func (d *defalt) Close() error {
 	return nil
 }
@@ -19,10 +19,13 @@ import (
 	"crypto/md5"
 	"encoding/hex"
 	"fmt"
+	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/google/go-containerregistry/pkg/crane"
@@ -239,3 +242,112 @@ func TestDefaultWithReleaseTag(t *testing.T) {
 		t.Errorf("Publish() = %v, wanted no digest", d.String())
 	}
 }
+
+func TestDefaultSkipsExisting(t *testing.T) {
+	var mu sync.Mutex
+	var writes []string
+	reg := registry.New()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			mu.Lock()
+			writes = append(writes, r.Method+" "+r.URL.Path)
+			mu.Unlock()
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("url.Parse(%v) = %v", server.URL, err)
+	}
+	repoName := fmt.Sprintf("%s/blah", u.Host)
+	importpath := "github.com/google/ko/cmd/app"
+	tags := []string{"latest", "v1.2.3"}
+
+	publishWith := func(br build.Result, opts ...publish.Option) error {
+		t.Helper()
+		def, err := publish.NewDefault(repoName, append(opts, publish.WithTags(tags))...)
+		if err != nil {
+			t.Fatalf("NewDefault() = %v", err)
+		}
+		_, err = def.Publish(context.Background(), br, importpath)
+		return err
+	}
+
+	if err := publishWith(img); err != nil {
+		t.Fatalf("Publish() = %v", err)
+	}
+	if len(writes) == 0 {
+		t.Fatal("Publish() wrote nothing")
+	}
+
+	// Publishing the same image again writes nothing.
+	writes = nil
+	if err := publishWith(img); err != nil {
+		t.Fatalf("Publish() = %v", err)
+	}
+	if len(writes) != 0 {
+		t.Errorf("Publish() of an existing image wrote %v", writes)
+	}
+
+	// Only a tag that points elsewhere is moved.
+	other, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	latest := fmt.Sprintf("%s/%s:latest", repoName, importpath)
+	if err := crane.Push(other, latest); err != nil {
+		t.Fatal(err)
+	}
+	writes = nil
+	if err := publishWith(img); err != nil {
+		t.Fatalf("Publish() = %v", err)
+	}
+	if len(writes) != 1 || !strings.HasSuffix(writes[0], "/manifests/latest") {
+		t.Errorf("Publish() wrote %v, wanted only the latest tag", writes)
+	}
+
+	// Immutable tags are not moved.
+	if err := crane.Push(other, latest); err != nil {
+		t.Fatal(err)
+	}
+	writes = nil
+	if err := publishWith(img, publish.WithImmutableTags(true)); err == nil {
+		t.Error("Publish() = nil, wanted an error for an immutable tag")
+	}
+	if len(writes) != 0 {
+		t.Errorf("Publish() with an immutable tag wrote %v", writes)
+	}
+	if err := publishWith(other, publish.WithImmutableTags(true)); err == nil {
+		t.Error("Publish() = nil, wanted an error for the immutable v1.2.3 tag")
+	}
+
+	// An image already there without its SBOM is published again with it.
+	f, err := static.NewFile([]byte("da bom"))
+	if err != nil {
+		t.Fatalf("static.NewFile() = %v", err)
+	}
+	si, err := ocimutate.AttachFileToImage(signed.Image(img), "sbom", f)
+	if err != nil {
+		t.Fatalf("ocimutate.AttachFileToImage() = %v", err)
+	}
+	writes = nil
+	if err := publishWith(si); err != nil {
+		t.Fatalf("Publish() = %v", err)
+	}
+	h, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sbomTag := fmt.Sprintf("/manifests/%s-%s.sbom", h.Algorithm, h.Hex)
+	if !slices.ContainsFunc(writes, func(w string) bool { return strings.HasSuffix(w, sbomTag) }) {
+		t.Errorf("Publish() wrote %v, wanted the SBOM", writes)
+	}
+	writes = nil
+	if err := publishWith(si); err != nil {
+		t.Fatalf("Publish() = %v", err)
+	}
+	if len(writes) != 0 {
+		t.Errorf("Publish() of an existing image and SBOM wrote %v", writes)
+	}
+}
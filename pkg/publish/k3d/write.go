@@ -0,0 +1,194 @@
+// Copyright 2026 ko Build Authors All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k3d
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/google/ko/pkg/internal/uname"
+
+	"sigs.k8s.io/kind/pkg/exec"
+)
+
+const (
+	clusterNameEnvKey = "K3D_CLUSTER_NAME"
+
+	// The cluster that k3d creates when it is not given a name.
+	defaultClusterName = "k3s-default"
+
+	// The labels that k3d puts on the containers of its nodes.
+	clusterLabel = "k3d.cluster"
+	roleLabel    = "k3d.role"
+)
+
+// node is a k3d node that can run commands.
+type node interface {
+	CommandContext(ctx context.Context, cmd string, args ...string) exec.Cmd
+	String() string
+}
+
+// provider is an interface for k3d providers to facilitate testing.
+type provider interface {
+	ListNodes(name string) ([]node, error)
+}
+
+// GetProvider is a variable so we can override in tests.
+var GetProvider = func() provider {
+	return dockerProvider{}
+}
+
+// dockerProvider finds the nodes of k3d clusters among docker containers.
+type dockerProvider struct{}
+
+func (dockerProvider) ListNodes(name string) ([]node, error) {
+	lines, err := exec.OutputLines(exec.Command("docker", "ps",
+		"--filter", fmt.Sprintf("label=%s=%s", clusterLabel, name),
+		"--format", fmt.Sprintf(`{{.Names}}\t{{.Label %q}}`, roleLabel),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes of k3d cluster %q: %w", name, err)
+	}
+	return parseNodes(lines), nil
+}
+
+// parseNodes returns the nodes among the lines of container names and k3d
+// roles that docker ps prints.
+func parseNodes(lines []string) []node {
+	var nodes []node
+	for _, line := range lines {
+		container, role, _ := strings.Cut(line, "\t")
+		// The load balancer and registry containers don't run images.
+		if role == "server" || role == "agent" {
+			nodes = append(nodes, containerNode(container))
+		}
+	}
+	return nodes
+}
+
+// containerNode is a k3d node that runs commands with docker exec.
+type containerNode string
+
+func (n containerNode) CommandContext(ctx context.Context, cmd string, args ...string) exec.Cmd {
+	return exec.CommandContext(ctx, "docker", append([]string{"exec", "-i", string(n), cmd}, args...)...)
+}
+
+func (n containerNode) String() string {
+	return string(n)
+}
+
+// Tag adds a tag to an already existent image.
+func Tag(ctx context.Context, src, dest name.Tag) error {
+	return onEachNode(func(n node) error {
+		var buf bytes.Buffer
+		cmd := n.CommandContext(ctx, "ctr", "--namespace=k8s.io", "images", "tag", "--force", src.String(), dest.String())
+		cmd.SetStdout(&buf)
+		cmd.SetStderr(&buf)
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("failed to tag image: %w\n%s", err, buf.String())
+		}
+		return nil
+	})
+}
+
+// Write saves the image into the k3d nodes as the given tag.
+func Write(ctx context.Context, tag name.Tag, img v1.Image) error {
+	return onEachNode(func(n node) error {
+		pr, pw := io.Pipe()
+
+		grp := errgroup.Group{}
+		grp.Go(func() error {
+			return pw.CloseWithError(tarball.Write(tag, img, pw))
+		})
+
+		var buf bytes.Buffer
+		cmd := n.CommandContext(ctx, "ctr", "--namespace=k8s.io", "images", "import", "--all-platforms", "-").SetStdin(pr)
+		cmd.SetStdout(&buf)
+		cmd.SetStderr(&buf)
+		if err := cmd.Run(); err != nil {
+			pr.CloseWithError(err)
+			return fmt.Errorf("failed to load image to node %q: %w\n%s", n, err, buf.String())
+		}
+
+		if err := grp.Wait(); err != nil {
+			return fmt.Errorf("failed to write intermediate tarball representation: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// Platform returns the platform of the k3d nodes, which run Linux on the
+// architecture of the machine that runs them.
+func Platform(ctx context.Context) (*v1.Platform, error) {
+	nodeList, err := getNodes()
+	if err != nil {
+		return nil, err
+	}
+	n := nodeList[0]
+	var stdout, stderr bytes.Buffer
+	cmd := n.CommandContext(ctx, "uname", "-m")
+	cmd.SetStdout(&stdout)
+	cmd.SetStderr(&stderr)
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to get the architecture of node %q: %w\n%s", n, err, stderr.String())
+	}
+	return uname.Platform(strings.TrimSpace(stdout.String()))
+}
+
+// onEachNode executes the given function on each node. Exits on first error.
+func onEachNode(f func(node) error) error {
+	nodeList, err := getNodes()
+	if err != nil {
+		return err
+	}
+
+	for _, n := range nodeList {
+		if err := f(n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getNodes gets all the nodes of the cluster in $K3D_CLUSTER_NAME, or of
+// the default cluster.  Returns an error if none were found.
+func getNodes() ([]node, error) {
+	provider := GetProvider()
+
+	clusterName := os.Getenv(clusterNameEnvKey)
+	if clusterName == "" {
+		clusterName = defaultClusterName
+	}
+
+	nodeList, err := provider.ListNodes(clusterName)
+	if err != nil {
+		return nil, err
+	}
+	if len(nodeList) == 0 {
+		return nil, fmt.Errorf("no nodes found for k3d cluster %q", clusterName)
+	}
+
+	return nodeList, nil
+}
@@ -0,0 +1,242 @@
+// Copyright 2026 ko Build Authors All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k3d
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"sigs.k8s.io/kind/pkg/exec"
+)
+
+func TestWrite(t *testing.T) {
+	ctx := context.Background()
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatalf("random.Image() = %v", err)
+	}
+
+	tag, err := name.NewTag("k3d.local/test:new")
+	if err != nil {
+		t.Fatalf("name.NewTag() = %v", err)
+	}
+
+	n1 := &fakeNode{}
+	n2 := &fakeNode{}
+	p := &fakeProvider{nodes: []node{n1, n2}}
+	GetProvider = func() provider { return p }
+
+	if err := Write(ctx, tag, img); err != nil {
+		t.Fatalf("Write() = %v", err)
+	}
+	if got, want := p.cluster, "k3s-default"; got != want {
+		t.Errorf("cluster = %q, want %q", got, want)
+	}
+
+	// Verify the respective command is executed on each node.
+	for _, n := range []*fakeNode{n1, n2} {
+		if got, want := len(n.cmds), 1; got != want {
+			t.Fatalf("len(n.cmds) = %d, want %d", got, want)
+		}
+		if got, want := n.cmds[0].cmd, "ctr --namespace=k8s.io images import --all-platforms -"; got != want {
+			t.Fatalf("c.cmd = %s, want %s", got, want)
+		}
+	}
+}
+
+func TestTag(t *testing.T) {
+	ctx := context.Background()
+	oldTag, err := name.NewTag("k3d.local/test:test")
+	if err != nil {
+		t.Fatalf("name.NewTag() = %v", err)
+	}
+
+	newTag, err := name.NewTag("k3d.local/test:new")
+	if err != nil {
+		t.Fatalf("name.NewTag() = %v", err)
+	}
+
+	t.Setenv("K3D_CLUSTER_NAME", "other")
+	n1 := &fakeNode{}
+	n2 := &fakeNode{}
+	p := &fakeProvider{nodes: []node{n1, n2}}
+	GetProvider = func() provider { return p }
+
+	if err := Tag(ctx, oldTag, newTag); err != nil {
+		t.Fatalf("Tag() = %v", err)
+	}
+	if got, want := p.cluster, "other"; got != want {
+		t.Errorf("cluster = %q, want %q", got, want)
+	}
+
+	// Verify the respective command is executed on each node.
+	for _, n := range []*fakeNode{n1, n2} {
+		if got, want := len(n.cmds), 1; got != want {
+			t.Fatalf("len(n.cmds) = %d, want %d", got, want)
+		}
+		if got, want := n.cmds[0].cmd, fmt.Sprintf("ctr --namespace=k8s.io images tag --force %s %s", oldTag, newTag); got != want {
+			t.Fatalf("c.cmd = %s, want %s", got, want)
+		}
+	}
+}
+
+func TestPlatform(t *testing.T) {
+	n1 := &fakeNode{out: "x86_64\n"}
+	n2 := &fakeNode{out: "x86_64\n"}
+	GetProvider = func() provider {
+		return &fakeProvider{nodes: []node{n1, n2}}
+	}
+
+	p, err := Platform(context.Background())
+	if err != nil {
+		t.Fatalf("Platform() = %v", err)
+	}
+	if want := (&v1.Platform{OS: "linux", Architecture: "amd64"}); !p.Equals(*want) {
+		t.Errorf("Platform() = %v, wanted %v", p, want)
+	}
+}
+
+func TestParseNodes(t *testing.T) {
+	got := parseNodes([]string{
+		"k3d-dev-serverlb\tloadbalancer",
+		"k3d-dev-agent-0\tagent",
+		"k3d-dev-server-0\tserver",
+		"k3d-dev-registry\tregistry",
+	})
+	want := []node{containerNode("k3d-dev-agent-0"), containerNode("k3d-dev-server-0")}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("parseNodes() (-want +got): %s", diff)
+	}
+}
+
+func TestFailWithNoNodes(t *testing.T) {
+	ctx := context.Background()
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatalf("random.Image() = %v", err)
+	}
+
+	tag, err := name.NewTag("k3d.local/test:new")
+	if err != nil {
+		t.Fatalf("name.NewTag() = %v", err)
+	}
+
+	GetProvider = func() provider {
+		return &fakeProvider{}
+	}
+
+	if err := Write(ctx, tag, img); err == nil {
+		t.Fatal("Write() = nil, wanted an error")
+	}
+	if err := Tag(ctx, tag, tag); err == nil {
+		t.Fatal("Tag() = nil, wanted an error")
+	}
+}
+
+func TestFailCommands(t *testing.T) {
+	ctx := context.Background()
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatalf("random.Image() = %v", err)
+	}
+
+	tag, err := name.NewTag("k3d.local/test:new")
+	if err != nil {
+		t.Fatalf("name.NewTag() = %v", err)
+	}
+
+	errTest := errors.New("test")
+	GetProvider = func() provider {
+		return &fakeProvider{nodes: []node{&fakeNode{err: errTest}}}
+	}
+
+	if err := Write(ctx, tag, img); !errors.Is(err, errTest) {
+		t.Fatalf("Write() = %v, want %v", err, errTest)
+	}
+	if err := Tag(ctx, tag, tag); !errors.Is(err, errTest) {
+		t.Fatalf("Tag() = %v, want %v", err, errTest)
+	}
+}
+
+type fakeProvider struct {
+	nodes   []node
+	cluster string
+}
+
+func (f *fakeProvider) ListNodes(name string) ([]node, error) {
+	f.cluster = name
+	return f.nodes, nil
+}
+
+type fakeNode struct {
+	cmds []*fakeCmd
+	out  string
+	err  error
+}
+
+func (f *fakeNode) CommandContext(_ context.Context, cmd string, args ...string) exec.Cmd {
+	command := &fakeCmd{
+		cmd: strings.Join(append([]string{cmd}, args...), " "),
+		out: f.out,
+		err: f.err,
+	}
+	f.cmds = append(f.cmds, command)
+	return command
+}
+
+func (f *fakeNode) String() string {
+	return "test"
+}
+
+type fakeCmd struct {
+	cmd    string
+	out    string
+	err    error
+	stdin  io.Reader
+	stdout io.Writer
+}
+
+func (f *fakeCmd) Run() error {
+	if f.stdin != nil {
+		// Consume the entire stdin to move the image publish forward.
+		io.ReadAll(f.stdin)
+	}
+	if f.stdout != nil {
+		io.WriteString(f.stdout, f.out)
+	}
+	return f.err
+}
+
+func (f *fakeCmd) SetStdin(stdin io.Reader) exec.Cmd {
+	f.stdin = stdin
+	return f
+}
+
+func (f *fakeCmd) SetStdout(stdout io.Writer) exec.Cmd {
+	f.stdout = stdout
+	return f
+}
+
+// The following functions are not used by our code at all.
+func (f *fakeCmd) SetEnv(...string) exec.Cmd    { return f }
+func (f *fakeCmd) SetStderr(io.Writer) exec.Cmd { return f }
@@ -18,8 +18,8 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"os"
 	"strings"
+	"sync"
 
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
@@ -32,19 +32,61 @@ const (
 	KindDomain = "kind.local"
 )
 
+// kindPublisher loads images into the nodes of a local cluster: kind, k3d or
+// minikube.
 type kindPublisher struct {
-	base  string
-	namer Namer
-	tags  []string
+	base     string
+	namer    Namer
+	tags     []string
+	platform *v1.Platform
+
+	// How to reach the nodes of the cluster.
+	write         func(context.Context, name.Tag, v1.Image) error
+	tag           func(ctx context.Context, src, dest name.Tag) error
+	nodesPlatform func(context.Context) (*v1.Platform, error)
+
+	// The platform of the nodes, found on first use.
+	once        sync.Once
+	platformErr error
+}
+
+// KindOption is a functional option for NewKindPublisher, NewK3dPublisher
+// and NewMinikubePublisher.
+type KindOption func(*kindPublisher)
+
+// WithKindPlatform is a functional option for the platform of the image to
+// load from multi-platform images, instead of the nodes' own.
+func WithKindPlatform(p *v1.Platform) KindOption {
+	return func(t *kindPublisher) {
+		t.platform = p
+	}
 }
 
 // NewKindPublisher returns a new publish.Interface that loads images into kind nodes.
-func NewKindPublisher(base string, namer Namer, tags []string) Interface {
-	return &kindPublisher{
-		base:  base,
-		namer: namer,
-		tags:  tags,
+func NewKindPublisher(base string, namer Namer, tags []string, opts ...KindOption) Interface {
+	t := &kindPublisher{
+		base:          base,
+		namer:         namer,
+		tags:          tags,
+		write:         kind.Write,
+		tag:           kind.Tag,
+		nodesPlatform: kind.Platform,
+	}
+	for _, option := range opts {
+		option(t)
 	}
+	return t
+}
+
+// nodePlatform returns the platform of the image to load from multi-platform
+// images.
+func (t *kindPublisher) nodePlatform(ctx context.Context) (*v1.Platform, error) {
+	t.once.Do(func() {
+		if t.platform == nil {
+			t.platform, t.platformErr = t.nodesPlatform(ctx)
+		}
+	})
+	return t.platform, t.platformErr
 }
 
 // Publish implements publish.Interface.
@@ -53,46 +95,33 @@ func (t *kindPublisher) Publish(ctx context.Context, br build.Result, s string)
 	// https://github.com/google/go-containerregistry/issues/212
 	s = strings.ToLower(s)
 
-	// There's no way to write an index to a kind, so attempt to downcast it to an image.
+	// There's no way to write an index to the nodes, so load the image for
+	// their platform.
 	var img v1.Image
 	switch i := br.(type) {
 	case v1.Image:
 		img = i
 	case v1.ImageIndex:
-		im, err := i.IndexManifest()
+		p, err := t.nodePlatform(ctx)
 		if err != nil {
 			return nil, err
 		}
-		goos, goarch := os.Getenv("GOOS"), os.Getenv("GOARCH")
-		if goos == "" {
-			goos = "linux"
-		}
-		if goarch == "" {
-			goarch = "amd64"
-		}
-		for _, manifest := range im.Manifests {
-			if manifest.Platform == nil {
-				continue
-			}
-			if manifest.Platform.OS != goos {
-				continue
-			}
-			if manifest.Platform.Architecture != goarch {
-				continue
-			}
-			img, err = i.Image(manifest.Digest)
-			if err != nil {
-				return nil, err
-			}
-			break
-		}
-		if img == nil {
-			return nil, fmt.Errorf("failed to find %s/%s image in index for image: %v", goos, goarch, s)
+		img, err = imageForPlatform(i, p)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", s, err)
 		}
 	default:
 		return nil, fmt.Errorf("failed to interpret %s result as image: %v", s, br)
 	}
 
+	// Images are loaded as Docker image tarballs, which can't hold zstd
+	// layers.
+	img, err := gzipLayers(img)
+	if err != nil {
+		return nil, err
+	}
+
+	// The digest is that of the image that is loaded.
 	h, err := img.Digest()
 	if err != nil {
 		return nil, err
@@ -104,7 +133,7 @@ func (t *kindPublisher) Publish(ctx context.Context, br build.Result, s string)
 	}
 
 	log.Printf("Loading %v", digestTag)
-	if err := kind.Write(ctx, digestTag, img); err != nil {
+	if err := t.write(ctx, digestTag, img); err != nil {
 		return nil, err
 	}
 	log.Printf("Loaded %v", digestTag)
@@ -116,7 +145,7 @@ func (t *kindPublisher) Publish(ctx context.Context, br build.Result, s string)
 			return nil, err
 		}
 
-		if err := kind.Tag(ctx, digestTag, tag); err != nil {
+		if err := t.tag(ctx, digestTag, tag); err != nil {
 			return nil, err
 		}
 		log.Printf("Added tag %v", tagName)
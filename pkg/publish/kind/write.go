@@ -20,12 +20,14 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
 
 	"golang.org/x/sync/errgroup"
 
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/google/ko/pkg/internal/uname"
 
 	"sigs.k8s.io/kind/pkg/cluster"
 	"sigs.k8s.io/kind/pkg/cluster/nodes"
@@ -84,6 +86,24 @@ func Write(ctx context.Context, tag name.Tag, img v1.Image) error {
 	})
 }
 
+// Platform returns the platform of the kind nodes, which run Linux on the
+// architecture of the machine that runs them.
+func Platform(ctx context.Context) (*v1.Platform, error) {
+	nodeList, err := getNodes()
+	if err != nil {
+		return nil, err
+	}
+	n := nodeList[0]
+	var stdout, stderr bytes.Buffer
+	cmd := n.CommandContext(ctx, "uname", "-m")
+	cmd.SetStdout(&stdout)
+	cmd.SetStderr(&stderr)
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to get the architecture of node %q: %w\n%s", n, err, stderr.String())
+	}
+	return uname.Platform(strings.TrimSpace(stdout.String()))
+}
+
 // onEachNode executes the given function on each node. Exits on first error.
 func onEachNode(f func(nodes.Node) error) error {
 	nodeList, err := getNodes()
@@ -23,6 +23,7 @@ import (
 	"testing"
 
 	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/random"
 	"sigs.k8s.io/kind/pkg/cluster/nodes"
 	"sigs.k8s.io/kind/pkg/exec"
@@ -98,6 +99,26 @@ func TestTag(t *testing.T) {
 	}
 }
 
+func TestPlatform(t *testing.T) {
+	n1 := &fakeNode{out: "aarch64\n"}
+	n2 := &fakeNode{out: "aarch64\n"}
+	GetProvider = func() provider {
+		return &fakeProvider{nodes: []nodes.Node{n1, n2}}
+	}
+
+	p, err := Platform(context.Background())
+	if err != nil {
+		t.Fatalf("Platform() = %v", err)
+	}
+	if want := (&v1.Platform{OS: "linux", Architecture: "arm64"}); !p.Equals(*want) {
+		t.Errorf("Platform() = %v, wanted %v", p, want)
+	}
+	// Only one node is asked, since they all run on the same machine.
+	if got, want := len(n1.cmds)+len(n2.cmds), 1; got != want {
+		t.Errorf("ran %d commands, wanted %d", got, want)
+	}
+}
+
 func TestFailWithNoNodes(t *testing.T) {
 	ctx := context.Background()
 	img, err := random.Image(1024, 1)
@@ -171,12 +192,14 @@ func (f *fakeProvider) ListInternalNodes(string) ([]nodes.Node, error) {
 
 type fakeNode struct {
 	cmds []*fakeCmd
+	out  string
 	err  error
 }
 
 func (f *fakeNode) CommandContext(_ context.Context, cmd string, args ...string) exec.Cmd {
 	command := &fakeCmd{
 		cmd: strings.Join(append([]string{cmd}, args...), " "),
+		out: f.out,
 		err: f.err,
 	}
 	f.cmds = append(f.cmds, command)
@@ -194,9 +217,11 @@ func (f *fakeNode) IP() (string, string, error)        { return "", "", nil }
 func (f *fakeNode) SerialLogs(io.Writer) error         { return nil }
 
 type fakeCmd struct {
-	cmd   string
-	err   error
-	stdin io.Reader
+	cmd    string
+	out    string
+	err    error
+	stdin  io.Reader
+	stdout io.Writer
 }
 
 func (f *fakeCmd) Run() error {
@@ -204,6 +229,9 @@ func (f *fakeCmd) Run() error {
 		// Consume the entire stdin to move the image publish forward.
 		io.ReadAll(f.stdin)
 	}
+	if f.stdout != nil {
+		io.WriteString(f.stdout, f.out)
+	}
 	return f.err
 }
 
@@ -212,7 +240,11 @@ func (f *fakeCmd) SetStdin(stdin io.Reader) exec.Cmd {
 	return f
 }
 
+func (f *fakeCmd) SetStdout(stdout io.Writer) exec.Cmd {
+	f.stdout = stdout
+	return f
+}
+
 // The following functions are not used by our code at all.
 func (f *fakeCmd) SetEnv(...string) exec.Cmd    { return f }
-func (f *fakeCmd) SetStdout(io.Writer) exec.Cmd { return f }
 func (f *fakeCmd) SetStderr(io.Writer) exec.Cmd { return f }
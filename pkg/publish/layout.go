@@ -16,24 +16,66 @@ package publish
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/empty"
 	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
 	"github.com/google/go-containerregistry/pkg/v1/types"
 	"github.com/google/ko/pkg/build"
+	specsv1 "github.com/opencontainers/image-spec/specs-go/v1"
 )
 
 type LayoutPublisher struct {
-	p string
+	p     string
+	base  string
+	namer Namer
+	tags  []string
+
+	// mu guards index.json, which every Publish rewrites.
+	mu        sync.Mutex
+	published bool
+}
+
+// LayoutOption is a functional option for NewLayout.
+type LayoutOption func(*LayoutPublisher)
+
+// WithLayoutNamer is a functional option for naming the images in the
+// layout's index.json, as they would be named in the base repository.
+func WithLayoutNamer(base string, namer Namer) LayoutOption {
+	return func(l *LayoutPublisher) {
+		l.base = base
+		l.namer = namer
+	}
+}
+
+// WithLayoutTags is a functional option for the tags of the images named in
+// the layout's index.json.
+func WithLayoutTags(tags []string) LayoutOption {
+	return func(l *LayoutPublisher) {
+		l.tags = tags
+	}
 }
 
 // NewLayout returns a new publish.Interface that saves images to an OCI Image Layout.
-func NewLayout(p string) Interface {
-	return &LayoutPublisher{p}
+func NewLayout(p string, opts ...LayoutOption) Interface {
+	l := &LayoutPublisher{p: p}
+	for _, option := range opts {
+		option(l)
+	}
+	return l
 }
 
 func (l *LayoutPublisher) writeResult(br build.Result) (layout.Path, error) {
@@ -56,7 +98,7 @@ func (l *LayoutPublisher) writeResult(br build.Result) (layout.Path, error) {
 		if !ok {
 			return "", fmt.Errorf("failed to interpret result as index: %v", br)
 		}
-		if err := p.AppendIndex(idx); err != nil {
+		if err := p.WriteIndex(idx); err != nil {
 			return "", err
 		}
 		return p, nil
@@ -65,7 +107,7 @@ func (l *LayoutPublisher) writeResult(br build.Result) (layout.Path, error) {
 		if !ok {
 			return "", fmt.Errorf("failed to interpret result as image: %v", br)
 		}
-		if err := p.AppendImage(img); err != nil {
+		if err := p.WriteImage(img); err != nil {
 			return "", err
 		}
 		return p, nil
@@ -74,6 +116,77 @@ func (l *LayoutPublisher) writeResult(br build.Result) (layout.Path, error) {
 	}
 }
 
+// refNames returns the names that the result of building s has in
+// index.json, which are none without a namer.
+func (l *LayoutPublisher) refNames(s string, h v1.Hash) ([]string, error) {
+	if l.namer == nil {
+		return nil, nil
+	}
+	s = strings.ToLower(strings.TrimPrefix(s, build.StrictScheme))
+	repo := l.namer(l.base, s)
+	if len(l.tags) == 0 {
+		ref, err := name.NewDigest(fmt.Sprintf("%s@%s", repo, h))
+		if err != nil {
+			return nil, err
+		}
+		return []string{ref.String()}, nil
+	}
+	var names []string
+	for _, t := range l.tags {
+		tag, err := name.NewTag(fmt.Sprintf("%s:%s", repo, t))
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, tag.String())
+	}
+	return names, nil
+}
+
+// updateIndex adds a descriptor of br to index.json for each of names,
+// replacing the entries that had those names, or that had no name and the
+// same digest.
+func (l *LayoutPublisher) updateIndex(p layout.Path, br build.Result, names []string) error {
+	desc, err := partial.Descriptor(br)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.published = true
+
+	ii, err := p.ImageIndex()
+	if err != nil {
+		return err
+	}
+	ii = mutate.RemoveManifests(ii, func(d v1.Descriptor) bool {
+		if n, ok := d.Annotations[specsv1.AnnotationRefName]; ok {
+			return slices.Contains(names, n)
+		}
+		return d.Digest == desc.Digest
+	})
+	var adds []mutate.IndexAddendum
+	for _, n := range names {
+		d := *desc
+		d.Annotations = map[string]string{specsv1.AnnotationRefName: n}
+		adds = append(adds, mutate.IndexAddendum{Add: br, Descriptor: d})
+	}
+	if len(names) == 0 {
+		adds = append(adds, mutate.IndexAddendum{Add: br, Descriptor: *desc})
+	}
+	ii = mutate.AppendManifests(ii, adds...)
+
+	index, err := ii.IndexManifest()
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(index, "", "   ")
+	if err != nil {
+		return err
+	}
+	return p.WriteFile(specsv1.ImageIndexFile, b, os.ModePerm)
+}
+
 // Publish implements publish.Interface.
 func (l *LayoutPublisher) Publish(_ context.Context, br build.Result, s string) (name.Reference, error) {
 	log.Printf("Saving %v", s)
@@ -81,12 +194,19 @@ func (l *LayoutPublisher) Publish(_ context.Context, br build.Result, s string)
 	if err != nil {
 		return nil, err
 	}
-	log.Printf("Saved %v", s)
 
 	h, err := br.Digest()
 	if err != nil {
 		return nil, err
 	}
+	names, err := l.refNames(s, h)
+	if err != nil {
+		return nil, err
+	}
+	if err := l.updateIndex(p, br, names); err != nil {
+		return nil, err
+	}
+	log.Printf("Saved %v", s)
 
 	dig, err := name.NewDigest(fmt.Sprintf("%s@%s", p, h))
 	if err != nil {
@@ -96,6 +216,110 @@ func (l *LayoutPublisher) Publish(_ context.Context, br build.Result, s string)
 	return dig, nil
 }
 
+// Close implements publish.Interface, removing the blobs that are no longer
+// used by any image in the layout.  The images have been saved by then, so
+// failing to remove them is only logged.
 func (l *LayoutPublisher) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.published {
+		return nil
+	}
+	removed, err := garbageCollect(layout.Path(l.p))
+	if err != nil {
+		log.Printf("Not removing unused blobs from %s: %v", l.p, err)
+	} else if removed > 0 {
+		log.Printf("Removed %d unused blobs from %s", removed, l.p)
+	}
+	return nil
+}
+
+// garbageCollect removes the blobs of p that nothing in its index.json
+// refers to, returning how many it removed.
+func garbageCollect(p layout.Path) (int, error) {
+	ii, err := p.ImageIndex()
+	if err != nil {
+		return 0, err
+	}
+	keep := map[v1.Hash]bool{}
+	if err := markIndex(ii, keep); err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	blobs := filepath.Join(string(p), specsv1.ImageBlobsDir)
+	err = filepath.WalkDir(blobs, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(blobs, path)
+		if err != nil {
+			return err
+		}
+		h, err := v1.NewHash(strings.Replace(filepath.ToSlash(rel), "/", ":", 1))
+		if err != nil {
+			// Leave files that aren't blobs alone.
+			return nil
+		}
+		if keep[h] {
+			return nil
+		}
+		if err := p.RemoveBlob(h); err != nil {
+			return err
+		}
+		removed++
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		err = nil
+	}
+	return removed, err
+}
+
+// markIndex adds the blobs that ii refers to to keep.
+func markIndex(ii v1.ImageIndex, keep map[v1.Hash]bool) error {
+	m, err := ii.IndexManifest()
+	if err != nil {
+		return err
+	}
+	for _, desc := range m.Manifests {
+		if keep[desc.Digest] {
+			continue
+		}
+		keep[desc.Digest] = true
+		switch {
+		case desc.MediaType.IsIndex():
+			child, err := ii.ImageIndex(desc.Digest)
+			if err != nil {
+				return err
+			}
+			if err := markIndex(child, keep); err != nil {
+				return err
+			}
+		case desc.MediaType.IsImage():
+			img, err := ii.Image(desc.Digest)
+			if err != nil {
+				return err
+			}
+			h, err := img.ConfigName()
+			if err != nil {
+				return err
+			}
+			keep[h] = true
+			layers, err := img.Layers()
+			if err != nil {
+				return err
+			}
+			for _, l := range layers {
+				h, err := l.Digest()
+				if err != nil {
+					return err
+				}
+				keep[h] = true
+			}
+		default:
+			return fmt.Errorf("can't tell which blobs %s uses, of media type %q", desc.Digest, desc.MediaType)
+		}
+	}
 	return nil
 }
@@ -17,10 +17,17 @@ package publish
 import (
 	"context"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
 	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+	"github.com/google/ko/pkg/build"
+	specsv1 "github.com/opencontainers/image-spec/specs-go/v1"
 )
 
 func TestLayout(t *testing.T) {
@@ -43,3 +50,132 @@ func TestLayout(t *testing.T) {
 		t.Errorf("Publish() = %v, wanted prefix %v", d, tmp)
 	}
 }
+
+func TestLayoutNames(t *testing.T) {
+	// Publish returns references in the layout's directory, so it has to be
+	// a valid repository name.
+	tmp, err := os.MkdirTemp("/tmp", "ko")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	importpath := "github.com/Google/go-containerregistry/cmd/crane"
+	repo := "example.com/blah/crane"
+	namer := func(base, importpath string) string {
+		return base + "/" + filepath.Base(importpath)
+	}
+
+	publishAndClose := func(br build.Result, tags []string) {
+		t.Helper()
+		lp := NewLayout(tmp, WithLayoutNamer("example.com/blah", namer), WithLayoutTags(tags))
+		if _, err := lp.Publish(context.Background(), br, importpath); err != nil {
+			t.Fatalf("Publish() = %v", err)
+		}
+		if err := lp.Close(); err != nil {
+			t.Fatalf("Close() = %v", err)
+		}
+	}
+	entries := func() map[string]v1.Hash {
+		t.Helper()
+		p, err := layout.FromPath(tmp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ii, err := p.ImageIndex()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := validate.Index(ii); err != nil {
+			t.Errorf("validate.Index() = %v", err)
+		}
+		m, err := ii.IndexManifest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := map[string]v1.Hash{}
+		for _, desc := range m.Manifests {
+			got[desc.Annotations[specsv1.AnnotationRefName]] = desc.Digest
+		}
+		if len(got) != len(m.Manifests) {
+			t.Errorf("index.json has %d entries for %d names", len(m.Manifests), len(got))
+		}
+		return got
+	}
+
+	img1, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d1, err := img1.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	publishAndClose(img1, []string{"latest", "v1"})
+	publishAndClose(img1, []string{"latest", "v1"})
+	if diff := cmp.Diff(map[string]v1.Hash{repo + ":latest": d1, repo + ":v1": d1}, entries()); diff != "" {
+		t.Errorf("index.json (-want +got): %s", diff)
+	}
+
+	// Publishing another image with the same tag replaces it, and removes
+	// the blobs that only the old image used.
+	idx, err := random.Index(1024, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d2, err := idx.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	publishAndClose(idx, []string{"latest"})
+	if diff := cmp.Diff(map[string]v1.Hash{repo + ":latest": d2, repo + ":v1": d1}, entries()); diff != "" {
+		t.Errorf("index.json (-want +got): %s", diff)
+	}
+	img2, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publishAndClose(img2, []string{"v1"})
+	if _, err := os.Stat(filepath.Join(tmp, "blobs", d1.Algorithm, d1.Hex)); !os.IsNotExist(err) {
+		t.Errorf("blob of replaced image %s still there: %v", d1, err)
+	}
+	ls, err := img1.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range ls {
+		h, err := l.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(filepath.Join(tmp, "blobs", h.Algorithm, h.Hex)); !os.IsNotExist(err) {
+			t.Errorf("layer %s of replaced image still there: %v", h, err)
+		}
+	}
+
+	// Without a namer, publishing the same image again doesn't add another
+	// entry.
+	unnamed, err := os.MkdirTemp("/tmp", "ko")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(unnamed)
+	for range 2 {
+		lp := NewLayout(unnamed)
+		if _, err := lp.Publish(context.Background(), img1, importpath); err != nil {
+			t.Fatalf("Publish() = %v", err)
+		}
+	}
+	p, err := layout.FromPath(unnamed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ii, err := p.ImageIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m, err := ii.IndexManifest(); err != nil {
+		t.Fatal(err)
+	} else if len(m.Manifests) != 1 {
+		t.Errorf("index.json has %d entries, wanted 1", len(m.Manifests))
+	}
+}
@@ -0,0 +1,40 @@
+// Copyright 2026 ko Build Authors All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package publish
+
+import (
+	"github.com/google/ko/pkg/publish/minikube"
+)
+
+const (
+	// MinikubeDomain is a sentinel "registry" that represents side-loading images into minikube nodes.
+	MinikubeDomain = "minikube.local"
+)
+
+// NewMinikubePublisher returns a new publish.Interface that loads images into minikube nodes.
+func NewMinikubePublisher(base string, namer Namer, tags []string, opts ...KindOption) Interface {
+	t := &kindPublisher{
+		base:          base,
+		namer:         namer,
+		tags:          tags,
+		write:         minikube.Write,
+		tag:           minikube.Tag,
+		nodesPlatform: minikube.Platform,
+	}
+	for _, option := range opts {
+		option(t)
+	}
+	return t
+}
@@ -0,0 +1,262 @@
+// Copyright 2026 ko Build Authors All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package minikube
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/google/ko/pkg/internal/uname"
+
+	"sigs.k8s.io/kind/pkg/exec"
+)
+
+const (
+	// The same environment variable as the minikube CLI.
+	profileEnvKey = "MINIKUBE_PROFILE"
+
+	// The profile that minikube uses when it is not given one.
+	defaultProfile = "minikube"
+)
+
+// runtimeCommands are the commands that load and tag images in the container
+// runtimes that minikube supports.
+var runtimeCommands = map[string]struct {
+	load, tag []string
+}{
+	"docker": {
+		load: []string{"docker", "load"},
+		tag:  []string{"docker", "tag"},
+	},
+	"containerd": {
+		load: []string{"sudo", "ctr", "--namespace=k8s.io", "images", "import", "--all-platforms", "-"},
+		tag:  []string{"sudo", "ctr", "--namespace=k8s.io", "images", "tag", "--force"},
+	},
+	"crio": {
+		load: []string{"sudo", "podman", "load"},
+		tag:  []string{"sudo", "podman", "tag"},
+	},
+}
+
+// node is a minikube node that can run commands.
+type node interface {
+	CommandContext(ctx context.Context, cmd string, args ...string) exec.Cmd
+	// Runtime is the container runtime of the node, as minikube calls it.
+	Runtime() string
+	String() string
+}
+
+// provider is an interface for minikube providers to facilitate testing.
+type provider interface {
+	ListNodes(profile string) ([]node, error)
+}
+
+// GetProvider is a variable so we can override in tests.
+var GetProvider = func() provider {
+	return cliProvider{}
+}
+
+// cliProvider finds the nodes of minikube profiles with the minikube CLI.
+type cliProvider struct{}
+
+func (cliProvider) ListNodes(profile string) ([]node, error) {
+	out, err := exec.Output(exec.Command("minikube", "profile", "list", "--output=json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list minikube profiles: %w", err)
+	}
+	return parseNodes(out, profile)
+}
+
+// profileList is the part of `minikube profile list --output=json` that
+// describes the nodes of each profile.
+type profileList struct {
+	Valid []struct {
+		Name   string
+		Config struct {
+			KubernetesConfig struct {
+				ContainerRuntime string
+			}
+			Nodes []struct {
+				Name string
+			}
+		}
+	} `json:"valid"`
+}
+
+// parseNodes returns the nodes of profile from the output of
+// `minikube profile list --output=json`.
+func parseNodes(b []byte, profile string) ([]node, error) {
+	var pl profileList
+	if err := json.Unmarshal(b, &pl); err != nil {
+		return nil, fmt.Errorf("failed to parse minikube profiles: %w", err)
+	}
+	for _, p := range pl.Valid {
+		if p.Name != profile {
+			continue
+		}
+		runtime := p.Config.KubernetesConfig.ContainerRuntime
+		if runtime == "cri-o" {
+			runtime = "crio"
+		}
+		var nodes []node
+		for _, n := range p.Config.Nodes {
+			nodes = append(nodes, sshNode{profile: profile, name: n.Name, runtime: runtime})
+		}
+		return nodes, nil
+	}
+	return nil, nil
+}
+
+// sshNode is a minikube node that runs commands with minikube ssh.
+type sshNode struct {
+	profile string
+	// The name of the node, which is empty for the first one.
+	name    string
+	runtime string
+}
+
+func (n sshNode) CommandContext(ctx context.Context, cmd string, args ...string) exec.Cmd {
+	ssh := []string{"--profile", n.profile, "ssh"}
+	if n.name != "" {
+		ssh = append(ssh, "--node", n.name)
+	}
+	ssh = append(ssh, "--", cmd)
+	return exec.CommandContext(ctx, "minikube", append(ssh, args...)...)
+}
+
+func (n sshNode) Runtime() string {
+	return n.runtime
+}
+
+func (n sshNode) String() string {
+	if n.name == "" {
+		return n.profile
+	}
+	return n.profile + "-" + n.name
+}
+
+// Tag adds a tag to an already existent image.
+func Tag(ctx context.Context, src, dest name.Tag) error {
+	return onEachNode(func(n node) error {
+		rc, ok := runtimeCommands[n.Runtime()]
+		if !ok {
+			return fmt.Errorf("unsupported container runtime %q on node %q", n.Runtime(), n)
+		}
+		var buf bytes.Buffer
+		cmd := n.CommandContext(ctx, rc.tag[0], append(rc.tag[1:], src.String(), dest.String())...)
+		cmd.SetStdout(&buf)
+		cmd.SetStderr(&buf)
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("failed to tag image: %w\n%s", err, buf.String())
+		}
+		return nil
+	})
+}
+
+// Write saves the image into the minikube nodes as the given tag.
+func Write(ctx context.Context, tag name.Tag, img v1.Image) error {
+	return onEachNode(func(n node) error {
+		rc, ok := runtimeCommands[n.Runtime()]
+		if !ok {
+			return fmt.Errorf("unsupported container runtime %q on node %q", n.Runtime(), n)
+		}
+
+		pr, pw := io.Pipe()
+
+		grp := errgroup.Group{}
+		grp.Go(func() error {
+			return pw.CloseWithError(tarball.Write(tag, img, pw))
+		})
+
+		var buf bytes.Buffer
+		cmd := n.CommandContext(ctx, rc.load[0], rc.load[1:]...).SetStdin(pr)
+		cmd.SetStdout(&buf)
+		cmd.SetStderr(&buf)
+		if err := cmd.Run(); err != nil {
+			pr.CloseWithError(err)
+			return fmt.Errorf("failed to load image to node %q: %w\n%s", n, err, buf.String())
+		}
+
+		if err := grp.Wait(); err != nil {
+			return fmt.Errorf("failed to write intermediate tarball representation: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// Platform returns the platform of the minikube nodes, which run Linux on
+// the architecture of the machine or VM that runs them.
+func Platform(ctx context.Context) (*v1.Platform, error) {
+	nodeList, err := getNodes()
+	if err != nil {
+		return nil, err
+	}
+	n := nodeList[0]
+	var stdout, stderr bytes.Buffer
+	cmd := n.CommandContext(ctx, "uname", "-m")
+	cmd.SetStdout(&stdout)
+	cmd.SetStderr(&stderr)
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to get the architecture of node %q: %w\n%s", n, err, stderr.String())
+	}
+	return uname.Platform(strings.TrimSpace(stdout.String()))
+}
+
+// onEachNode executes the given function on each node. Exits on first error.
+func onEachNode(f func(node) error) error {
+	nodeList, err := getNodes()
+	if err != nil {
+		return err
+	}
+
+	for _, n := range nodeList {
+		if err := f(n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getNodes gets all the nodes of the profile in $MINIKUBE_PROFILE, or of
+// the default profile.  Returns an error if none were found.
+func getNodes() ([]node, error) {
+	provider := GetProvider()
+
+	profile := os.Getenv(profileEnvKey)
+	if profile == "" {
+		profile = defaultProfile
+	}
+
+	nodeList, err := provider.ListNodes(profile)
+	if err != nil {
+		return nil, err
+	}
+	if len(nodeList) == 0 {
+		return nil, fmt.Errorf("no nodes found for minikube profile %q", profile)
+	}
+
+	return nodeList, nil
+}
@@ -0,0 +1,275 @@
+// Copyright 2026 ko Build Authors All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package minikube
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"sigs.k8s.io/kind/pkg/exec"
+)
+
+func TestWrite(t *testing.T) {
+	ctx := context.Background()
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatalf("random.Image() = %v", err)
+	}
+
+	tag, err := name.NewTag("minikube.local/test:new")
+	if err != nil {
+		t.Fatalf("name.NewTag() = %v", err)
+	}
+
+	for runtime, want := range map[string]string{
+		"docker":     "docker load",
+		"containerd": "sudo ctr --namespace=k8s.io images import --all-platforms -",
+		"crio":       "sudo podman load",
+	} {
+		n1 := &fakeNode{runtime: runtime}
+		n2 := &fakeNode{runtime: runtime}
+		p := &fakeProvider{nodes: []node{n1, n2}}
+		GetProvider = func() provider { return p }
+
+		if err := Write(ctx, tag, img); err != nil {
+			t.Fatalf("Write() = %v", err)
+		}
+		if got, want := p.profile, "minikube"; got != want {
+			t.Errorf("profile = %q, want %q", got, want)
+		}
+
+		// Verify the respective command is executed on each node.
+		for _, n := range []*fakeNode{n1, n2} {
+			if got, want := len(n.cmds), 1; got != want {
+				t.Fatalf("len(n.cmds) = %d, want %d", got, want)
+			}
+			if got := n.cmds[0].cmd; got != want {
+				t.Fatalf("c.cmd = %s, want %s", got, want)
+			}
+		}
+	}
+
+	GetProvider = func() provider {
+		return &fakeProvider{nodes: []node{&fakeNode{runtime: "rkt"}}}
+	}
+	if err := Write(ctx, tag, img); err == nil {
+		t.Error("Write() = nil, wanted an error for an unknown runtime")
+	}
+}
+
+func TestTag(t *testing.T) {
+	ctx := context.Background()
+	oldTag, err := name.NewTag("minikube.local/test:test")
+	if err != nil {
+		t.Fatalf("name.NewTag() = %v", err)
+	}
+
+	newTag, err := name.NewTag("minikube.local/test:new")
+	if err != nil {
+		t.Fatalf("name.NewTag() = %v", err)
+	}
+
+	t.Setenv("MINIKUBE_PROFILE", "other")
+	n1 := &fakeNode{runtime: "containerd"}
+	n2 := &fakeNode{runtime: "containerd"}
+	p := &fakeProvider{nodes: []node{n1, n2}}
+	GetProvider = func() provider { return p }
+
+	if err := Tag(ctx, oldTag, newTag); err != nil {
+		t.Fatalf("Tag() = %v", err)
+	}
+	if got, want := p.profile, "other"; got != want {
+		t.Errorf("profile = %q, want %q", got, want)
+	}
+
+	// Verify the respective command is executed on each node.
+	for _, n := range []*fakeNode{n1, n2} {
+		if got, want := len(n.cmds), 1; got != want {
+			t.Fatalf("len(n.cmds) = %d, want %d", got, want)
+		}
+		if got, want := n.cmds[0].cmd, fmt.Sprintf("sudo ctr --namespace=k8s.io images tag --force %s %s", oldTag, newTag); got != want {
+			t.Fatalf("c.cmd = %s, want %s", got, want)
+		}
+	}
+}
+
+func TestPlatform(t *testing.T) {
+	n1 := &fakeNode{out: "aarch64\n"}
+	n2 := &fakeNode{out: "aarch64\n"}
+	GetProvider = func() provider {
+		return &fakeProvider{nodes: []node{n1, n2}}
+	}
+
+	p, err := Platform(context.Background())
+	if err != nil {
+		t.Fatalf("Platform() = %v", err)
+	}
+	if want := (&v1.Platform{OS: "linux", Architecture: "arm64"}); !p.Equals(*want) {
+		t.Errorf("Platform() = %v, wanted %v", p, want)
+	}
+}
+
+func TestParseNodes(t *testing.T) {
+	out := []byte(`{"invalid":[],"valid":[{
+		"Name":"minikube","Status":"OK",
+		"Config":{"KubernetesConfig":{"ContainerRuntime":"docker"},"Nodes":[{"Name":""}]}
+	},{
+		"Name":"multi","Status":"OK",
+		"Config":{"KubernetesConfig":{"ContainerRuntime":"cri-o"},"Nodes":[{"Name":""},{"Name":"m02"}]}
+	}]}`)
+	got, err := parseNodes(out, "multi")
+	if err != nil {
+		t.Fatalf("parseNodes() = %v", err)
+	}
+	want := []node{
+		sshNode{profile: "multi", runtime: "crio"},
+		sshNode{profile: "multi", name: "m02", runtime: "crio"},
+	}
+	if diff := cmp.Diff(want, got, cmp.AllowUnexported(sshNode{})); diff != "" {
+		t.Errorf("parseNodes() (-want +got): %s", diff)
+	}
+	if got, want := fmt.Sprint(got[1]), "multi-m02"; got != want {
+		t.Errorf("node = %s, want %s", got, want)
+	}
+
+	if got, err := parseNodes(out, "missing"); err != nil || len(got) != 0 {
+		t.Errorf("parseNodes(missing) = %v, %v, wanted no nodes", got, err)
+	}
+}
+
+func TestFailWithNoNodes(t *testing.T) {
+	ctx := context.Background()
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatalf("random.Image() = %v", err)
+	}
+
+	tag, err := name.NewTag("minikube.local/test:new")
+	if err != nil {
+		t.Fatalf("name.NewTag() = %v", err)
+	}
+
+	GetProvider = func() provider {
+		return &fakeProvider{}
+	}
+
+	if err := Write(ctx, tag, img); err == nil {
+		t.Fatal("Write() = nil, wanted an error")
+	}
+	if err := Tag(ctx, tag, tag); err == nil {
+		t.Fatal("Tag() = nil, wanted an error")
+	}
+}
+
+func TestFailCommands(t *testing.T) {
+	ctx := context.Background()
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatalf("random.Image() = %v", err)
+	}
+
+	tag, err := name.NewTag("minikube.local/test:new")
+	if err != nil {
+		t.Fatalf("name.NewTag() = %v", err)
+	}
+
+	errTest := errors.New("test")
+	GetProvider = func() provider {
+		return &fakeProvider{nodes: []node{&fakeNode{runtime: "docker", err: errTest}}}
+	}
+
+	if err := Write(ctx, tag, img); !errors.Is(err, errTest) {
+		t.Fatalf("Write() = %v, want %v", err, errTest)
+	}
+	if err := Tag(ctx, tag, tag); !errors.Is(err, errTest) {
+		t.Fatalf("Tag() = %v, want %v", err, errTest)
+	}
+}
+
+type fakeProvider struct {
+	nodes   []node
+	profile string
+}
+
+func (f *fakeProvider) ListNodes(profile string) ([]node, error) {
+	f.profile = profile
+	return f.nodes, nil
+}
+
+type fakeNode struct {
+	cmds    []*fakeCmd
+	runtime string
+	out     string
+	err     error
+}
+
+func (f *fakeNode) CommandContext(_ context.Context, cmd string, args ...string) exec.Cmd {
+	command := &fakeCmd{
+		cmd: strings.Join(append([]string{cmd}, args...), " "),
+		out: f.out,
+		err: f.err,
+	}
+	f.cmds = append(f.cmds, command)
+	return command
+}
+
+func (f *fakeNode) Runtime() string {
+	return f.runtime
+}
+
+func (f *fakeNode) String() string {
+	return "test"
+}
+
+type fakeCmd struct {
+	cmd    string
+	out    string
+	err    error
+	stdin  io.Reader
+	stdout io.Writer
+}
+
+func (f *fakeCmd) Run() error {
+	if f.stdin != nil {
+		// Consume the entire stdin to move the image publish forward.
+		io.ReadAll(f.stdin)
+	}
+	if f.stdout != nil {
+		io.WriteString(f.stdout, f.out)
+	}
+	return f.err
+}
+
+func (f *fakeCmd) SetStdin(stdin io.Reader) exec.Cmd {
+	f.stdin = stdin
+	return f
+}
+
+func (f *fakeCmd) SetStdout(stdout io.Writer) exec.Cmd {
+	f.stdout = stdout
+	return f
+}
+
+// The following functions are not used by our code at all.
+func (f *fakeCmd) SetEnv(...string) exec.Cmd    { return f }
+func (f *fakeCmd) SetStderr(io.Writer) exec.Cmd { return f }
@@ -17,44 +17,104 @@ package publish
 import (
 	"context"
 	"errors"
+	"log"
+	"sync"
 
 	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/google/ko/pkg/build"
 )
 
 // MultiPublisher creates a publisher that publishes to all
-// the provided publishers, similar to the Unix tee(1) command.
+// the provided publishers concurrently, similar to the Unix tee(1) command.
 //
 // When calling Publish, the name.Reference returned will be the return value
-// of the last publisher passed to MultiPublisher (last one wins).
+// of the last publisher passed to MultiPublisher, which is the primary.
 func MultiPublisher(publishers ...Interface) Interface {
-	return &multiPublisher{publishers}
+	if len(publishers) == 0 {
+		return &multiPublisher{}
+	}
+	last := len(publishers) - 1
+	return NewMulti(publishers[last], publishers[:last])
+}
+
+// MultiOption is a functional option for NewMulti.
+type MultiOption func(*multiPublisher)
+
+// WithIgnoreSecondaryErrors makes Publish and Close log the errors of the
+// secondary publishers, and succeed as long as the primary does.
+func WithIgnoreSecondaryErrors(ignore bool) MultiOption {
+	return func(p *multiPublisher) {
+		p.ignoreSecondaryErrors = ignore
+	}
+}
+
+// NewMulti returns a publisher that publishes to the primary and to each of
+// the secondaries concurrently, and returns the primary's reference.
+// Publishing fails with the errors of all the publishers that fail.
+func NewMulti(primary Interface, secondaries []Interface, opts ...MultiOption) Interface {
+	p := &multiPublisher{
+		primary:     primary,
+		secondaries: secondaries,
+	}
+	for _, o := range opts {
+		o(p)
+	}
+	return p
 }
 
 type multiPublisher struct {
-	publishers []Interface
+	primary               Interface
+	secondaries           []Interface
+	ignoreSecondaryErrors bool
 }
 
 // Publish implements publish.Interface.
-func (p *multiPublisher) Publish(ctx context.Context, br build.Result, s string) (ref name.Reference, err error) {
-	if len(p.publishers) == 0 {
+func (p *multiPublisher) Publish(ctx context.Context, br build.Result, s string) (name.Reference, error) {
+	if p.primary == nil {
 		return nil, errors.New("MultiPublisher configured with zero publishers")
 	}
 
-	for _, pub := range p.publishers {
-		ref, err = pub.Publish(ctx, br, s)
+	// Don't cancel the others when one fails, so that every error is
+	// reported rather than the cancellations that it causes.
+	var wg sync.WaitGroup
+	errs := make([]error, len(p.secondaries))
+	for i, pub := range p.secondaries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = pub.Publish(ctx, br, s)
+		}()
+	}
+	ref, err := p.primary.Publish(ctx, br, s)
+	wg.Wait()
+
+	if err := errors.Join(err, p.secondaryErrors(errs)); err != nil {
+		return nil, err
+	}
+	return ref, nil
+}
+
+// secondaryErrors joins the errors of the secondary publishers, unless they
+// are ignored.
+func (p *multiPublisher) secondaryErrors(errs []error) error {
+	if !p.ignoreSecondaryErrors {
+		return errors.Join(errs...)
+	}
+	for _, err := range errs {
 		if err != nil {
-			return
+			log.Printf("Ignoring error of secondary publisher: %v", err)
 		}
 	}
-	return
+	return nil
 }
 
-func (p *multiPublisher) Close() (err error) {
-	for _, pub := range p.publishers {
-		if perr := pub.Close(); perr != nil {
-			err = perr
-		}
+func (p *multiPublisher) Close() error {
+	if p.primary == nil {
+		return nil
+	}
+	var errs []error
+	for _, pub := range p.secondaries {
+		errs = append(errs, pub.Close())
 	}
-	return
+	return errors.Join(p.primary.Close(), p.secondaryErrors(errs))
 }
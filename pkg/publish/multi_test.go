@@ -16,11 +16,19 @@ package publish_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http/httptest"
+	"net/url"
 	"os"
+	"strings"
 	"testing"
 
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
 	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/ko/pkg/build"
 	"github.com/google/ko/pkg/publish"
 )
 
@@ -71,3 +79,135 @@ func TestMulti_Zero(t *testing.T) {
 		t.Errorf("Publish() got nil error")
 	}
 }
+
+type failingPublisher struct{ err error }
+
+func (f failingPublisher) Publish(context.Context, build.Result, string) (name.Reference, error) {
+	if f.err == nil {
+		return nil, errors.New("failed")
+	}
+	return nil, f.err
+}
+
+func (f failingPublisher) Close() error { return f.err }
+
+// blockingPublisher publishes once release is closed.
+type blockingPublisher struct {
+	release chan struct{}
+	ref     name.Reference
+}
+
+func (b blockingPublisher) Publish(context.Context, build.Result, string) (name.Reference, error) {
+	<-b.release
+	return b.ref, nil
+}
+
+func (blockingPublisher) Close() error { return nil }
+
+// releasingPublisher releases a blockingPublisher when it publishes.
+type releasingPublisher struct {
+	release chan struct{}
+}
+
+func (r releasingPublisher) Publish(context.Context, build.Result, string) (name.Reference, error) {
+	close(r.release)
+	return name.MustParseReference("example.com/secondary"), nil
+}
+
+func (releasingPublisher) Close() error { return nil }
+
+func TestMultiConcurrent(t *testing.T) {
+	// The primary only publishes once the secondary does, which would
+	// deadlock if they ran one after the other.
+	release := make(chan struct{})
+	primary := name.MustParseReference("example.com/primary")
+	p := publish.NewMulti(blockingPublisher{release: release, ref: primary}, []publish.Interface{releasingPublisher{release: release}})
+	ref, err := p.Publish(context.Background(), img, "foo")
+	if err != nil {
+		t.Fatalf("Publish() = %v", err)
+	}
+	if ref != primary {
+		t.Errorf("Publish() = %v, wanted %v", ref, primary)
+	}
+}
+
+func TestMultiErrors(t *testing.T) {
+	errPrimary, errSecondary := errors.New("primary"), errors.New("secondary")
+	p := publish.NewMulti(failingPublisher{err: errPrimary}, []publish.Interface{failingPublisher{err: errSecondary}})
+	if _, err := p.Publish(context.Background(), img, "foo"); !errors.Is(err, errPrimary) || !errors.Is(err, errSecondary) {
+		t.Errorf("Publish() = %v, wanted both %v and %v", err, errPrimary, errSecondary)
+	}
+	if err := p.Close(); !errors.Is(err, errPrimary) || !errors.Is(err, errSecondary) {
+		t.Errorf("Close() = %v, wanted both %v and %v", err, errPrimary, errSecondary)
+	}
+
+	// Only the errors of the primary count when those of the secondaries
+	// are ignored.
+	release := make(chan struct{})
+	close(release)
+	primary := name.MustParseReference("example.com/primary")
+	p = publish.NewMulti(blockingPublisher{release: release, ref: primary}, []publish.Interface{failingPublisher{err: errSecondary}}, publish.WithIgnoreSecondaryErrors(true))
+	if ref, err := p.Publish(context.Background(), img, "foo"); err != nil || ref != primary {
+		t.Errorf("Publish() = %v, %v; wanted %v", ref, err, primary)
+	}
+	if err := p.Close(); err != nil {
+		t.Errorf("Close() = %v", err)
+	}
+	p = publish.NewMulti(failingPublisher{err: errPrimary}, nil, publish.WithIgnoreSecondaryErrors(true))
+	if _, err := p.Publish(context.Background(), img, "foo"); !errors.Is(err, errPrimary) {
+		t.Errorf("Publish() = %v, wanted %v", err, errPrimary)
+	}
+}
+
+func TestMultiRegistries(t *testing.T) {
+	importpath := "github.com/google/ko/cmd/app"
+	var repos []string
+	var publishers []publish.Interface
+	for range 3 {
+		server := httptest.NewServer(registry.New())
+		defer server.Close()
+		u, err := url.Parse(server.URL)
+		if err != nil {
+			t.Fatalf("url.Parse(%v) = %v", server.URL, err)
+		}
+		repo := fmt.Sprintf("%s/mirror", u.Host)
+		def, err := publish.NewDefault(repo, publish.WithNamer(md5Hash), publish.WithTags([]string{"v1"}))
+		if err != nil {
+			t.Fatalf("NewDefault() = %v", err)
+		}
+		repos = append(repos, repo)
+		publishers = append(publishers, def)
+	}
+
+	// The second is the primary.
+	p := publish.NewMulti(publishers[1], []publish.Interface{publishers[0], publishers[2]})
+	ref, err := p.Publish(context.Background(), img, importpath)
+	if err != nil {
+		t.Fatalf("Publish() = %v", err)
+	}
+	if want := md5Hash(repos[1], importpath); !strings.HasPrefix(ref.String(), want) {
+		t.Errorf("Publish() = %v, wanted prefix %v", ref, want)
+	}
+	want, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, repo := range repos {
+		got, err := crane.Digest(md5Hash(repo, importpath) + ":v1")
+		if err != nil {
+			t.Fatalf("crane.Digest() = %v", err)
+		}
+		if got != want.String() {
+			t.Errorf("%s has %s, wanted %s", repo, got, want)
+		}
+	}
+	if err := p.Close(); err != nil {
+		t.Errorf("Close() = %v", err)
+	}
+
+	// A failing secondary fails the publish.
+	p = publish.NewMulti(publishers[0], []publish.Interface{publishers[1], failingPublisher{}})
+	if _, err := p.Publish(context.Background(), img, importpath); err == nil {
+		t.Error("Publish() = nil, wanted an error")
+	}
+}
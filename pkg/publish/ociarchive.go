@@ -0,0 +1,256 @@
+// Copyright 2026 ko Build Authors All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package publish
+
+import (
+	archivetar "archive/tar"
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path"
+	"slices"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/google/ko/pkg/build"
+	specsv1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// annotationImageName is the annotation that containerd uses for the name
+// of an image in an index.json.
+const annotationImageName = "io.containerd.image.name"
+
+// writeOCIArchiveToFile writes the results to file as an OCI image layout
+// in a tarball.
+func writeOCIArchiveToFile(file string, refs map[name.Reference]build.Result) (err error) {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	return writeOCIArchive(f, refs)
+}
+
+// writeOCIArchive writes the results to out as an OCI image layout in a
+// tarball, with an entry in its index.json for each of the references.
+func writeOCIArchive(out io.Writer, refs map[name.Reference]build.Result) error {
+	w := &ociArchiveWriter{
+		tw:      archivetar.NewWriter(out),
+		written: map[string]bool{},
+	}
+	if err := w.writeFile(specsv1.ImageLayoutFile, []byte(fmt.Sprintf(`{"imageLayoutVersion": %q}`, specsv1.ImageLayoutVersion))); err != nil {
+		return err
+	}
+
+	index := v1.IndexManifest{
+		SchemaVersion: 2,
+		MediaType:     types.OCIImageIndex,
+	}
+	// Sort the references so that the same results make the same archive.
+	sorted := slices.SortedFunc(maps.Keys(refs), func(a, b name.Reference) int {
+		return cmp.Compare(a.String(), b.String())
+	})
+	for _, ref := range sorted {
+		br := refs[ref]
+		if err := w.writeResult(br); err != nil {
+			return fmt.Errorf("writing %s: %w", ref, err)
+		}
+		desc, err := partial.Descriptor(br)
+		if err != nil {
+			return err
+		}
+		d := *desc
+		d.Annotations = maps.Clone(desc.Annotations)
+		if d.Annotations == nil {
+			d.Annotations = map[string]string{}
+		}
+		d.Annotations[specsv1.AnnotationRefName] = ref.String()
+		d.Annotations[annotationImageName] = ref.String()
+		index.Manifests = append(index.Manifests, d)
+	}
+
+	b, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	if err := w.writeFile(specsv1.ImageIndexFile, b); err != nil {
+		return err
+	}
+	return w.tw.Close()
+}
+
+// ociArchiveWriter writes the blobs of images and indexes to an OCI image
+// layout tarball, writing each blob only once.
+type ociArchiveWriter struct {
+	tw      *archivetar.Writer
+	written map[string]bool
+}
+
+func (w *ociArchiveWriter) writeResult(br build.Result) error {
+	switch br := br.(type) {
+	case v1.ImageIndex:
+		return w.writeIndex(br)
+	case v1.Image:
+		return w.writeImage(br)
+	default:
+		return fmt.Errorf("unsupported result type %T", br)
+	}
+}
+
+func (w *ociArchiveWriter) writeIndex(idx v1.ImageIndex) error {
+	m, err := idx.IndexManifest()
+	if err != nil {
+		return err
+	}
+	for _, desc := range m.Manifests {
+		switch {
+		case desc.MediaType.IsIndex():
+			child, err := idx.ImageIndex(desc.Digest)
+			if err != nil {
+				return err
+			}
+			if err := w.writeIndex(child); err != nil {
+				return err
+			}
+		case desc.MediaType.IsImage():
+			img, err := idx.Image(desc.Digest)
+			if err != nil {
+				return err
+			}
+			if err := w.writeImage(img); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unsupported media type %q for %s", desc.MediaType, desc.Digest)
+		}
+	}
+	return w.writeManifest(idx)
+}
+
+func (w *ociArchiveWriter) writeImage(img v1.Image) error {
+	layers, err := img.Layers()
+	if err != nil {
+		return err
+	}
+	for _, l := range layers {
+		h, err := l.Digest()
+		if err != nil {
+			return err
+		}
+		size, err := l.Size()
+		if err != nil {
+			return err
+		}
+		if err := w.writeBlob(h, size, l.Compressed); err != nil {
+			return err
+		}
+	}
+
+	h, err := img.ConfigName()
+	if err != nil {
+		return err
+	}
+	cfg, err := img.RawConfigFile()
+	if err != nil {
+		return err
+	}
+	if err := w.writeBlob(h, int64(len(cfg)), opener(cfg)); err != nil {
+		return err
+	}
+	return w.writeManifest(img)
+}
+
+func (w *ociArchiveWriter) writeManifest(br build.Result) error {
+	h, err := br.Digest()
+	if err != nil {
+		return err
+	}
+	m, err := br.RawManifest()
+	if err != nil {
+		return err
+	}
+	return w.writeBlob(h, int64(len(m)), opener(m))
+}
+
+func (w *ociArchiveWriter) writeBlob(h v1.Hash, size int64, open func() (io.ReadCloser, error)) error {
+	dir := path.Join(specsv1.ImageBlobsDir, h.Algorithm)
+	file := path.Join(dir, h.Hex)
+	if w.written[file] {
+		return nil
+	}
+	for _, d := range []string{specsv1.ImageBlobsDir, dir} {
+		if w.written[d] {
+			continue
+		}
+		if err := w.tw.WriteHeader(&archivetar.Header{
+			Name:     d + "/",
+			Typeflag: archivetar.TypeDir,
+			Mode:     0o755,
+		}); err != nil {
+			return err
+		}
+		w.written[d] = true
+	}
+
+	rc, err := open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	if err := w.tw.WriteHeader(&archivetar.Header{
+		Name:     file,
+		Typeflag: archivetar.TypeReg,
+		Mode:     0o644,
+		Size:     size,
+	}); err != nil {
+		return err
+	}
+	if n, err := io.Copy(w.tw, rc); err != nil {
+		return fmt.Errorf("writing blob %s: %w", h, err)
+	} else if n != size {
+		return fmt.Errorf("blob %s has %d bytes, expected %d", h, n, size)
+	}
+	w.written[file] = true
+	return nil
+}
+
+func (w *ociArchiveWriter) writeFile(name string, b []byte) error {
+	if err := w.tw.WriteHeader(&archivetar.Header{
+		Name:     name,
+		Typeflag: archivetar.TypeReg,
+		Mode:     0o644,
+		Size:     int64(len(b)),
+	}); err != nil {
+		return err
+	}
+	_, err := w.tw.Write(b)
+	return err
+}
+
+func opener(b []byte) func() (io.ReadCloser, error) {
+	return func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(b)), nil
+	}
+}
@@ -90,6 +90,15 @@ func WithTagOnly(tagOnly bool) Option {
 	}
 }
 
+// WithImmutableTags is a functional option for refusing to move tags that
+// already point to other images, such as release tags.
+func WithImmutableTags(immutable bool) Option {
+	return func(i *defaultOpener) error {
+		i.immutableTags = immutable
+		return nil
+	}
+}
+
 func Insecure(b bool) Option {
 	return func(i *defaultOpener) error {
 		i.insecure = b
@@ -0,0 +1,43 @@
+// Copyright 2026 ko Build Authors All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package publish
+
+import (
+	"fmt"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// imageForPlatform returns the image in idx for the platform p, for loading
+// multi-platform images into places that can only hold images.
+func imageForPlatform(idx v1.ImageIndex, p *v1.Platform) (v1.Image, error) {
+	im, err := idx.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+	for _, desc := range im.Manifests {
+		if desc.Platform == nil || !desc.Platform.Satisfies(*p) {
+			continue
+		}
+		return idx.Image(desc.Digest)
+	}
+	var have []string
+	for _, desc := range im.Manifests {
+		if desc.Platform != nil {
+			have = append(have, desc.Platform.String())
+		}
+	}
+	return nil, fmt.Errorf("failed to find %s image in index, which has %v", p, have)
+}
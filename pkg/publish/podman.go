@@ -0,0 +1,58 @@
+// Copyright 2026 ko Build Authors All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package publish
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/moby/moby/client"
+)
+
+const (
+	// PodmanDomain is a sentinel "registry" that represents side-loading images into Podman.
+	PodmanDomain = "podman.local"
+
+	// The same environment variable as the podman CLI.
+	podmanHostEnvKey = "CONTAINER_HOST"
+)
+
+// podmanHost returns the address of the Podman API socket: $CONTAINER_HOST,
+// or else the rootless socket of the user, or else the rootful one.
+func podmanHost() string {
+	if host := os.Getenv(podmanHostEnvKey); host != "" {
+		return host
+	}
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		sock := filepath.Join(dir, "podman", "podman.sock")
+		if _, err := os.Stat(sock); err == nil {
+			return "unix://" + sock
+		}
+	}
+	return "unix:///run/podman/podman.sock"
+}
+
+// NewPodman returns a new publish.Interface that publishes images to Podman
+// through the Docker-compatible API of its socket.  Podman can't hold
+// multi-platform images, so the image for its platform is loaded.
+func NewPodman(base string, namer Namer, tags []string, opts ...DaemonOption) (Interface, error) {
+	host := podmanHost()
+	c, err := client.New(client.WithHost(host))
+	if err != nil {
+		return nil, fmt.Errorf("connecting to podman at %s: %w", host, err)
+	}
+	return NewDaemon(namer, tags, append([]DaemonOption{WithDockerClient(c), WithLocalDomain(base)}, opts...)...)
+}
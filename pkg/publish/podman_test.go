@@ -0,0 +1,114 @@
+// Copyright 2026 ko Build Authors All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package publish_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/google/ko/pkg/publish"
+)
+
+// fakePodman serves the parts of the Podman API that loading images uses,
+// and records the images loaded and the tags added.
+type fakePodman struct {
+	mu     sync.Mutex
+	loaded []string
+	tags   []string
+}
+
+func (f *fakePodman) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w.Header().Set("Api-Version", "1.41")
+	w.Header().Set("Content-Type", "application/json")
+	switch p := r.URL.Path; {
+	case strings.HasSuffix(p, "/_ping"):
+		_, _ = io.WriteString(w, "OK")
+	case strings.HasSuffix(p, "/version"):
+		_, _ = io.WriteString(w, `{"Os":"linux","Arch":"arm64","ApiVersion":"1.41"}`)
+	case strings.HasSuffix(p, "/info"):
+		_, _ = io.WriteString(w, `{"Driver":"overlay"}`)
+	case strings.HasSuffix(p, "/images/load"):
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		f.loaded = append(f.loaded, string(b))
+		_, _ = io.WriteString(w, `{"stream":"Loaded image"}`)
+	case strings.HasSuffix(p, "/tag"):
+		f.tags = append(f.tags, r.URL.Query().Get("repo")+":"+r.URL.Query().Get("tag"))
+		w.WriteHeader(http.StatusCreated)
+	default:
+		http.Error(w, `{"message":"no such image"}`, http.StatusNotFound)
+	}
+}
+
+func TestPodman(t *testing.T) {
+	importpath := "github.com/google/ko"
+	idx, digests := platformIndex(t)
+
+	f := &fakePodman{}
+	sock := filepath.Join(t.TempDir(), "podman.sock")
+	lis, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: f}
+	go srv.Serve(lis)
+	defer srv.Close()
+	t.Setenv("CONTAINER_HOST", "unix://"+sock)
+
+	def, err := publish.NewPodman(publish.PodmanDomain, md5Hash, []string{"latest"})
+	if err != nil {
+		t.Fatalf("NewPodman() = %v", err)
+	}
+	ref, err := def.Publish(context.Background(), idx, importpath)
+	if err != nil {
+		t.Fatalf("Publish() = %v", err)
+	}
+
+	// Podman's own platform is loaded from the index.
+	repo := md5Hash(publish.PodmanDomain, importpath)
+	if want := repo + ":" + digests["linux/arm64"].Hex; ref.String() != want {
+		t.Errorf("Publish() = %v, wanted %v", ref, want)
+	}
+	if len(f.loaded) != 1 {
+		t.Fatalf("loaded %d images, wanted 1", len(f.loaded))
+	}
+	tarPath := filepath.Join(t.TempDir(), "image.tar")
+	if err := os.WriteFile(tarPath, []byte(f.loaded[0]), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	m, err := tarball.LoadManifest(func() (io.ReadCloser, error) { return os.Open(tarPath) })
+	if err != nil {
+		t.Fatalf("LoadManifest() = %v", err)
+	}
+	if len(m) != 1 || len(m[0].RepoTags) != 1 || m[0].RepoTags[0] != ref.String() {
+		t.Errorf("loaded %+v, wanted %v", m, ref)
+	}
+	if want := repo + ":latest"; len(f.tags) != 1 || f.tags[0] != want {
+		t.Errorf("tags = %v, wanted %v", f.tags, want)
+	}
+}
@@ -16,35 +16,140 @@ package publish
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"io"
 	"os"
 	"strings"
+	"sync"
 
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/ko/pkg/build"
 	"github.com/sigstore/cosign/v3/pkg/oci"
+	ociremote "github.com/sigstore/cosign/v3/pkg/oci/remote"
 	"github.com/sigstore/cosign/v3/pkg/oci/walk"
 )
 
+// ImageRefsFormat is the format of the file that a recorder writes.
+type ImageRefsFormat string
+
+const (
+	// ImageRefsText is one reference per line: the published reference, or
+	// the digests of an index and of each of its images.
+	ImageRefsText ImageRefsFormat = "text"
+
+	// ImageRefsJSON is a JSON object of the RecordedImage of each import
+	// path.
+	ImageRefsJSON ImageRefsFormat = "json"
+)
+
+// ParseImageRefsFormat parses the name of an ImageRefsFormat.
+func ParseImageRefsFormat(s string) (ImageRefsFormat, error) {
+	switch f := ImageRefsFormat(s); f {
+	case "":
+		return ImageRefsText, nil
+	case ImageRefsText, ImageRefsJSON:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unsupported image refs format %q, must be text or json", s)
+	}
+}
+
+// RecordedImage is the image of an import path in ImageRefsJSON files.
+type RecordedImage struct {
+	// Ref is the published reference of the image.
+	Ref string `json:"ref"`
+	// Tags are the references of the tags of the image, if it was pushed
+	// to a registry.
+	Tags []string `json:"tags,omitempty"`
+	// Platforms are the images of each platform of an index, or the image
+	// itself.
+	Platforms []RecordedPlatform `json:"platforms,omitempty"`
+	RecordedAttachments
+}
+
+// RecordedPlatform is the image of a platform in ImageRefsJSON files.
+type RecordedPlatform struct {
+	Platform *v1.Platform `json:"platform,omitempty"`
+	// Ref is the reference of the image by digest.
+	Ref string `json:"ref"`
+	RecordedAttachments
+}
+
+// RecordedAttachments are the references of the SBOM that ko pushed for an
+// image, if it pushed the image to a registry, and of where cosign stores its
+// signatures and attestations.
+type RecordedAttachments struct {
+	SBOM        string `json:"sbom,omitempty"`
+	Signature   string `json:"signature"`
+	Attestation string `json:"attestation"`
+}
+
 // recorder wraps a publisher implementation in a layer that records the published
 // references to a file.
 type recorder struct {
 	inner    Interface
 	fileName string
-	wc       io.Writer
+	format   ImageRefsFormat
+	pushed   bool
+	tags     []string
+	oopt     []ociremote.Option
+
+	mu     sync.Mutex
+	wc     io.Writer
+	images map[string]RecordedImage
 }
 
 // recorder implements Interface
 var _ Interface = (*recorder)(nil)
 
+// RecorderOption is a functional option for NewRecorder.
+type RecorderOption func(*recorder)
+
+// WithImageRefsFormat is a functional option for choosing the format of the
+// file.
+func WithImageRefsFormat(f ImageRefsFormat) RecorderOption {
+	return func(r *recorder) {
+		if f != "" {
+			r.format = f
+		}
+	}
+}
+
+// WithRecorderPushed is a functional option for recording that the inner
+// publisher pushes images to a registry with the given tags, along with
+// their SBOMs.  Only then do ImageRefsJSON files record the tags and SBOMs,
+// which publishers like the daemon, tarball and layout ones don't push.
+func WithRecorderPushed(tags []string) RecorderOption {
+	return func(r *recorder) {
+		r.pushed = true
+		r.tags = tags
+	}
+}
+
 // NewRecorder wraps the provided publish.Interface in an implementation that
 // records publish results to a file.
-func NewRecorder(inner Interface, name string) (Interface, error) {
-	return &recorder{
+func NewRecorder(inner Interface, fileName string, opts ...RecorderOption) (Interface, error) {
+	r := &recorder{
 		inner:    inner,
-		fileName: name,
-	}, nil
+		fileName: fileName,
+		format:   ImageRefsText,
+		images:   map[string]RecordedImage{},
+	}
+	for _, o := range opts {
+		o(r)
+	}
+
+	// Respect COSIGN_REPOSITORY, like the SBOMs that are published.
+	targetRepoOverride, err := ociremote.GetEnvTargetRepository()
+	if err != nil {
+		return nil, err
+	}
+	if (targetRepoOverride != name.Repository{}) {
+		r.oopt = append(r.oopt, ociremote.WithTargetRepository(targetRepoOverride))
+	}
+	return r, nil
 }
 
 // Publish implements Interface
@@ -53,6 +158,12 @@ func (r *recorder) Publish(ctx context.Context, br build.Result, ref string) (na
 	if err != nil {
 		return nil, err
 	}
+	if r.format == ImageRefsJSON {
+		if err := r.recordJSON(br, strings.TrimPrefix(ref, build.StrictScheme), result); err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
 
 	references := make([]string, 0, 20 /* just try to avoid resizing*/)
 	switch t := br.(type) {
@@ -72,6 +183,8 @@ func (r *recorder) Publish(ctx context.Context, br build.Result, ref string) (na
 		references = append(references, result.String())
 	}
 
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	if r.wc == nil {
 		f, err := os.OpenFile(r.fileName, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
 		if err != nil {
@@ -86,6 +199,94 @@ func (r *recorder) Publish(ctx context.Context, br build.Result, ref string) (na
 	return result, nil
 }
 
+// recordJSON records the image of importpath, and rewrites the file with the
+// images recorded so far.
+func (r *recorder) recordJSON(br build.Result, importpath string, result name.Reference) error {
+	h, err := br.Digest()
+	if err != nil {
+		return err
+	}
+	digest := result.Context().Digest(h.String())
+	img := RecordedImage{Ref: result.String()}
+	for _, t := range r.tags {
+		img.Tags = append(img.Tags, result.Context().Tag(t).String())
+	}
+	if img.RecordedAttachments, err = r.attachments(br, digest); err != nil {
+		return err
+	}
+
+	switch t := br.(type) {
+	case v1.ImageIndex:
+		im, err := t.IndexManifest()
+		if err != nil {
+			return err
+		}
+		for _, desc := range im.Manifests {
+			p := RecordedPlatform{
+				Platform: desc.Platform,
+				Ref:      result.Context().Digest(desc.Digest.String()).String(),
+			}
+			var child build.Result
+			if si, ok := t.(oci.SignedImageIndex); ok && desc.MediaType.IsImage() {
+				if child, err = si.SignedImage(desc.Digest); err != nil {
+					return err
+				}
+			}
+			if p.RecordedAttachments, err = r.attachments(child, result.Context().Digest(desc.Digest.String())); err != nil {
+				return err
+			}
+			img.Platforms = append(img.Platforms, p)
+		}
+	case v1.Image:
+		cf, err := t.ConfigFile()
+		if err != nil {
+			return err
+		}
+		if p := cf.Platform(); p != nil {
+			img.Platforms = []RecordedPlatform{{
+				Platform:            p,
+				Ref:                 digest.String(),
+				RecordedAttachments: img.RecordedAttachments,
+			}}
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.images[importpath] = img
+	b, err := json.MarshalIndent(r.images, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(r.fileName, append(b, '\n'), 0644)
+}
+
+// attachments returns the references of the attachments of the image or
+// index br, which is published as digest.  br may be nil for the images of
+// indexes that don't have attachments.
+func (r *recorder) attachments(br build.Result, digest name.Digest) (RecordedAttachments, error) {
+	var a RecordedAttachments
+	if se, ok := br.(oci.SignedEntity); ok && r.pushed {
+		if _, err := se.Attachment("sbom"); err == nil {
+			tag, err := ociremote.SBOMTag(digest, r.oopt...)
+			if err != nil {
+				return a, err
+			}
+			a.SBOM = tag.String()
+		}
+	}
+	tag, err := ociremote.SignatureTag(digest, r.oopt...)
+	if err != nil {
+		return a, err
+	}
+	a.Signature = tag.String()
+	if tag, err = ociremote.AttestationTag(digest, r.oopt...); err != nil {
+		return a, err
+	}
+	a.Attestation = tag.String()
+	return a, nil
+}
+
 // Close implements Interface
 func (r *recorder) Close() error {
 	if err := r.inner.Close(); err != nil {
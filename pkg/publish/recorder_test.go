@@ -16,15 +16,22 @@ package publish
 
 import (
 	"context"
+	"encoding/json"
 	"os"
 	"path"
 	"strings"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
 	"github.com/google/go-containerregistry/pkg/v1/random"
 	"github.com/google/ko/pkg/build"
+	ocimutate "github.com/sigstore/cosign/v3/pkg/oci/mutate"
 	"github.com/sigstore/cosign/v3/pkg/oci/signed"
+	"github.com/sigstore/cosign/v3/pkg/oci/static"
 )
 
 type cbPublish struct {
@@ -105,3 +112,115 @@ func TestRecorder(t *testing.T) {
 		}
 	}
 }
+
+func TestRecorderJSON(t *testing.T) {
+	repo := name.MustParseReference("example.com/app")
+	inner := &cbPublish{cb: func(_ context.Context, b build.Result, _ string) (name.Reference, error) {
+		h, err := b.Digest()
+		if err != nil {
+			return nil, err
+		}
+		return repo.Context().Digest(h.String()), nil
+	}}
+
+	amd64, arm64 := &v1.Platform{OS: "linux", Architecture: "amd64"}, &v1.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"}
+	var adds []mutate.IndexAddendum
+	for _, p := range []*v1.Platform{amd64, arm64} {
+		img, err := random.Image(3, 1)
+		if err != nil {
+			t.Fatalf("random.Image() = %v", err)
+		}
+		adds = append(adds, mutate.IndexAddendum{Add: img, Descriptor: v1.Descriptor{Platform: p}})
+	}
+	f, err := static.NewFile([]byte("sbom"))
+	if err != nil {
+		t.Fatalf("static.NewFile() = %v", err)
+	}
+	idx, err := ocimutate.AttachFileToImageIndex(signed.ImageIndex(mutate.AppendManifests(empty.Index, adds...)), "sbom", f)
+	if err != nil {
+		t.Fatalf("AttachFileToImageIndex() = %v", err)
+	}
+	im, err := idx.IndexManifest()
+	if err != nil {
+		t.Fatalf("IndexManifest() = %v", err)
+	}
+
+	attachments := func(d name.Digest, sbom bool) RecordedAttachments {
+		tag := strings.Replace(d.DigestStr(), ":", "-", 1)
+		a := RecordedAttachments{
+			Signature:   d.Context().Tag(tag + ".sig").String(),
+			Attestation: d.Context().Tag(tag + ".att").String(),
+		}
+		if sbom {
+			a.SBOM = d.Context().Tag(tag + ".sbom").String()
+		}
+		return a
+	}
+
+	for _, c := range []struct {
+		desc     string
+		opts     []RecorderOption
+		wantTags []string
+		wantSBOM bool
+	}{{
+		desc:     "pushed",
+		opts:     []RecorderOption{WithRecorderPushed([]string{"latest", "v1"})},
+		wantTags: []string{"example.com/app:latest", "example.com/app:v1"},
+		wantSBOM: true,
+	}, {
+		// Like publishing to the daemon, a tarball or a layout.
+		desc: "not pushed",
+	}} {
+		t.Run(c.desc, func(t *testing.T) {
+			file := path.Join(t.TempDir(), "refs.json")
+			recorder, err := NewRecorder(inner, file, append(c.opts, WithImageRefsFormat(ImageRefsJSON))...)
+			if err != nil {
+				t.Fatalf("NewRecorder() = %v", err)
+			}
+			ref, err := recorder.Publish(context.Background(), idx, build.StrictScheme+"example.com/app/cmd/app")
+			if err != nil {
+				t.Fatalf("recorder.Publish() = %v", err)
+			}
+			if err := recorder.Close(); err != nil {
+				t.Errorf("recorder.Close() = %v", err)
+			}
+
+			buf, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatalf("os.ReadFile() = %v", err)
+			}
+			var got map[string]RecordedImage
+			if err := json.Unmarshal(buf, &got); err != nil {
+				t.Fatalf("json.Unmarshal() = %v", err)
+			}
+
+			want := RecordedImage{
+				Ref:                 ref.String(),
+				Tags:                c.wantTags,
+				RecordedAttachments: attachments(ref.(name.Digest), c.wantSBOM),
+			}
+			for i, p := range []*v1.Platform{amd64, arm64} {
+				d := repo.Context().Digest(im.Manifests[i].Digest.String())
+				want.Platforms = append(want.Platforms, RecordedPlatform{
+					Platform:            p,
+					Ref:                 d.String(),
+					RecordedAttachments: attachments(d, false),
+				})
+			}
+			if diff := cmp.Diff(map[string]RecordedImage{"example.com/app/cmd/app": want}, got); diff != "" {
+				t.Errorf("image refs (-want +got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestParseImageRefsFormat(t *testing.T) {
+	for s, want := range map[string]ImageRefsFormat{"": ImageRefsText, "text": ImageRefsText, "json": ImageRefsJSON} {
+		if got, err := ParseImageRefsFormat(s); err != nil || got != want {
+			t.Errorf("ParseImageRefsFormat(%q) = %v, %v; wanted %v", s, got, err, want)
+		}
+	}
+	if _, err := ParseImageRefsFormat("yaml"); err == nil {
+		t.Error("ParseImageRefsFormat(yaml) = nil, wanted an error")
+	}
+}
@@ -26,23 +26,66 @@ import (
 	"github.com/google/ko/pkg/build"
 )
 
+// TarballFormat is the kind of archive that a tarball publisher writes.
+type TarballFormat string
+
+const (
+	// TarballDocker writes a docker-archive, as written by `docker save`.  It
+	// is the default, and can only hold single-platform images.
+	TarballDocker TarballFormat = "docker"
+	// TarballOCI writes an oci-archive: an OCI image layout in a tarball,
+	// which can also hold multi-platform images.
+	TarballOCI TarballFormat = "oci"
+)
+
+// ParseTarballFormat parses the name of a TarballFormat.
+func ParseTarballFormat(s string) (TarballFormat, error) {
+	switch f := TarballFormat(s); f {
+	case "":
+		return TarballDocker, nil
+	case TarballDocker, TarballOCI:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unsupported tarball format %q, must be docker or oci", s)
+	}
+}
+
 type tar struct {
-	file  string
-	base  string
-	namer Namer
-	tags  []string
-	refs  map[name.Reference]v1.Image
+	file   string
+	base   string
+	namer  Namer
+	tags   []string
+	format TarballFormat
+	refs   map[name.Reference]build.Result
+}
+
+// TarballOption is a functional option for NewTarball.
+type TarballOption func(*tar)
+
+// WithTarballFormat is a functional option for choosing the format of the
+// tarball.
+func WithTarballFormat(f TarballFormat) TarballOption {
+	return func(t *tar) {
+		if f != "" {
+			t.format = f
+		}
+	}
 }
 
 // NewTarball returns a new publish.Interface that saves images to a tarball.
-func NewTarball(file, base string, namer Namer, tags []string) Interface {
-	return &tar{
-		file:  file,
-		base:  base,
-		namer: namer,
-		tags:  tags,
-		refs:  make(map[name.Reference]v1.Image),
+func NewTarball(file, base string, namer Namer, tags []string, opts ...TarballOption) Interface {
+	t := &tar{
+		file:   file,
+		base:   base,
+		namer:  namer,
+		tags:   tags,
+		format: TarballDocker,
+		refs:   make(map[name.Reference]build.Result),
+	}
+	for _, option := range opts {
+		option(t)
 	}
+	return t
 }
 
 // Publish implements publish.Interface.
@@ -51,10 +94,10 @@ func (t *tar) Publish(_ context.Context, br build.Result, s string) (name.Refere
 	// https://github.com/google/go-containerregistry/issues/212
 	s = strings.ToLower(s)
 
-	// There's no way to write an index to a tarball, so attempt to downcast it to an image.
-	img, ok := br.(v1.Image)
-	if !ok {
-		return nil, fmt.Errorf("failed to interpret %s result as image: %v", s, br)
+	// There's no way to write an index to a docker-archive, so attempt to
+	// downcast it to an image.
+	if _, ok := br.(v1.Image); !ok && t.format == TarballDocker {
+		return nil, fmt.Errorf("failed to interpret %s result as image, multi-platform images need the oci tarball format: %v", s, br)
 	}
 
 	for _, tagName := range t.tags {
@@ -62,10 +105,10 @@ func (t *tar) Publish(_ context.Context, br build.Result, s string) (name.Refere
 		if err != nil {
 			return nil, err
 		}
-		t.refs[tag] = img
+		t.refs[tag] = br
 	}
 
-	h, err := img.Digest()
+	h, err := br.Digest()
 	if err != nil {
 		return nil, err
 	}
@@ -75,7 +118,7 @@ func (t *tar) Publish(_ context.Context, br build.Result, s string) (name.Refere
 		if err != nil {
 			return nil, err
 		}
-		t.refs[ref] = img
+		t.refs[ref] = br
 	}
 
 	ref := fmt.Sprintf("%s@%s", t.namer(t.base, s), h)
@@ -94,7 +137,17 @@ func (t *tar) Publish(_ context.Context, br build.Result, s string) (name.Refere
 
 func (t *tar) Close() error {
 	log.Printf("Saving %v", t.file)
-	if err := tarball.MultiRefWriteToFile(t.file, t.refs); err != nil {
+	var err error
+	if t.format == TarballOCI {
+		err = writeOCIArchiveToFile(t.file, t.refs)
+	} else {
+		imgs := make(map[name.Reference]v1.Image, len(t.refs))
+		for ref, br := range t.refs {
+			imgs[ref] = br.(v1.Image)
+		}
+		err = tarball.MultiRefWriteToFile(t.file, imgs)
+	}
+	if err != nil {
 		// Bad practice, but we log  this here because right now we just defer the Close.
 		log.Printf("failed to save %q: %v", t.file, err)
 		return err
@@ -15,15 +15,22 @@
 package publish_test
 
 import (
+	"archive/tar"
 	"context"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
 	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
 	"github.com/google/ko/pkg/publish"
+	specsv1 "github.com/opencontainers/image-spec/specs-go/v1"
 )
 
 func TestTarball(t *testing.T) {
@@ -68,3 +75,132 @@ func TestTarball(t *testing.T) {
 		}
 	}
 }
+
+func TestTarballOCI(t *testing.T) {
+	idx, err := random.Index(1024, 1, 2)
+	if err != nil {
+		t.Fatalf("random.Index() = %v", err)
+	}
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatalf("random.Image() = %v", err)
+	}
+	file := filepath.Join(t.TempDir(), "images.tar")
+	repoName := "example.com/blah"
+
+	// Multi-platform images can't go in a docker-archive.
+	dp := publish.NewTarball(file, repoName, md5Hash, []string{"latest"})
+	if _, err := dp.Publish(context.Background(), idx, "github.com/foo/index"); err == nil {
+		t.Error("Publish(index) to a docker tarball = nil, wanted an error")
+	}
+
+	tp := publish.NewTarball(file, repoName, md5Hash, []string{"latest", "v1"}, publish.WithTarballFormat(publish.TarballOCI))
+	if _, err := tp.Publish(context.Background(), idx, "github.com/foo/index"); err != nil {
+		t.Fatalf("Publish(index) = %v", err)
+	}
+	if _, err := tp.Publish(context.Background(), img, "github.com/foo/image"); err != nil {
+		t.Fatalf("Publish(image) = %v", err)
+	}
+	if err := tp.Close(); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+
+	dir := t.TempDir()
+	untar(t, file, dir)
+	p, err := layout.FromPath(dir)
+	if err != nil {
+		t.Fatalf("layout.FromPath() = %v", err)
+	}
+	ii, err := p.ImageIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validate.Index(ii); err != nil {
+		t.Errorf("validate.Index() = %v", err)
+	}
+	m, err := ii.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, desc := range m.Manifests {
+		got = append(got, desc.Annotations[specsv1.AnnotationRefName])
+	}
+	indexRepo := md5Hash(repoName, "github.com/foo/index")
+	imageRepo := md5Hash(repoName, "github.com/foo/image")
+	want := []string{indexRepo + ":latest", indexRepo + ":v1", imageRepo + ":latest", imageRepo + ":v1"}
+	if indexRepo > imageRepo {
+		want = append(want[2:], want[:2]...)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ref names (-want +got): %s", diff)
+	}
+
+	// Each blob is only written once.
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	seen := map[string]bool{}
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if seen[hdr.Name] {
+			t.Errorf("%s written more than once", hdr.Name)
+		}
+		seen[hdr.Name] = true
+	}
+}
+
+func TestParseTarballFormat(t *testing.T) {
+	for s, want := range map[string]publish.TarballFormat{
+		"":       publish.TarballDocker,
+		"docker": publish.TarballDocker,
+		"oci":    publish.TarballOCI,
+	} {
+		if got, err := publish.ParseTarballFormat(s); err != nil || got != want {
+			t.Errorf("ParseTarballFormat(%q) = %q, %v; wanted %q", s, got, err, want)
+		}
+	}
+	if _, err := publish.ParseTarballFormat("zip"); err == nil {
+		t.Error("ParseTarballFormat(zip) = nil, wanted an error")
+	}
+}
+
+func untar(t *testing.T, file, dir string) {
+	t.Helper()
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		p := filepath.Join(dir, filepath.FromSlash(hdr.Name))
+		if hdr.Typeflag == tar.TypeDir {
+			if err := os.MkdirAll(p, os.ModePerm); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		b, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, b, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}